@@ -102,7 +102,7 @@ func (rl *Shell) menuComplete() {
 func (rl *Shell) deleteCharOrList() {
 	switch {
 	case rl.cursor.Pos() < rl.line.Len():
-		rl.line.CutRune(rl.cursor.Pos())
+		rl.line.CutGrapheme(rl.cursor.Pos())
 	default:
 		rl.possibleCompletions()
 	}
@@ -7,11 +7,10 @@ import (
 	"strings"
 	"unicode"
 
-	"github.com/rivo/uniseg"
-
 	"github.com/reeflective/readline/inputrc"
 	"github.com/reeflective/readline/internal/color"
 	"github.com/reeflective/readline/internal/completion"
+	"github.com/reeflective/readline/internal/core"
 	"github.com/reeflective/readline/internal/keymap"
 	"github.com/reeflective/readline/internal/strutil"
 	"github.com/reeflective/readline/internal/term"
@@ -56,6 +55,7 @@ func (rl *Shell) standardCommands() commands {
 		"forward-backward-delete-char": rl.forwardBackwardDeleteChar,
 		"quoted-insert":                rl.quotedInsert,
 		"tab-insert":                   rl.tabInsert,
+		"newline-insert":               rl.newlineInsert,
 		"self-insert":                  rl.selfInsert,
 		"bracketed-paste-begin":        rl.bracketedPasteBegin,
 		"transpose-chars":              rl.transposeChars,
@@ -127,6 +127,8 @@ func (rl *Shell) standardCommands() commands {
 		"redo":                rl.redo,
 		"select-keyword-next": rl.selectKeywordNext,
 		"select-keyword-prev": rl.selectKeywordPrev,
+		"mouse-event":         rl.mouseEvent,
+		"suspend-process":     rl.suspendProcess,
 	}
 
 	return widgets
@@ -289,8 +291,8 @@ func (rl *Shell) downLine() {
 func (rl *Shell) clearScreen() {
 	rl.History.SkipSave()
 
-	fmt.Print(term.CursorTopLeft)
-	fmt.Print(term.ClearScreen)
+	rl.term.Print(term.CursorTopLeft)
+	rl.term.Print(term.ClearScreen)
 
 	rl.Display.PrintPrimaryPrompt()
 }
@@ -300,8 +302,8 @@ func (rl *Shell) clearScreen() {
 func (rl *Shell) clearDisplay() {
 	rl.History.SkipSave()
 
-	fmt.Print(term.CursorTopLeft)
-	fmt.Print(term.ClearDisplay)
+	rl.term.Print(term.CursorTopLeft)
+	rl.term.Print(term.ClearDisplay)
 
 	rl.Display.PrintPrimaryPrompt()
 }
@@ -333,7 +335,7 @@ func (rl *Shell) deleteChar() {
 
 	// Delete the chars in the line anyway
 	for i := 1; i <= vii; i++ {
-		rl.line.CutRune(rl.cursor.Pos())
+		rl.line.CutGrapheme(rl.cursor.Pos())
 	}
 }
 
@@ -369,12 +371,12 @@ func (rl *Shell) backwardDeleteChar() {
 
 		// And then delete the character under cursor.
 		rl.cursor.Dec()
-		rl.line.CutRune(rl.cursor.Pos())
+		rl.line.CutGrapheme(rl.cursor.Pos())
 
 	default:
 		for i := 1; i <= vii; i++ {
 			rl.cursor.Dec()
-			rl.line.CutRune(rl.cursor.Pos())
+			rl.line.CutGrapheme(rl.cursor.Pos())
 		}
 	}
 }
@@ -413,6 +415,15 @@ func (rl *Shell) tabInsert() {
 	rl.cursor.InsertAt('\t')
 }
 
+// Insert a newline character, without accepting the line. This is
+// meant to be bound to keys like Shift-Enter, when extended keys are
+// enabled, so as to edit multiline input regardless of AcceptMultiline.
+func (rl *Shell) newlineInsert() {
+	rl.History.SkipSave()
+
+	rl.cursor.InsertAt('\n')
+}
+
 // Insert the character typed.
 func (rl *Shell) selfInsert() {
 	rl.History.SkipSave()
@@ -433,23 +444,37 @@ func (rl *Shell) selfInsert() {
 	}
 
 	var quoted []rune
-	var length int
 
 	if rl.Config.GetBool("output-meta") && key[0] != inputrc.Esc {
 		quoted = append(quoted, key[0])
-		length = uniseg.StringWidth(string(quoted))
 	} else {
-		quoted, length = strutil.Quote(key[0])
+		quoted, _ = strutil.Quote(key[0])
 	}
 
 	rl.cursor.InsertAt(quoted...)
-	rl.cursor.Move(-1 * len(quoted))
-	rl.cursor.Move(length)
 }
 
+// This function is intended to be bound to the "bracketed paste" escape
+// sequence sent by some terminals, and is bound by default. It reads all
+// the pasted text until the end of the paste sequence, and inserts it as
+// a single block: newlines, tabs and other special keys are not dispatched
+// to their commands. The entire paste is recorded as a single undo step.
 func (rl *Shell) bracketedPasteBegin() {
-	// keys, _ := rl.Keys.PeekAllBytes()
-	// fmt.Println(string(keys))
+	rl.History.Save()
+
+	// Handle suffix-autoremoval for inserted completions.
+	rl.completer.TrimSuffix()
+
+	paste := rl.Keys.ReadPaste()
+	if len(paste) == 0 {
+		return
+	}
+
+	// Terminals send carriage returns for newlines.
+	text := strings.ReplaceAll(string(paste), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	rl.cursor.InsertAt([]rune(text)...)
 }
 
 // Drag the character before point forward over the character
@@ -464,18 +489,25 @@ func (rl *Shell) transposeChars() {
 
 	rl.History.Save()
 
-	switch {
-	case rl.cursor.Pos() == rl.line.Len():
-		last := (*rl.line)[rl.cursor.Pos()-1]
-		blast := (*rl.line)[rl.cursor.Pos()-2]
-		(*rl.line)[rl.cursor.Pos()-2] = last
-		(*rl.line)[rl.cursor.Pos()-1] = blast
-	default:
-		last := rl.cursor.Char()
-		blast := (*rl.line)[rl.cursor.Pos()-1]
-		(*rl.line)[rl.cursor.Pos()-1] = last
-		rl.cursor.ReplaceWith(blast)
+	// Transpose the characters before and under the cursor, or the last
+	// two ones at the end of the line, and move the cursor after them.
+	epos := rl.cursor.Pos()
+	if epos < rl.line.Len() {
+		_, epos = rl.line.Grapheme(epos)
 	}
+
+	mpos, _ := rl.line.Grapheme(epos - 1)
+	bpos, _ := rl.line.Grapheme(mpos - 1)
+
+	if bpos == mpos {
+		return
+	}
+
+	last := string((*rl.line)[mpos:epos])
+	blast := string((*rl.line)[bpos:mpos])
+
+	rl.line.InsertBetween(bpos, epos, []rune(last+blast)...)
+	rl.cursor.Set(epos)
 }
 
 // Drag the word before point past the word after point,
@@ -631,8 +663,8 @@ func (rl *Shell) capitalizeWord() {
 	backward := rl.line.Backward(rl.line.Tokenize, rl.cursor.Pos())
 	rl.cursor.Move(backward)
 
-	letter := rl.cursor.Char()
-	rl.cursor.ReplaceWith(unicode.ToUpper(letter))
+	letter := rl.cursor.Grapheme()
+	rl.cursor.ReplaceWith([]rune(strings.ToUpper(string(letter)))...)
 	rl.cursor.Set(startPos)
 }
 
@@ -649,7 +681,7 @@ func (rl *Shell) overwriteMode() {
 	defer done()
 
 	// All replaced characters are stored, to be used with backspace
-	cache := make([]rune, 0)
+	cache := make([][]rune, 0)
 
 	// Don't use the delete cache past the end of the line
 	lineStart := rl.line.Len()
@@ -674,10 +706,10 @@ func (rl *Shell) overwriteMode() {
 
 			// And recover the last replaced character
 			if len(cache) > 0 && rl.cursor.Pos() < lineStart {
-				key = cache[len(cache)-1]
+				replaced := cache[len(cache)-1]
 				cache = cache[:len(cache)-1]
 
-				rl.cursor.ReplaceWith(key)
+				rl.cursor.ReplaceWith(replaced...)
 			}
 		} else {
 			// If the cursor is at the end of the line,
@@ -685,7 +717,7 @@ func (rl *Shell) overwriteMode() {
 			if rl.line.Len() == rl.cursor.Pos() {
 				rl.cursor.InsertAt(key)
 			} else {
-				cache = append(cache, rl.cursor.Char())
+				cache = append(cache, rl.cursor.Grapheme())
 				rl.cursor.ReplaceWith(key)
 				rl.cursor.Inc()
 			}
@@ -1234,7 +1266,7 @@ func (rl *Shell) abort() {
 		key := rl.Keys.Caller()
 		if key[0] == rune(inputrc.Unescape(`\C-C`)[0]) {
 			quoted, _ := strutil.Quote(key[0])
-			fmt.Print(string(quoted))
+			rl.term.Print(string(quoted))
 		}
 	}
 
@@ -1431,7 +1463,7 @@ func (rl *Shell) insertComment() {
 // can be made part of an inputrc file.
 func (rl *Shell) dumpFunctions() {
 	rl.Display.ClearHelpers()
-	fmt.Println()
+	rl.term.Println()
 
 	defer func() {
 		rl.Prompt.PrimaryPrint()
@@ -1448,7 +1480,7 @@ func (rl *Shell) dumpFunctions() {
 // can be made part of an inputrc file.
 func (rl *Shell) dumpVariables() {
 	rl.Display.ClearHelpers()
-	fmt.Println()
+	rl.term.Println()
 
 	defer func() {
 		rl.Prompt.PrimaryPrint()
@@ -1468,12 +1500,12 @@ func (rl *Shell) dumpVariables() {
 	if rl.Iterations.IsSet() {
 		for _, variable := range variables {
 			value := rl.Config.Vars[variable]
-			fmt.Printf("set %s %v\n", variable, value)
+			rl.term.Printf("set %s %v\n", variable, value)
 		}
 	} else {
 		for _, variable := range variables {
 			value := rl.Config.Vars[variable]
-			fmt.Printf("%s is set to `%v'\n", variable, value)
+			rl.term.Printf("%s is set to `%v'\n", variable, value)
 		}
 	}
 }
@@ -1484,7 +1516,7 @@ func (rl *Shell) dumpVariables() {
 // can be made part of an inputrc file.
 func (rl *Shell) dumpMacros() {
 	rl.Display.ClearHelpers()
-	fmt.Println()
+	rl.term.Println()
 
 	defer func() {
 		rl.Prompt.PrimaryPrint()
@@ -1510,12 +1542,12 @@ func (rl *Shell) dumpMacros() {
 	if rl.Iterations.IsSet() {
 		for _, key := range macroBinds {
 			action := inputrc.Escape(binds[inputrc.Unescape(key)].Action)
-			fmt.Printf("\"%s\": \"%s\"\n", key, action)
+			rl.term.Printf("\"%s\": \"%s\"\n", key, action)
 		}
 	} else {
 		for _, key := range macroBinds {
 			action := inputrc.Escape(binds[inputrc.Unescape(key)].Action)
-			fmt.Printf("%s outputs %s\n", key, action)
+			rl.term.Printf("%s outputs %s\n", key, action)
 		}
 	}
 }
@@ -1526,7 +1558,7 @@ func (rl *Shell) editAndExecuteCommand() {
 	buffer := *rl.line
 
 	// Edit in editor
-	edited, err := rl.Buffers.EditBuffer(buffer, "", "", rl.Keymap.IsEmacs())
+	edited, err := rl.Buffers.EditBuffer(buffer, "", "", rl.Keymap.IsEmacs(), rl.RunExternal)
 	if err != nil || (len(edited) == 0 && len(buffer) != 0) {
 		rl.History.SkipSave()
 
@@ -1548,7 +1580,7 @@ func (rl *Shell) editCommandLine() {
 	keymapCur := rl.Keymap.Main()
 
 	// Edit in editor
-	edited, err := rl.Buffers.EditBuffer(buffer, "", "", rl.Keymap.IsEmacs())
+	edited, err := rl.Buffers.EditBuffer(buffer, "", "", rl.Keymap.IsEmacs(), rl.RunExternal)
 	if err != nil || (len(edited) == 0 && len(buffer) != 0) {
 		rl.History.SkipSave()
 
@@ -1619,3 +1651,40 @@ func (rl *Shell) selectKeywordPrev() {
 	rl.cursor.Set(epos)
 	rl.selection.Visual(false)
 }
+
+// Handle a mouse event reported by the terminal, when the enable-mouse option is on.
+// Clicking on the input line moves the cursor there, clicking on a completion candidate
+// selects and inserts it, and the wheel scrolls the completions if any, or the history.
+func (rl *Shell) mouseEvent() {
+	rl.History.SkipSave()
+
+	event, found := rl.Keys.Mouse()
+	if !found {
+		return
+	}
+
+	switch event.Button {
+	case core.MouseLeft:
+		if row, found := rl.Display.CompletionRow(event.Y); found && rl.completer.SelectAt(row, event.X-1) {
+			return
+		}
+
+		if pos, found := rl.Display.LinePosition(event.X, event.Y); found {
+			rl.cursor.Set(pos)
+		}
+
+	case core.MouseWheelUp:
+		if rl.completer.Matches() > 0 {
+			rl.completer.Select(-1, 0)
+		} else {
+			rl.upLineOrHistory()
+		}
+
+	case core.MouseWheelDown:
+		if rl.completer.Matches() > 0 {
+			rl.completer.Select(1, 0)
+		} else {
+			rl.downLineOrHistory()
+		}
+	}
+}
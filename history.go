@@ -28,6 +28,47 @@ var NewHistoryFromFile = history.NewSourceFromFile
 // to the readline instance, with shell.History.Add().
 var NewInMemoryHistory = history.NewInMemoryHistory
 
+// EntryHistory is a history source storing the metadata of its lines along with them:
+// the sources returned by NewHistoryFromFile and NewInMemoryHistory implement it.
+// The result of the command run for the last line accepted is attached to those
+// sources with shell.History.Finish(), and shell.History.SetFilter() restricts
+// history searches and completions to lines with some metadata (for instance,
+// to those whose command has succeeded in the current directory).
+type EntryHistory = history.EntrySource
+
+// HistoryEntry is a history line, along with the metadata of its command.
+type HistoryEntry = history.Entry
+
+// HistoryResult is the result of the command run for a history line.
+type HistoryResult = history.Result
+
+// SharedHistory is a history source which can be shared by several shells, like the
+// file source: the lines written by other shells are read from it with Reload before
+// each history walk or search.
+type SharedHistory = history.Reloader
+
+// HistoryPolicy decides which accepted lines are written to history sources, like the
+// HISTCONTROL and HISTIGNORE variables of Bash: it is set with shell.History.SetPolicy().
+type HistoryPolicy = history.Policy
+
+// HistoryRedaction redacts secrets (credentials, tokens, private keys...) from lines before
+// they are written to history sources, while the line returned by Readline is not redacted:
+// by default, the secrets detected by DefaultHistorySecrets are replaced with a placeholder.
+// It is set with shell.History.SetRedaction(), and shell.History.AddSecret() adds detectors.
+type HistoryRedaction = history.Redaction
+
+// HistorySecret is a detector of secrets redacted from lines written to history sources.
+type HistorySecret = history.Secret
+
+// DefaultHistorySecrets returns the detectors of secrets redacted by default: AWS keys, JSON
+// Web Tokens, bearer tokens, password/secret/token/api-key arguments, and PEM private keys.
+var DefaultHistorySecrets = history.DefaultSecrets
+
+// ErasableHistory is a history source from which lines can be removed, which is needed
+// to erase older copies of lines (HistoryPolicy.EraseDups): the sources returned by
+// NewHistoryFromFile and NewInMemoryHistory implement it.
+type ErasableHistory = history.EraseSource
+
 // historyCommands returns all history commands.
 // Under each comment are gathered all commands related to the comment's
 // subject. When there are two subgroups separated by an empty line, the
@@ -651,7 +692,8 @@ func (rl *Shell) acceptLineWith(infer, hold bool) {
 	rl.line, rl.cursor, rl.selection = rl.completer.GetBuffer()
 
 	// Without multiline support, we always return the line.
-	if rl.AcceptMultiline == nil {
+	// A secret line is never passed to the caller for checks.
+	if rl.AcceptMultiline == nil || rl.secret {
 		rl.Macros.StopRecord(rl.Keys.Caller()...)
 
 		rl.Display.AcceptLine()
@@ -0,0 +1,48 @@
+package readline
+
+import "github.com/reeflective/readline/internal/keymap"
+
+// lineState returns a copy of the input line and the cursor position when
+// the caller watches line changes, so that lineChanged can compare them.
+func (rl *Shell) lineState() (line string, cursor int) {
+	if rl.OnLineChange == nil || rl.secret {
+		return "", -1
+	}
+
+	current, cur := rl.completer.Line()
+
+	return string(*current), cur.Pos()
+}
+
+// lineChanged calls the OnLineChange hook if the input
+// line or the cursor position are not the given ones.
+func (rl *Shell) lineChanged(line string, cursor int) {
+	if rl.OnLineChange == nil || rl.secret {
+		return
+	}
+
+	current, cur := rl.completer.Line()
+	if string(*current) == line && cur.Pos() == cursor {
+		return
+	}
+
+	rl.OnLineChange(append([]rune(nil), *current...), cur.Pos())
+}
+
+// lineAccepted calls the OnAccept hook, unless the line is a secret.
+func (rl *Shell) lineAccepted(line string) {
+	if rl.OnAccept == nil || rl.secret {
+		return
+	}
+
+	rl.OnAccept(line)
+}
+
+// keymapChanged is called by the keymap engine when the current keymap changes.
+func (rl *Shell) keymapChanged(old, new keymap.Mode) {
+	if rl.OnKeymapChange == nil {
+		return
+	}
+
+	rl.OnKeymapChange(string(old), string(new))
+}
@@ -165,6 +165,16 @@ func IsMeta(c rune) bool {
 	return c > Delete && c <= 0xff
 }
 
+// Enmod encodes a key code pressed with the given modifiers (ModShift,
+// ModAlt, etc.) as an extended key sequence, in the canonical form of the kitty
+// keyboard protocol: "\x1b[<code>u", or "\x1b[<code>;<1+modifiers>u".
+func Enmod(c rune, mods int) string {
+	if mods == 0 {
+		return fmt.Sprintf("\x1b[%du", c)
+	}
+	return fmt.Sprintf("\x1b[%d;%du", c, mods+1)
+}
+
 // Error is a error.
 type Error string
 
@@ -188,6 +198,15 @@ const (
 	ErrUnknownModifier Error = "unknown modifier"
 )
 
+// Extended key modifiers, as reported by terminals using the kitty
+// keyboard protocol or the xterm modifyOtherKeys mode.
+const (
+	ModShift   = 1
+	ModAlt     = 2
+	ModControl = 4
+	ModSuper   = 8
+)
+
 // Error satisfies the error interface.
 func (err Error) Error() string {
 	return string(err)
@@ -3,6 +3,7 @@ package inputrc
 import (
 	"bytes"
 	"embed"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os/user"
@@ -138,6 +139,9 @@ func TestDecodeKey(t *testing.T) {
 		{"return", "\r"},
 		{"Meta-tab", "\x1b\t"},
 		{"Control-Meta-v", string(Encontrol(Enmeta('v')))},
+		{"Shift-Return", "\x1b[13;2u"},
+		{"Control-Shift-a", "\x1b[97;6u"},
+		{"Super-Meta-x", "\x1b[120;11u"},
 	}
 	for idx, test := range tests {
 		r := []rune(test.s)
@@ -154,6 +158,12 @@ func TestDecodeKey(t *testing.T) {
 			t.Errorf("test %d expected %q==%q", idx, exp, s)
 		}
 	}
+	for _, s := range []string{"S-Return", "Hyper-a"} {
+		r := []rune(s)
+		if _, _, err := decodeKey(r, 0, len(r)); !errors.Is(err, ErrUnknownModifier) {
+			t.Errorf("%s: expected error %v, got: %v", s, ErrUnknownModifier, err)
+		}
+	}
 }
 
 func newConfig() (*Config, map[string][]string) {
@@ -536,7 +536,7 @@ func decodeKey(seq []rune, pos, end int) (string, int, error) {
 	}
 
 	val := strings.ToLower(string(seq[start:pos]))
-	meta, control := false, false
+	meta, control, shift, super := false, false, false, false
 
 	for idx := strings.Index(val, "-"); idx != -1; idx = strings.Index(val, "-") {
 		switch val[:idx] {
@@ -544,6 +544,10 @@ func decodeKey(seq []rune, pos, end int) (string, int, error) {
 			control = true
 		case "meta", "m":
 			meta = true
+		case "shift":
+			shift = true
+		case "super":
+			super = true
 		default:
 			return "", idx, ErrUnknownModifier
 		}
@@ -577,6 +581,12 @@ func decodeKey(seq []rune, pos, end int) (string, int, error) {
 		char, _ = utf8.DecodeRuneInString(val)
 	}
 
+	// Keys with modifiers having no legacy encoding
+	// can only be bound to their extended sequence.
+	if shift || super {
+		return Enmod(char, extendedMods(shift, meta, control, super)), pos, nil
+	}
+
 	switch {
 	case control && meta:
 		return string([]rune{Esc, Encontrol(char)}), pos, nil
@@ -589,6 +599,23 @@ func decodeKey(seq []rune, pos, end int) (string, int, error) {
 	return string(char), pos, nil
 }
 
+// extendedMods returns the modifiers bitmask of an extended key.
+func extendedMods(shift, meta, control, super bool) (mods int) {
+	if shift {
+		mods |= ModShift
+	}
+	if meta {
+		mods |= ModAlt
+	}
+	if control {
+		mods |= ModControl
+	}
+	if super {
+		mods |= ModSuper
+	}
+	return mods
+}
+
 /*
 // decodeRunes decodes runes.
 func decodeRunes(r []rune, i, end int) string {
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/reeflective/readline/internal/color"
+	"github.com/reeflective/readline/internal/strutil"
 	"github.com/reeflective/readline/internal/term"
 )
 
@@ -14,8 +15,10 @@ import (
 // respecting the current display and completion settings.
 func Display(eng *Engine, maxRows int) {
 	eng.usedY = 0
+	eng.cells = nil
+	eng.menuTop, eng.menuRows = 0, 0
 
-	defer fmt.Print(term.ClearScreenBelow)
+	defer eng.term.Print(term.ClearScreenBelow)
 
 	// The completion engine might be inactive but still having
 	// a non-empty list of completions. This is on purpose, as
@@ -23,7 +26,7 @@ func Display(eng *Engine, maxRows int) {
 	// little more time. The engine itself is responsible for
 	// deleting those lists when it deems them useless.
 	if eng.Matches() == 0 || eng.skipDisplay {
-		fmt.Print(term.ClearLineAfter)
+		eng.term.Print(term.ClearLineAfter)
 		return
 	}
 
@@ -31,14 +34,14 @@ func Display(eng *Engine, maxRows int) {
 	completions := term.ClearLineAfter
 
 	for _, group := range eng.groups {
-		completions += eng.renderCompletions(group)
+		completions += eng.renderCompletions(group, strings.Count(completions, term.NewlineReturn))
 	}
 
 	// Crop the completions so that it fits within our terminal
 	completions, eng.usedY = eng.cropCompletions(completions, maxRows)
 
 	if completions != "" {
-		fmt.Print(completions)
+		eng.term.Print(completions)
 	}
 }
 
@@ -48,9 +51,18 @@ func Coordinates(e *Engine) int {
 	return e.usedY
 }
 
-// renderCompletions renders all completions in a given list (with aliases or not).
-// The descriptions list argument is optional.
-func (e *Engine) renderCompletions(grp *group) string {
+// menuCell is a candidate displayed in the completions list.
+type menuCell struct {
+	grp        *group
+	row, col   int // Coordinates of the candidate in its group.
+	line       int // Row of the rendered completions on which it is displayed.
+	start, end int // Columns used by the candidate, and its description if any.
+}
+
+// renderCompletions renders all completions in a given list (with aliases or not),
+// starting on a given row of the rendered completions, and records the columns used
+// by each candidate, so that they can be selected with the mouse (see SelectAt).
+func (e *Engine) renderCompletions(grp *group, line int) string {
 	var builder strings.Builder
 
 	if len(grp.rows) == 0 {
@@ -60,9 +72,12 @@ func (e *Engine) renderCompletions(grp *group) string {
 	if grp.tag != "" {
 		tag := fmt.Sprintf("%s%s%s %s", color.Bold, color.FgYellow, grp.tag, color.Reset)
 		builder.WriteString(tag + term.ClearLineAfter + term.NewlineReturn)
+		line++
 	}
 
 	for rowIndex, row := range grp.rows {
+		column := 0
+
 		for columnIndex := range grp.columnsWidth {
 			var value Candidate
 
@@ -79,6 +94,8 @@ func (e *Engine) renderCompletions(grp *group) string {
 			display := e.highlightDisplay(grp, value, padding, columnIndex, isSelected)
 
 			builder.WriteString(display)
+			cell := menuCell{grp: grp, row: rowIndex, col: columnIndex, line: line, start: column}
+			column += strutil.RealLength(display)
 
 			// Add description if no aliases, or if done with them.
 			onLast := columnIndex == len(grp.columnsWidth)-1
@@ -92,11 +109,18 @@ func (e *Engine) renderCompletions(grp *group) string {
 				descPad := grp.getPad(value, columnIndex, true)
 				desc := e.highlightDesc(grp, value, descPad, rowIndex, columnIndex, isSelected)
 				builder.WriteString(desc)
+				column += strutil.RealLength(desc)
+			}
+
+			if len(row) > columnIndex {
+				cell.end = column
+				e.cells = append(e.cells, cell)
 			}
 		}
 
 		// We're done for this line.
 		builder.WriteString(term.ClearLineAfter + term.NewlineReturn)
+		line++
 	}
 
 	return builder.String()
@@ -210,6 +234,7 @@ func (e *Engine) cutCompletionsBelow(scanner *bufio.Scanner, maxRows int) (strin
 	}
 
 	cropped = strings.TrimSuffix(cropped, term.NewlineReturn)
+	e.menuTop, e.menuRows = 0, count
 
 	// Add hint for remaining completions, if any.
 	_, used := e.completionCount()
@@ -249,6 +274,7 @@ func (e *Engine) cutCompletionsAboveBelow(scanner *bufio.Scanner, maxRows, absPo
 
 	cropped = strings.TrimSuffix(cropped, term.NewlineReturn)
 	count -= cutAbove + 1
+	e.menuTop, e.menuRows = cutAbove+1, count
 
 	// Add hint for remaining completions, if any.
 	_, used := e.completionCount()
@@ -6,6 +6,7 @@ import (
 	"github.com/reeflective/readline/inputrc"
 	"github.com/reeflective/readline/internal/core"
 	"github.com/reeflective/readline/internal/keymap"
+	"github.com/reeflective/readline/internal/term"
 	"github.com/reeflective/readline/internal/ui"
 )
 
@@ -16,6 +17,7 @@ type Engine struct {
 	cached        Completer       // A cached completer function to use when updating.
 	autoCompleter Completer       // Completer used by things like autocomplete
 	hint          *ui.Hint        // The completions can feed hint/usage messages
+	term          *term.Terminal  // The terminal on which completions are displayed
 
 	// Line parameters
 	keys       *core.Keys      // The input keys reader
@@ -34,9 +36,13 @@ type Engine struct {
 	suffix      string        // The current word suffix
 	inserted    []rune        // The selected candidate (inserted in line) without prefix or suffix.
 	usedY       int           // Comprehensive size offset (terminal rows) of the currently built completions.
+	cells       []menuCell    // Candidates displayed, with the rows and columns they use.
+	menuTop     int           // First row of the rendered completions that is displayed.
+	menuRows    int           // Number of rows of the rendered completions that are displayed.
 	auto        bool          // Is the engine autocompleting ?
 	autoForce   bool          // Special autocompletion mode (isearch-style)
 	skipDisplay bool          // Don't display completions if there are some.
+	secret      bool          // The line is a secret: no completions are generated.
 
 	// Incremental search
 	IsearchRegex       *regexp.Regexp // Holds the current search regex match
@@ -54,10 +60,11 @@ type Engine struct {
 }
 
 // NewEngine initializes a new completion engine with the shell operating parameters.
-func NewEngine(h *ui.Hint, km *keymap.Engine, o *inputrc.Config) *Engine {
+func NewEngine(t *term.Terminal, h *ui.Hint, km *keymap.Engine, o *inputrc.Config) *Engine {
 	return &Engine{
 		config: o,
 		hint:   h,
+		term:   t,
 		keymap: km,
 	}
 }
@@ -73,10 +80,22 @@ func Init(eng *Engine, k *core.Keys, l *core.Line, cur *core.Cursor, s *core.Sel
 	eng.autoCompleter = comp
 }
 
+// SetSecret is used when the shell reads a secret (like a password): while set,
+// no completions are generated, either explicitly or when autocompleting, so that
+// the secret is never passed to completers nor used to filter candidates.
+func SetSecret(eng *Engine, secret bool) {
+	eng.secret = secret
+}
+
 // Generate uses a list of completions to group/order and prepares completions before printing them.
 // If either no completions or only one is available after all constraints are applied, the engine
 // will automatically insert/accept and/or reset itself.
 func (e *Engine) Generate(completions Values) {
+	if e.secret {
+		e.ClearMenu(true)
+		return
+	}
+
 	e.prepare(completions)
 
 	if e.noCompletions() {
@@ -100,6 +119,12 @@ func (e *Engine) GenerateWith(completer Completer) {
 		return
 	}
 
+	// A secret line is never passed to completers.
+	if e.secret {
+		e.Generate(Values{})
+		return
+	}
+
 	// Call the provided/cached completer
 	// and use the completions as normal
 	e.Generate(e.cached())
@@ -154,6 +179,32 @@ func (e *Engine) Select(row, column int) {
 	}
 }
 
+// SelectAt selects the candidate displayed at some row (0 being the first row of
+// completions displayed) and column, and updates the inserted candidate like Select.
+// It returns false if there is no candidate displayed at these coordinates.
+func (e *Engine) SelectAt(row, column int) bool {
+	if row < 0 || row >= e.menuRows {
+		return false
+	}
+
+	for _, cell := range e.cells {
+		if cell.line != e.menuTop+row || column < cell.start || column >= cell.end {
+			continue
+		}
+
+		for _, grp := range e.groups {
+			grp.isCurrent = grp == cell.grp
+		}
+
+		cell.grp.posX, cell.grp.posY = cell.col, cell.row
+		e.Select(0, 0)
+
+		return true
+	}
+
+	return false
+}
+
 // SelectTag allows to select the first value of the next tag (next=true),
 // or the last value of the previous tag (next=false).
 func (e *Engine) SelectTag(next bool) {
@@ -297,7 +348,7 @@ func (e *Engine) Line() (*core.Line, *core.Cursor) {
 // We don't do it when we are currently in the completion keymap,
 // since that means completions have already been computed.
 func (e *Engine) Autocomplete() {
-	e.auto = e.needsAutoComplete()
+	e.auto = e.needsAutoComplete() && !e.secret
 
 	// Clear the current completion list when we are at the
 	// beginning of the line, and not currently completing.
@@ -9,7 +9,6 @@ import (
 	"golang.org/x/exp/slices"
 
 	"github.com/reeflective/readline/internal/color"
-	"github.com/reeflective/readline/internal/term"
 )
 
 // group is used to structure different types of completions with different
@@ -46,7 +45,7 @@ func (e *Engine) newCompletionGroup(comps Values, tag string, vals RawValues, de
 		posX:         -1,
 		posY:         -1,
 		columnsWidth: []int{0},
-		termWidth:    term.GetWidth(),
+		termWidth:    e.term.Width(),
 		longestDesc:  longest(descriptions, true),
 	}
 
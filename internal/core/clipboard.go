@@ -0,0 +1,58 @@
+package core
+
+import (
+	"encoding/base64"
+	"regexp"
+	"time"
+)
+
+var rxRcvClipboard = regexp.MustCompile("\x1b\\]52;[a-z0-9]*;([A-Za-z0-9+/=]*)(?:\a|\x1b\\\\)")
+
+// QueryClipboard queries the contents of a terminal selection ('c' for the clipboard, 'p' for the
+// primary selection) with an OSC 52 sequence, and returns them, or false if the terminal has not
+// answered before the timeout: most terminals do not allow applications to read the clipboard.
+// Keys input while waiting for the answer are stacked, like those read by WaitAvailableKeys.
+func (k *Keys) QueryClipboard(selection byte, timeout time.Duration) (contents string, found bool) {
+	k.term.Print("\x1b]52;" + string(selection) + ";?\a")
+
+	deadline := time.Now().Add(timeout)
+	defer func() { k.seqWait = 0 }()
+
+	var input []byte
+
+	for !found {
+		k.seqWait = time.Until(deadline)
+		if k.seqWait <= 0 || k.err != nil {
+			break
+		}
+
+		buf := make([]byte, keyScanBufSize)
+
+		read, _ := k.read(buf)
+		if read == 0 {
+			break
+		}
+
+		input = append(input, buf[:read]...)
+
+		if match := rxRcvClipboard.FindSubmatch(input); match != nil {
+			decoded, err := base64.StdEncoding.DecodeString(string(match[1]))
+			contents, found = string(decoded), err == nil
+			input = rxRcvClipboard.ReplaceAll(input, nil)
+		}
+	}
+
+	if _, keys := k.extractCursorPos(input); len(keys) > 0 {
+		k.stack(k.decode(keys))
+	}
+
+	return contents, found
+}
+
+// decode decodes the special sequences found in the input keys (mouse events and extended
+// keys), and drops the answers to clipboard queries which have been read after their timeout.
+func (k *Keys) decode(keys []byte) []byte {
+	keys = rxRcvClipboard.ReplaceAll(keys, nil)
+
+	return k.decodeExtended(k.decodeMouse(keys))
+}
@@ -47,19 +47,19 @@ func (c *Cursor) Pos() int {
 	return c.pos
 }
 
-// Inc increments the cursor position by 1,
-// if it's not at the end of the line.
+// Inc moves the cursor past the grapheme cluster (the user-perceived
+// character) under it, if it's not at the end of the line.
 func (c *Cursor) Inc() {
 	if c.pos < c.line.Len() {
-		c.pos++
+		_, c.pos = c.line.Grapheme(c.pos)
 	}
 }
 
-// Dec decrements the cursor position by 1,
-// if it's not at the beginning of the line.
+// Dec moves the cursor to the beginning of the grapheme cluster
+// before it, if it's not at the beginning of the line.
 func (c *Cursor) Dec() {
 	if c.pos > 0 {
-		c.pos--
+		c.pos, _ = c.line.Grapheme(c.pos - 1)
 	}
 }
 
@@ -87,17 +87,25 @@ func (c *Cursor) Char() rune {
 	return (*c.line)[c.pos]
 }
 
-// ReplaceWith replaces the rune (unicode point) under the cursor with the provided one.
-// If the cursor is appending to the line, the character is simply added at the end of it.
-func (c *Cursor) ReplaceWith(char rune) {
+// Grapheme returns the grapheme cluster (the user-perceived character) under the
+// cursor, which might be made of several runes, or nothing if the cursor is appending.
+func (c *Cursor) Grapheme() []rune {
 	c.CheckAppend()
 
-	switch {
-	case c.pos == c.line.Len():
-		c.line.Insert(c.line.Len(), char)
-	default:
-		(*c.line)[c.pos] = char
-	}
+	bpos, epos := c.line.Grapheme(c.pos)
+
+	return (*c.line)[bpos:epos]
+}
+
+// ReplaceWith replaces the grapheme cluster under the cursor with the provided characters.
+// If the cursor is appending to the line, the characters are simply added at the end of it.
+// The cursor stays at the beginning of the replacement.
+func (c *Cursor) ReplaceWith(chars ...rune) {
+	c.CheckAppend()
+
+	bpos, epos := c.line.Grapheme(c.pos)
+	c.line.InsertBetween(bpos, epos, chars...)
+	c.pos = bpos
 }
 
 // InsertAt inserts the given runes into the line at the current cursor position.
@@ -358,7 +366,8 @@ func (c *Cursor) CheckCommand() {
 // CoordinatesCursor returns the number of real terminal lines above the cursor position
 // (y value), and the number of columns since the beginning of the current line (x value).
 // @indent -    Used to align all lines (except the first) together on a single column.
-func CoordinatesCursor(cur *Cursor, indent int) (x, y int) {
+// @width -     The width of the terminal, in columns.
+func CoordinatesCursor(cur *Cursor, indent, width int) (x, y int) {
 	cur.CheckAppend()
 
 	newlines := cur.line.newlines()
@@ -372,13 +381,13 @@ func CoordinatesCursor(cur *Cursor, indent int) (x, y int) {
 			// simply care about the line count.
 			line := (*cur.line)[bpos:newline[0]]
 			bpos = newline[0] + 1
-			_, y := strutil.LineSpan(line, pos, indent)
+			_, y := strutil.LineSpan(line, pos, indent, width)
 			usedY += y
 
 		default:
 			// On the cursor line, use both line and column count.
 			line := (*cur.line)[bpos:cur.pos]
-			usedX, y := strutil.LineSpan(line, pos, indent)
+			usedX, y := strutil.LineSpan(line, pos, indent, width)
 			usedY += y
 
 			return usedX, usedY
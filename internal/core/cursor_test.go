@@ -11,6 +11,9 @@ var (
 
 	// cursorMultiline is used for tests requiring multiline input (horizontal positions, etc).
 	cursorMultiline = Line("git command -c \n second line of input before an empty line \n\n and then a last one")
+
+	// cursorGraphemes has a letter with a combining accent, and a ZWJ emoji sequence.
+	cursorGraphemes = Line("ce\u0301\U0001F468\u200D\U0001F469\u200D\U0001F467!")
 )
 
 func TestNewCursor(t *testing.T) {
@@ -148,6 +151,11 @@ func TestCursor_Inc(t *testing.T) {
 			fields: fields{line: &cursorLine, pos: len(cursorLine)},
 			want:   len(cursorLine),
 		},
+		{
+			name:   "Grapheme cluster",
+			fields: fields{line: &cursorGraphemes, pos: 1},
+			want:   3,
+		},
 	}
 
 	for _, test := range tests {
@@ -191,6 +199,11 @@ func TestCursor_Dec(t *testing.T) {
 			fields: fields{line: &cursorLine, pos: 0},
 			want:   0,
 		},
+		{
+			name:   "Grapheme cluster",
+			fields: fields{line: &cursorGraphemes, pos: 8},
+			want:   3,
+		},
 	}
 
 	for _, test := range tests {
@@ -1005,7 +1018,7 @@ func TestCursor_Coordinates(t *testing.T) {
 				mark: test.fields.mark,
 				line: test.fields.line,
 			}
-			gotX, gotY := CoordinatesCursor(c, indent)
+			gotX, gotY := CoordinatesCursor(c, indent, getTermWidth())
 			if gotX != test.wantX {
 				t.Errorf("Cursor.Coordinates() gotX = %v, want %v", gotX, test.wantX)
 			}
@@ -0,0 +1,211 @@
+package core
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/reeflective/readline/inputrc"
+)
+
+// Extended key events, as reported with the kitty keyboard protocol.
+const keyRelease = 3
+
+// decodeExtended decodes the extended key sequences found in the input keys, as sent by terminals
+// using the kitty keyboard protocol ("CSI code;modifiers u") or xterm modifyOtherKeys ("CSI 27;
+// modifiers;code ~"), when the enable-extended-keys option is on.
+//
+// Extended keys are translated into their canonical sequence (see inputrc.Enmod) if the latter
+// is bound in any keymap, or otherwise into their legacy encoding, so that existing binds still apply:
+// Shift-Enter is an extended key if bound, but an Enter key otherwise. Keys with modifiers having no
+// legacy encoding (like Super) are dropped if not bound.
+func (k *Keys) decodeExtended(keys []byte) []byte {
+	if k.cfg == nil || !k.cfg.GetBool("enable-extended-keys") {
+		return keys
+	}
+
+	decoded := make([]byte, 0, len(keys))
+
+	for len(keys) > 0 {
+		code, mods, size, isKey := parseExtended(keys)
+
+		switch {
+		case size == 0:
+			decoded = append(decoded, keys[0])
+			keys = keys[1:]
+
+			continue
+		case !isKey:
+		case k.isBound(inputrc.Enmod(code, mods)):
+			decoded = append(decoded, inputrc.Enmod(code, mods)...)
+		default:
+			decoded = append(decoded, legacyKey(code, mods)...)
+		}
+
+		keys = keys[size:]
+	}
+
+	return decoded
+}
+
+// parseExtended parses the extended key sequence at the beginning of keys, if any, and
+// returns its key code, modifiers and length. Key releases are not keys, but are parsed.
+func parseExtended(keys []byte) (code rune, mods, size int, isKey bool) {
+	if len(keys) < 3 || rune(keys[0]) != inputrc.Esc || keys[1] != '[' {
+		return
+	}
+
+	end := 2
+	for end < len(keys) && strings.IndexByte("0123456789;:", keys[end]) != -1 {
+		end++
+	}
+
+	if end == len(keys) {
+		return
+	}
+
+	params := strings.Split(string(keys[2:end]), ";")
+
+	// Subparameters hold alternate keys and event types.
+	param := func(i, field int) int {
+		if i >= len(params) {
+			return 0
+		}
+
+		fields := strings.Split(params[i], ":")
+		if field >= len(fields) {
+			return 0
+		}
+
+		value, _ := strconv.Atoi(fields[field])
+
+		return value
+	}
+
+	switch {
+	case keys[end] == 'u':
+		code, mods = rune(param(0, 0)), param(1, 0)
+		isKey = param(1, 1) != keyRelease
+	case keys[end] == '~' && len(params) == 3 && params[0] == "27":
+		code, mods = rune(param(2, 0)), param(1, 0)
+		isKey = true
+	default:
+		return
+	}
+
+	if code == 0 {
+		return 0, 0, 0, false
+	}
+
+	// Lock keys states are not modifiers.
+	mods = max(mods-1, 0) & (inputrc.ModShift | inputrc.ModAlt | inputrc.ModControl | inputrc.ModSuper)
+
+	// With modifyOtherKeys, shifted letters are reported as such (Control-Shift-a
+	// as "A"), while their canonical sequence has the unshifted key, like kitty.
+	if mods&inputrc.ModShift != 0 && unicode.IsUpper(code) {
+		code = unicode.ToLower(code)
+	}
+
+	return code, mods, end + 1, isKey
+}
+
+// completeSequence reads more input keys if the keys read end with an incomplete extended key or
+// mouse sequence, since terminals might split them across several writes, while only complete ones
+// can be decoded: keys are read until the sequence is complete, or until the keyseq-timeout, after
+// which they are decoded as is.
+func (k *Keys) completeSequence(keys []byte) []byte {
+	if !k.partialSequence(keys) {
+		return keys
+	}
+
+	timeout := time.Duration(k.cfg.GetInt("keyseq-timeout")) * time.Millisecond
+	deadline := time.Now().Add(timeout)
+
+	defer func(wait time.Duration) { k.seqWait = wait }(k.seqWait)
+
+	for k.partialSequence(keys) {
+		k.seqWait = time.Until(deadline)
+		if k.seqWait <= 0 || k.err != nil {
+			break
+		}
+
+		buf := make([]byte, keyScanBufSize)
+
+		read, _ := k.read(buf)
+		if read == 0 {
+			break
+		}
+
+		keys = append(keys, buf[:read]...)
+	}
+
+	return keys
+}
+
+// partialSequence returns true if the keys end with the beginning of an extended key or mouse
+// sequence (or of a cursor position answer, which has the same parameters), when they are on.
+func (k *Keys) partialSequence(keys []byte) bool {
+	if k.cfg == nil {
+		return false
+	}
+
+	extended, mouse := k.cfg.GetBool("enable-extended-keys"), k.cfg.GetBool("enable-mouse")
+	if !extended && !mouse {
+		return false
+	}
+
+	start := bytes.LastIndexByte(keys, byte(inputrc.Esc))
+	if start == -1 || start+1 == len(keys) || keys[start+1] != '[' {
+		return false
+	}
+
+	params := keys[start+2:]
+
+	switch {
+	case len(params) > 0 && params[0] == 'M':
+		return mouse && len(params) < 4
+	case len(params) > 0 && params[0] == '<':
+		params = params[1:]
+	}
+
+	return len(bytes.Trim(params, "0123456789;:")) == 0
+}
+
+// legacyKey returns the legacy encoding of an extended key, or nothing if there is none.
+func legacyKey(code rune, mods int) []byte {
+	if mods&inputrc.ModSuper != 0 {
+		return nil
+	}
+
+	key := string(code)
+
+	switch {
+	case mods&inputrc.ModShift != 0 && code == inputrc.Tab:
+		key = "\x1b[Z"
+	case mods&inputrc.ModControl != 0 && code == '?':
+		key = string(inputrc.Delete)
+	case mods&inputrc.ModControl != 0 && (unicode.IsLetter(code) && code < unicode.MaxASCII || strings.ContainsRune(" @[\\]^_", code)):
+		key = string(inputrc.Encontrol(code))
+	case mods&inputrc.ModShift != 0:
+		key = string(unicode.ToUpper(code))
+	}
+
+	if mods&inputrc.ModAlt != 0 {
+		key = string(inputrc.Esc) + key
+	}
+
+	return []byte(key)
+}
+
+// isBound returns true if the key sequence is bound in any keymap.
+func (k *Keys) isBound(seq string) bool {
+	for _, binds := range k.cfg.Binds {
+		if _, bound := binds[seq]; bound {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,60 +1,114 @@
 package core
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"io"
-	"os"
 	"regexp"
 	"sync"
+	"time"
 
 	"github.com/reeflective/readline/inputrc"
 	"github.com/reeflective/readline/internal/strutil"
+	"github.com/reeflective/readline/internal/term"
 )
 
 const (
 	keyScanBufSize = 1024
-)
 
-// Stdin is used by the Keys struct to read and write keys.
-// It can be overwritten to use other file descriptors or
-// custom io.Readers, such as the one used on Windows.
-var Stdin io.ReadCloser = os.Stdin
+	// bracketedPasteEnd is sent by the terminal after pasted text.
+	bracketedPasteEnd = "\x1b[201~"
+)
 
 var rxRcvCursorPos = regexp.MustCompile(`\x1b\[([0-9]+);([0-9]+)R`)
 
+// ErrIdleTimeout is returned when no input key has been read during the idle timeout.
+var ErrIdleTimeout = errors.New("timed out waiting for input: auto-logout")
+
+// cursorPosTimeout is how long to wait for the terminal to answer a cursor position query.
+const cursorPosTimeout = time.Second
+
+// errKeyseqTimeout aborts waiting for the end of an ambiguous key sequence.
+var errKeyseqTimeout = errors.New("timed out waiting for the end of a key sequence")
+
 // Keys is used read, manage and use keys input by the shell user.
 type Keys struct {
-	buf       []byte      // Keys read and waiting to be used.
-	matched   []rune      // Keys that have been successfully matched against a bind.
-	macroKeys []rune      // Keys that have been fed by a macro.
-	mustWait  bool        // Keys are in the stack, but we must still read stdin.
-	waiting   bool        // Currently waiting for keys on stdin.
-	reading   bool        // Currently reading keys out of the main loop.
-	keysOnce  chan []byte // Passing keys from the main routine.
-	cursor    chan []byte // Cursor coordinates has been read on stdin.
-	resize    chan bool   // Resize events on Windows are sent on stdin.
-
-	cfg   *inputrc.Config // Configuration file used for meta key settings
-	mutex sync.RWMutex    // Concurrency safety
+	buf       []byte       // Keys read and waiting to be used.
+	matched   []rune       // Keys that have been successfully matched against a bind.
+	macroKeys []rune       // Keys that have been fed by a macro.
+	mouse     []MouseEvent // Mouse events read, for which MouseKey is in the stack.
+	mustWait  bool         // Keys are in the stack, but we must still read stdin.
+	secret    bool         // Keys used are zeroed, since they are those of a secret.
+	waiting   bool         // Currently waiting for keys on stdin.
+	reading   bool         // Currently reading keys out of the main loop.
+	keysOnce  chan []byte  // Passing keys from the main routine.
+	cursor    chan []byte  // Cursor coordinates has been read on stdin.
+	resize    chan bool    // Resize events on Windows are sent on stdin.
+
+	term    *term.Terminal  // Terminal on which to query the cursor position.
+	input   io.Reader       // Input keys stream, translated on some platforms.
+	ctx     context.Context // Reading keys is aborted when this context is done.
+	idle    time.Duration   // Reading keys is aborted when idle for this long.
+	err     error           // Why reading keys has been aborted, if it has.
+	pending chan readResult // A read of the input stream still running in the background.
+	seqWait time.Duration   // Reads are aborted after this duration, when waiting for a sequence.
+	expired bool            // Waiting for the end of the current key sequence has timed out.
+	cfg     *inputrc.Config // Configuration file used for meta key settings
+	mutex   sync.RWMutex    // Concurrency safety
+}
+
+// readResult holds the keys read (or the error) by a background read.
+type readResult struct {
+	keys []byte
+	err  error
+}
+
+// NewKeys is a required constructor for the keys reader,
+// which reads its input from the given terminal.
+func NewKeys(t *term.Terminal) *Keys {
+	return &Keys{
+		term:   t,
+		input:  newInputReader(t),
+		cursor: make(chan []byte, 1),
+	}
+}
+
+// SetContext binds all subsequent reads of input keys to a context: once the latter
+// is done, blocking reads return immediately, and WaitAvailableKeys returns its error.
+// If idle is positive, reads are also aborted with ErrIdleTimeout after this duration
+// without any key being input.
+func SetContext(ctx context.Context, keys *Keys, idle time.Duration) {
+	keys.ctx = ctx
+	keys.idle = idle
+	keys.err = nil
 }
 
 // WaitAvailableKeys waits until an input key is either read from standard input,
 // or directly returns if the key stack still/already has available keys.
-func WaitAvailableKeys(keys *Keys, cfg *inputrc.Config) {
+// An error is returned if reading was aborted because of the keys context,
+// either because it is done or because no key has been input while idle.
+func WaitAvailableKeys(keys *Keys, cfg *inputrc.Config) error {
 	keys.cfg = cfg
+	keys.expired = false
+
+	// A command might have been reading keys
+	// when the context was done or timed out.
+	if keys.err != nil {
+		return keys.err
+	}
 
 	if len(keys.buf) > 0 && !keys.mustWait {
-		return
+		return nil
 	}
 
 	// The macro engine might have fed some keys
 	if len(keys.macroKeys) > 0 {
-		return
+		return nil
 	}
 
 	keys.mutex.Lock()
 	keys.waiting = true
-	keys.cursor = make(chan []byte)
 	keys.mutex.Unlock()
 
 	defer func() {
@@ -64,12 +118,16 @@ func WaitAvailableKeys(keys *Keys, cfg *inputrc.Config) {
 	}()
 
 	for {
-		// Start reading from os.Stdin in the background.
+		// Start reading from the terminal in the background.
 		// We will either read keyBuf from user, or an EOF
 		// send by ourselves, because we pause reading.
 		keyBuf, err := keys.readInputFiltered()
 		if err != nil && errors.Is(err, io.EOF) {
-			return
+			return nil
+		}
+
+		if keys.err != nil {
+			return keys.err
 		}
 
 		if len(keyBuf) == 0 {
@@ -82,27 +140,61 @@ func WaitAvailableKeys(keys *Keys, cfg *inputrc.Config) {
 			continue
 
 		default:
-			// When convert-meta is on, any meta-prefixed bind should
-			// be stripped and replaced with an escape meta instead.
-			if keys.cfg != nil && keys.cfg.GetBool("convert-meta") {
-				keyBuf = []byte(strutil.ConvertMeta([]rune(string(keyBuf))))
-			}
-
-			keys.mutex.RLock()
-			keys.buf = append(keys.buf, keyBuf...)
-			keys.mutex.RUnlock()
+			keys.stack(keyBuf)
 		}
 
-		return
+		return nil
 	}
 }
 
+// WaitKeys waits for more input keys when the keys read so far form an ambiguous
+// sequence, which matches a bind but also the beginning of longer ones (like an
+// escape key, which might be the beginning of an arrow key sequence split across
+// several reads). It returns true if more keys are available, or false if none was
+// input before the timeout (there is none if zero or negative). In the latter case,
+// subsequent calls return false right away, until WaitAvailableKeys is called again.
+func WaitKeys(keys *Keys, timeout time.Duration) bool {
+	if len(keys.buf) > 0 || len(keys.macroKeys) > 0 {
+		return true
+	}
+
+	if keys.expired || keys.err != nil {
+		return false
+	}
+
+	keys.seqWait = max(timeout, 0)
+	defer func() { keys.seqWait = 0 }()
+
+	keyBuf, _ := keys.readInputFiltered()
+	if len(keyBuf) == 0 {
+		keys.expired = true
+		return false
+	}
+
+	keys.stack(keyBuf)
+
+	return true
+}
+
+// WaitingInput returns true if the shell is blocked waiting for new input keys, either
+// in its main loop or in a command reading keys, after having processed all previous
+// ones. This is not the case when only waiting for the end of an ambiguous sequence.
+func WaitingInput(keys *Keys) bool {
+	keys.mutex.RLock()
+	defer keys.mutex.RUnlock()
+
+	return keys.waiting || keys.reading
+}
+
 // PopKey is used to pop a key off the key stack without
 // yet marking this key as having matched a bind command.
 func PopKey(keys *Keys) (key byte, empty bool) {
 	switch {
 	case len(keys.buf) > 0:
 		key = keys.buf[0]
+		if keys.secret {
+			keys.buf[0] = 0
+		}
 		keys.buf = keys.buf[1:]
 	case len(keys.macroKeys) > 0:
 		key = byte(keys.macroKeys[0])
@@ -169,6 +261,9 @@ func PopForce(keys *Keys) (key byte, empty bool) {
 	switch {
 	case len(keys.buf) > 0:
 		key = keys.buf[0]
+		if keys.secret {
+			keys.buf[0] = 0
+		}
 		keys.buf = keys.buf[1:]
 	case len(keys.macroKeys) > 0:
 		key = byte(keys.macroKeys[0])
@@ -197,23 +292,44 @@ func MacroKeys(keys *Keys) []rune {
 // FlushUsed drops the keys that have matched a given command.
 func FlushUsed(keys *Keys) {
 	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+
+	if keys.secret {
+		clear(keys.matched)
+	}
+
 	keys.matched = nil
+}
+
+// SetSecret is used when the shell reads a secret (like a password): the keys
+// are zeroed once they have been used, along with the last keys matched when
+// the secret mode is left, so that they do not keep a copy of the secret.
+func SetSecret(keys *Keys, secret bool) {
+	keys.mutex.Lock()
 	defer keys.mutex.Unlock()
+
+	if !secret {
+		clear(keys.matched)
+		keys.matched = nil
+	}
+
+	keys.secret = secret
 }
 
 // ReadKey reads keys from stdin like Read(), but immediately
 // returns them instead of storing them in the stack, along with
 // an indication on whether this key is an escape/abort one.
+// If reading is aborted by the keys context, an escape is returned.
 func (k *Keys) ReadKey() (key rune, isAbort bool) {
-	k.mutex.RLock()
+	k.mutex.Lock()
 	k.keysOnce = make(chan []byte)
 	k.reading = true
-	k.mutex.RUnlock()
+	k.mutex.Unlock()
 
 	defer func() {
-		k.mutex.RLock()
+		k.mutex.Lock()
 		k.reading = false
-		k.mutex.RUnlock()
+		k.mutex.Unlock()
 	}()
 
 	switch {
@@ -226,6 +342,10 @@ func (k *Keys) ReadKey() (key rune, isAbort bool) {
 		key = []rune(string(buf))[0]
 	default:
 		buf, _ := k.readInputFiltered()
+		if len(buf) == 0 {
+			return inputrc.Esc, true
+		}
+
 		key = []rune(string(buf))[0]
 	}
 
@@ -237,6 +357,67 @@ func (k *Keys) ReadKey() (key rune, isAbort bool) {
 	return key, key == inputrc.Esc
 }
 
+// ReadPaste reads all keys until the end of a bracketed paste sequence is found,
+// either in the key stack or on stdin, and returns the pasted text verbatim.
+// This function should be called after the paste start sequence has matched.
+// Any keys found after the end of the paste are kept in the stack, and all the
+// keys read are marked as matched, so that macros can record pasted text.
+func (k *Keys) ReadPaste() (paste []rune) {
+	var buf []byte
+
+	macro := false
+
+	for {
+		if keys, fed := k.popStack(); len(keys) > 0 {
+			buf = append(buf, keys...)
+			macro = macro || fed
+		} else {
+			keys, err := k.readInputFiltered()
+			if (err != nil && errors.Is(err, io.EOF)) || k.err != nil {
+				k.matched = append(k.matched, []rune(string(buf))...)
+				return []rune(string(buf))
+			}
+
+			buf = append(buf, keys...)
+		}
+
+		end := bytes.Index(buf, []byte(bracketedPasteEnd))
+		if end == -1 {
+			continue
+		}
+
+		// Put back the keys that have been read past the paste.
+		remain := []rune(string(buf[end+len(bracketedPasteEnd):]))
+		if macro {
+			k.Feed(true, remain...)
+		} else {
+			k.mutex.Lock()
+			k.buf = append([]byte(string(remain)), k.buf...)
+			k.mutex.Unlock()
+		}
+
+		k.matched = append(k.matched, []rune(string(buf[:end+len(bracketedPasteEnd)]))...)
+
+		return []rune(string(buf[:end]))
+	}
+}
+
+// popStack removes all keys from the stack, or if it is empty, all keys fed by
+// macros, and returns them along with whether they have been fed by a macro.
+func (k *Keys) popStack() (keys []byte, macro bool) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	switch {
+	case len(k.buf) > 0:
+		keys, k.buf = k.buf, nil
+	case len(k.macroKeys) > 0:
+		keys, k.macroKeys, macro = []byte(string(k.macroKeys)), nil, true
+	}
+
+	return keys, macro
+}
+
 // Pop removes the first byte in the key stack (first read) and returns it.
 // It returns either a key and the empty boolean set to false, or if no keys
 // are present, returns a zero rune and empty set to true.
@@ -287,6 +468,128 @@ func (k *Keys) Feed(begin bool, keys ...rune) {
 	}
 }
 
+// ReadInput reads input bytes as is, without extracting any terminal reply from
+// them. Like all reads, it returns early if the keys context is done or idle.
+func ReadInput(keys *Keys, buf []byte) (int, error) {
+	return keys.read(buf)
+}
+
+// read reads input keys into buf, like a call to the input stream Read() method,
+// but returns early if the keys context is done, or if no keys are read during
+// the idle timeout. In this case, the error is also stored in the keys.
+func (k *Keys) read(buf []byte) (int, error) {
+	if k.ctx == nil && k.seqWait == 0 {
+		return k.input.Read(buf)
+	}
+
+	ctx, cancel := k.ctx, context.CancelFunc(func() {})
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if k.idle > 0 {
+		ctx, cancel = context.WithTimeoutCause(ctx, k.idle, ErrIdleTimeout)
+	}
+	defer cancel()
+
+	// Waiting for the end of a key sequence
+	// in vain is not an error for the shell.
+	if k.seqWait > 0 {
+		var cancelWait context.CancelFunc
+
+		ctx, cancelWait = context.WithTimeoutCause(ctx, k.seqWait, errKeyseqTimeout)
+		defer cancelWait()
+	}
+
+	read, err := k.readContext(ctx, buf)
+	if err != nil && ctx.Err() != nil {
+		if cause := context.Cause(ctx); errors.Is(cause, errKeyseqTimeout) {
+			return read, cause
+		}
+
+		k.err = context.Cause(ctx)
+
+		return read, k.err
+	}
+
+	return read, err
+}
+
+// readAsync reads the input stream in the background, so that the caller
+// can stop waiting for keys when the context is done. The read is not lost
+// when abandoned: its result will be returned by the next call to readAsync.
+func (k *Keys) readAsync(ctx context.Context, buf []byte) (int, error) {
+	if k.pending == nil {
+		pending := make(chan readResult, 1)
+		k.pending = pending
+
+		go func(size int) {
+			keys := make([]byte, size)
+			read, err := k.input.Read(keys)
+			pending <- readResult{keys: keys[:read], err: err}
+		}(len(buf))
+	}
+
+	select {
+	case result := <-k.pending:
+		k.pending = nil
+		return copy(buf, result.keys), result.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// stack appends keys read on the input stream to the key stack.
+func (k *Keys) stack(keys []byte) {
+	// When convert-meta is on, any meta-prefixed bind should
+	// be stripped and replaced with an escape meta instead.
+	if k.cfg != nil && k.cfg.GetBool("convert-meta") {
+		keys = []byte(strutil.ConvertMeta([]rune(string(keys))))
+	}
+
+	k.mutex.Lock()
+	k.buf = append(k.buf, keys...)
+	k.mutex.Unlock()
+}
+
+// sendCursorPos passes a cursor position answer to GetCursorPos: the answer is buffered, so that
+// it is not lost if read before GetCursorPos waits for it. Answers read after their query has been
+// abandoned (like when the keys context is done) are dropped by the next query.
+func (k *Keys) sendCursorPos(cursor []byte) {
+	select {
+	case k.cursor <- cursor:
+	default:
+	}
+}
+
+// waitCursorPos waits for a cursor position answer read by the main
+// key reading routine, until the query timeout or the keys context is done.
+func (k *Keys) waitCursorPos() (cursor []byte) {
+	var done <-chan struct{}
+	if k.ctx != nil {
+		done = k.ctx.Done()
+	}
+
+	timer := time.NewTimer(cursorPosTimeout)
+	defer timer.Stop()
+
+	select {
+	case cursor = <-k.cursor:
+	case <-timer.C:
+	case <-done:
+	}
+
+	return cursor
+}
+
+// dropCursorPos drops any cursor position answer to an abandoned query.
+func (k *Keys) dropCursorPos() {
+	select {
+	case <-k.cursor:
+	default:
+	}
+}
+
 func (k *Keys) extractCursorPos(keys []byte) (cursor, remain []byte) {
 	if !rxRcvCursorPos.Match(keys) {
 		return cursor, keys
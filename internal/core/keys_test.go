@@ -0,0 +1,288 @@
+package core
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/reeflective/readline/inputrc"
+	"github.com/reeflective/readline/internal/term"
+)
+
+func TestKeys_ReadPaste(t *testing.T) {
+	type fields struct {
+		buf       []byte
+		macroKeys []rune
+	}
+	tests := []struct {
+		name      string
+		fields    fields
+		want      string
+		wantBuf   string
+		wantMacro string
+	}{
+		{
+			name:   "Single line paste",
+			fields: fields{buf: []byte("git status\x1b[201~")},
+			want:   "git status",
+		},
+		{
+			name:   "Multiline paste with tabs",
+			fields: fields{buf: []byte("echo a\r\techo b\r\x1b[201~")},
+			want:   "echo a\r\techo b\r",
+		},
+		{
+			name:    "Keys typed after the paste are preserved",
+			fields:  fields{buf: []byte("ls\x1b[201~\r")},
+			want:    "ls",
+			wantBuf: "\r",
+		},
+		{
+			name:      "Paste fed by a macro",
+			fields:    fields{macroKeys: []rune("ls -l\x1b[201~\x1b")},
+			want:      "ls -l",
+			wantMacro: "\x1b",
+		},
+		{
+			name:   "Empty paste",
+			fields: fields{buf: []byte("\x1b[201~")},
+			want:   "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := &Keys{
+				buf:       test.fields.buf,
+				macroKeys: test.fields.macroKeys,
+			}
+
+			if got := string(keys.ReadPaste()); got != test.want {
+				t.Errorf("Keys.ReadPaste() = %q, want %q", got, test.want)
+			}
+
+			if got := string(keys.buf); got != test.wantBuf {
+				t.Errorf("Keys.buf = %q, want %q", got, test.wantBuf)
+			}
+
+			if got := string(keys.macroKeys); got != test.wantMacro {
+				t.Errorf("Keys.macroKeys = %q, want %q", got, test.wantMacro)
+			}
+		})
+	}
+}
+
+func TestSetSecret(t *testing.T) {
+	stack := []byte("pw\r")
+	matched := []rune("pw")
+
+	keys := &Keys{buf: stack}
+	SetSecret(keys, true)
+
+	// Keys used are zeroed, but not those still in the stack.
+	PopKey(keys)
+	PopForce(keys)
+
+	if want := "\x00\x00\r"; string(stack) != want {
+		t.Errorf("key stack = %q, want %q", stack, want)
+	}
+
+	keys.matched = matched
+	FlushUsed(keys)
+
+	if want := "\x00\x00"; string(matched) != want {
+		t.Errorf("matched keys = %q, want %q", string(matched), want)
+	}
+
+	// The last keys matched are zeroed when leaving the secret mode.
+	matched = []rune("\r")
+	keys.matched = matched
+	SetSecret(keys, false)
+
+	if string(matched) != "\x00" || keys.matched != nil {
+		t.Errorf("matched keys = %q, want zeroed", string(matched))
+	}
+
+	// Keys are kept as is otherwise.
+	keys.buf = stack[2:]
+	PopKey(keys)
+
+	if want := "\x00\x00\r"; string(stack) != want {
+		t.Errorf("key stack = %q, want %q", stack, want)
+	}
+}
+
+func TestWaitKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		buf         []byte
+		input       string
+		want        bool
+		wantBuf     string
+		wantExpired bool
+	}{
+		{
+			name:    "Keys already in the stack",
+			buf:     []byte("[A"),
+			want:    true,
+			wantBuf: "[A",
+		},
+		{
+			name:    "Keys input before the timeout",
+			input:   "[A",
+			want:    true,
+			wantBuf: "[A",
+		},
+		{
+			name:        "No keys input before the timeout",
+			want:        false,
+			wantExpired: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader, writer := io.Pipe()
+			defer writer.Close()
+
+			keys := NewKeys(term.NewTerminal(reader, io.Discard, nil))
+			keys.buf = test.buf
+
+			SetContext(context.Background(), keys, 0)
+
+			if test.input != "" {
+				go writer.Write([]byte(test.input))
+			}
+
+			if got := WaitKeys(keys, 100*time.Millisecond); got != test.want {
+				t.Errorf("WaitKeys() = %v, want %v", got, test.want)
+			}
+
+			if got := string(keys.buf); got != test.wantBuf {
+				t.Errorf("Keys.buf = %q, want %q", got, test.wantBuf)
+			}
+
+			if keys.expired != test.wantExpired {
+				t.Errorf("Keys.expired = %v, want %v", keys.expired, test.wantExpired)
+			}
+
+			if keys.err != nil {
+				t.Errorf("Keys.err = %v, want nil", keys.err)
+			}
+		})
+	}
+}
+
+func TestKeys_decodeExtended(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want string
+	}{
+		{name: "Legacy keys", keys: "ab\x1b[A\r", want: "ab\x1b[A\r"},
+		{name: "Bound key", keys: "a\x1b[13;2ub", want: "a\x1b[13;2ub"},
+		{name: "Unbound key", keys: "\x1b[13;5u", want: "\r"},
+		{name: "Control key", keys: "\x1b[97;5u\x1b[63;5u", want: "\x01\x7f"},
+		{name: "Control-Shift key", keys: "\x1b[97;6u", want: "\x01"},
+		{name: "Meta key", keys: "\x1b[98;3u", want: "\x1bb"},
+		{name: "Shift-Tab", keys: "\x1b[9;2u", want: "\x1b[Z"},
+		{name: "Escape", keys: "\x1b[27u", want: "\x1b"},
+		{name: "Lock keys", keys: "\x1b[97;69u", want: "\x01"},
+		{name: "Alternate keys", keys: "\x1b[97:65;5u", want: "\x01"},
+		{name: "Key release", keys: "\x1b[97;5:3ua", want: "a"},
+		{name: "Super key", keys: "\x1b[97;9ua", want: "a"},
+		{name: "modifyOtherKeys", keys: "\x1b[27;5;97~\x1b[27;2;13~", want: "\x01\x1b[13;2u"},
+		{name: "modifyOtherKeys shifted letter", keys: "\x1b[27;6;65~\x1b[27;6;66~", want: "\x01\x1b[98;6u"},
+	}
+
+	cfg := inputrc.NewDefaultConfig()
+	cfg.Set("enable-extended-keys", true)
+	cfg.Bind("emacs", inputrc.Enmod(inputrc.Return, inputrc.ModShift), "newline-insert", false)
+	cfg.Bind("emacs", inputrc.Enmod('b', inputrc.ModShift|inputrc.ModControl), "backward-word", false)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := &Keys{cfg: cfg}
+
+			if got := string(keys.decodeExtended([]byte(test.keys))); got != test.want {
+				t.Errorf("Keys.decodeExtended() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestKeys_partialSequence(t *testing.T) {
+	tests := []struct {
+		name  string
+		keys  string
+		mouse bool
+		want  bool
+	}{
+		{name: "No sequence", keys: "ab", want: false},
+		{name: "Escape", keys: "a\x1b", want: false},
+		{name: "Sequence start", keys: "a\x1b[", want: true},
+		{name: "Extended key", keys: "a\x1b[97;", want: true},
+		{name: "Extended key with subparameters", keys: "\x1b[97:65;5:", want: true},
+		{name: "Complete extended key", keys: "\x1b[97;5u", want: false},
+		{name: "Legacy key", keys: "\x1b[A", want: false},
+		{name: "Mouse event", keys: "\x1b[<0;5", want: true},
+		{name: "Legacy mouse event", keys: "\x1b[M !", mouse: true, want: true},
+		{name: "Legacy mouse event disabled", keys: "\x1b[M !", want: false},
+		{name: "Complete legacy mouse event", keys: "\x1b[M !\"", mouse: true, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := inputrc.NewDefaultConfig()
+			cfg.Set("enable-extended-keys", true)
+			cfg.Set("enable-mouse", test.mouse)
+
+			keys := &Keys{cfg: cfg}
+
+			if got := keys.partialSequence([]byte(test.keys)); got != test.want {
+				t.Errorf("Keys.partialSequence(%q) = %v, want %v", test.keys, got, test.want)
+			}
+		})
+	}
+}
+
+func TestKeys_decodeMouse(t *testing.T) {
+	tests := []struct {
+		name   string
+		keys   string
+		want   string
+		events []MouseEvent
+	}{
+		{name: "No mouse event", keys: "ab\x1b[A", want: "ab\x1b[A"},
+		{name: "Click", keys: "a\x1b[<0;5;2Mb", want: "a" + MouseKey + "b", events: []MouseEvent{{MouseLeft, 5, 2}}},
+		{name: "Release", keys: "\x1b[<0;5;2m", want: ""},
+		{name: "Motion", keys: "\x1b[<32;6;2M", want: ""},
+		{name: "Click with modifiers", keys: "\x1b[<16;5;2M", want: MouseKey, events: []MouseEvent{{MouseLeft, 5, 2}}},
+		{name: "Wheel", keys: "\x1b[<64;1;1M\x1b[<65;1;1M", want: MouseKey + MouseKey, events: []MouseEvent{{MouseWheelUp, 1, 1}, {MouseWheelDown, 1, 1}}},
+		{name: "Legacy click", keys: "\x1b[M !\"\x1b[M#!\"", want: MouseKey, events: []MouseEvent{{MouseLeft, 1, 2}}},
+	}
+
+	cfg := inputrc.NewDefaultConfig()
+	cfg.Set("enable-mouse", true)
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := &Keys{cfg: cfg}
+
+			if got := string(keys.decodeMouse([]byte(test.keys))); got != test.want {
+				t.Errorf("Keys.decodeMouse() = %q, want %q", got, test.want)
+			}
+
+			for _, want := range test.events {
+				if got, found := keys.Mouse(); !found || got != want {
+					t.Errorf("Keys.Mouse() = %v, %v, want %v, true", got, found, want)
+				}
+			}
+
+			if got, found := keys.Mouse(); found {
+				t.Errorf("Keys.Mouse() = %v, true, want no event", got)
+			}
+		})
+	}
+}
@@ -3,18 +3,38 @@
 package core
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"io"
 	"os"
 	"strconv"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/reeflective/readline/internal/term"
 )
 
+// newInputReader returns the stream from which to read keys:
+// on Unix systems, keys are read as is on the terminal input.
+func newInputReader(t *term.Terminal) io.Reader {
+	return t
+}
+
 // GetCursorPos returns the current cursor position in the terminal.
 // It is safe to call this function even if the shell is reading input.
+// Terminals not answering cursor queries (like dumb ones) are not queried,
+// and the position is then unknown (-1).
 func (k *Keys) GetCursorPos() (x, y int) {
+	if !k.term.Features().CursorControl {
+		return -1, -1
+	}
+
 	disable := func() (int, int) {
-		os.Stderr.WriteString("\r\ngetCursorPos() not supported by terminal emulator, disabling....\r\n")
+		if k.err != nil {
+			return -1, -1
+		}
+
+		k.term.Print("\r\ngetCursorPos() not supported by terminal emulator, disabling....\r\n")
 		return -1, -1
 	}
 
@@ -23,20 +43,27 @@ func (k *Keys) GetCursorPos() (x, y int) {
 
 	// Echo the query and wait for the main key
 	// reading routine to send us the response back.
-	fmt.Print("\x1b[6n")
+	k.dropCursorPos()
+	k.term.Print("\x1b[6n")
 
 	// In order not to get stuck with an input that might be user-one
 	// (like when the user typed before the shell is fully started, and yet not having
 	// queried cursor yet), we keep reading from stdin until we find the cursor response.
 	// Everything else is passed back as user input.
 	for {
+		k.mutex.RLock()
+		passed := k.waiting || k.reading
+		k.mutex.RUnlock()
+
 		switch {
-		case k.waiting, k.reading:
-			cursor = <-k.cursor
+		case passed:
+			if cursor = k.waitCursorPos(); len(cursor) == 0 {
+				return -1, -1
+			}
 		default:
 			buf := make([]byte, keyScanBufSize)
 
-			read, err := os.Stdin.Read(buf)
+			read, err := k.read(buf)
 			if err != nil {
 				return disable()
 			}
@@ -54,9 +81,9 @@ func (k *Keys) GetCursorPos() (x, y int) {
 
 		// If there is something but not cursor answer, its user input.
 		if len(match) == 0 && len(cursor) > 0 {
-			k.mutex.RLock()
+			k.mutex.Lock()
 			k.buf = append(k.buf, cursor...)
-			k.mutex.RUnlock()
+			k.mutex.Unlock()
 
 			continue
 		}
@@ -84,23 +111,78 @@ func (k *Keys) GetCursorPos() (x, y int) {
 }
 
 func (k *Keys) readInputFiltered() (keys []byte, err error) {
-	// Start reading from os.Stdin in the background.
+	// Start reading from the terminal in the background.
 	// We will either read keys from user, or an EOF
 	// send by ourselves, because we pause reading.
 	buf := make([]byte, keyScanBufSize)
 
-	read, err := Stdin.Read(buf)
+	read, err := k.read(buf)
 	if err != nil && errors.Is(err, io.EOF) {
 		return
 	}
 
 	// Always attempt to extract cursor position info.
 	// If found, strip it and keep the remaining keys.
-	cursor, keys := k.extractCursorPos(buf[:read])
+	cursor, keys := k.extractCursorPos(k.completeSequence(buf[:read]))
 
 	if len(cursor) > 0 {
-		k.cursor <- cursor
+		k.sendCursorPos(cursor)
+	}
+
+	return k.decode(keys), nil
+}
+
+// readContext reads the input stream once some keys are available on it,
+// or returns the context error if the latter is done before. Streams that
+// are not terminal files are read in the background instead.
+func (k *Keys) readContext(ctx context.Context, buf []byte) (int, error) {
+	descriptor, isFile := k.term.Fd()
+	if !isFile || k.pending != nil {
+		return k.readAsync(ctx, buf)
+	}
+
+	if err := waitInput(ctx, descriptor); err != nil {
+		return 0, err
 	}
 
-	return keys, nil
+	return k.input.Read(buf)
+}
+
+// waitInput blocks until the file descriptor is ready to be read, or until
+// the context is done, in which case a pipe is used to wake up the select.
+func waitInput(ctx context.Context, descriptor int) error {
+	wakeRead, wakeWrite, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+
+	defer wakeRead.Close()
+	defer wakeWrite.Close()
+
+	stop := context.AfterFunc(ctx, func() {
+		wakeWrite.Write([]byte{0})
+	})
+	defer stop()
+
+	wake := int(wakeRead.Fd())
+
+	for {
+		var fds unix.FdSet
+
+		fds.Set(descriptor)
+		fds.Set(wake)
+
+		_, err := unix.Select(max(descriptor, wake)+1, &fds, nil, nil, nil)
+
+		switch {
+		case errors.Is(err, unix.EINTR):
+			continue
+		case err != nil:
+			return err
+		case fds.IsSet(wake):
+			return ctx.Err()
+		default:
+			return nil
+		}
+	}
 }
@@ -4,11 +4,14 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"io"
+	"os"
 	"unsafe"
 
 	"github.com/reeflective/readline/inputrc"
+	"github.com/reeflective/readline/internal/term"
 )
 
 // Windows-specific special key codes.
@@ -50,8 +53,15 @@ const (
 	charBackspace = 127
 )
 
-func init() {
-	Stdin = newRawReader()
+// newInputReader returns the stream from which to read keys: when reading
+// from the console standard input, Windows input records are translated to
+// ANSI sequences. Other streams are assumed to already send such sequences.
+func newInputReader(t *term.Terminal) io.Reader {
+	if file, isFile := t.Input().(*os.File); isFile && file == os.Stdin && t.IsTerminal() {
+		return newRawReader()
+	}
+
+	return t
 }
 
 // GetTerminalResize sends booleans over a channel to notify resize events on Windows.
@@ -66,12 +76,12 @@ func GetTerminalResize(keys *Keys) <-chan bool {
 // readInputFiltered on Windows needs to check for terminal resize events.
 func (k *Keys) readInputFiltered() (keys []byte, err error) {
 	for {
-		// Start reading from os.Stdin in the background.
+		// Start reading from the terminal in the background.
 		// We will either read keys from user, or an EOF
 		// send by ourselves, because we pause reading.
 		buf := make([]byte, keyScanBufSize)
 
-		read, err := Stdin.Read(buf)
+		read, err := k.read(buf)
 		if err != nil && errors.Is(err, io.EOF) {
 			return keys, err
 		}
@@ -85,18 +95,26 @@ func (k *Keys) readInputFiltered() (keys []byte, err error) {
 			continue
 		}
 
+		input = k.completeSequence(input)
+
 		// Always attempt to extract cursor position info.
 		// If found, strip it and keep the remaining keys.
 		cursor, keys := k.extractCursorPos(input)
 
 		if len(cursor) > 0 {
-			k.cursor <- cursor
+			k.sendCursorPos(cursor)
 		}
 
-		return keys, nil
+		return k.decode(keys), nil
 	}
 }
 
+// readContext reads the input stream in the background, so
+// that it can return as soon as the context is done.
+func (k *Keys) readContext(ctx context.Context, buf []byte) (int, error) {
+	return k.readAsync(ctx, buf)
+}
+
 // rawReader translates Windows input to ANSI sequences,
 // to provide the same behavior as Unix terminals.
 type rawReader struct {
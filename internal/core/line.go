@@ -1,7 +1,6 @@
 package core
 
 import (
-	"fmt"
 	"regexp"
 	"strings"
 	"unicode"
@@ -11,6 +10,7 @@ import (
 	"github.com/reeflective/readline/internal/color"
 	"github.com/reeflective/readline/internal/strutil"
 	"github.com/reeflective/readline/internal/term"
+	"github.com/rivo/uniseg"
 )
 
 // Tokenizer is a method used by a (line) type to split itself according to
@@ -126,6 +126,51 @@ func (l *Line) CutRune(pos int) {
 	}
 }
 
+// CutGrapheme deletes the grapheme cluster (see Line.Grapheme) starting at the given
+// position in the line, or the last one if the position is the end of the line.
+// If the position is out of bounds, nothing is deleted.
+func (l *Line) CutGrapheme(pos int) {
+	if pos < 0 || pos > l.Len() || l.Len() == 0 {
+		return
+	}
+
+	if pos == l.Len() {
+		pos--
+	}
+
+	bpos, epos := l.Grapheme(pos)
+	l.Cut(bpos, epos)
+}
+
+// Grapheme returns the begin and end positions of the grapheme cluster containing the
+// character at pos: this is a user-perceived character, like a letter with combining
+// accents, a flag or an emoji sequence, which might be made of several runes.
+// If the position is the end of the line, both positions are the end of the line.
+func (l *Line) Grapheme(pos int) (bpos, epos int) {
+	if pos < 0 || pos >= l.Len() {
+		return l.Len(), l.Len()
+	}
+
+	// Newlines always break clusters, so start from the line beginning.
+	bpos = l.Find(inputrc.Newline, pos, false) + 1
+	state := -1
+
+	for rest := string((*l)[bpos:]); len(rest) > 0; {
+		var cluster string
+
+		cluster, rest, _, state = uniseg.FirstGraphemeClusterInString(rest, state)
+		epos = bpos + utf8.RuneCountInString(cluster)
+
+		if epos > pos {
+			break
+		}
+
+		bpos = epos
+	}
+
+	return bpos, epos
+}
+
 // Len returns the length of the line, as given by ut8.RuneCount.
 // This should NOT confused with the length of the line in terms of
 // how many terminal columns its printed representation will take.
@@ -314,11 +359,11 @@ func (l *Line) SurroundQuotes(single bool, pos int) (bpos, epos int) {
 	return bpos, epos
 }
 
-// DisplayLine prints the line to stdout, starting at the current terminal
+// DisplayLine prints the line to the terminal, starting at its current
 // cursor position, assuming it is at the end of the shell prompt string.
 // Params:
 // @indent -    Used to align all lines (except the first) together on a single column.
-func DisplayLine(l *Line, indent int) {
+func DisplayLine(t *term.Terminal, l *Line, indent int) {
 	lines := strings.Split(string(*l), "\n")
 
 	if strings.HasSuffix(string(*l), "\n") {
@@ -331,19 +376,19 @@ func DisplayLine(l *Line, indent int) {
 
 		// Clear everything before each line, except the first.
 		if num > 0 {
-			term.MoveCursorForwards(indent)
+			t.MoveCursorForwards(indent)
 			line = term.ClearLineBefore + line
 		}
 
 		// Clear everything after each line, except the last.
 		if num < len(lines)-1 {
-			if len(line)+indent < term.GetWidth() {
+			if len(line)+indent < t.Width() {
 				line += term.ClearLineAfter
 			}
 			line += term.NewlineReturn
 		}
 
-		fmt.Print(line)
+		t.Print(line)
 	}
 }
 
@@ -352,16 +397,17 @@ func DisplayLine(l *Line, indent int) {
 // take into account an eventual suggestion added to the line before printing.
 // Params:
 // @indent - Coordinates to align all lines (except the first) together on a single column.
+// @width -  The width of the terminal, in columns.
 // Returns:
 // @x - The number of columns, starting from the terminal left, to the end of the last line.
 // @y - The number of actual lines on which the line spans, accounting for line wrap.
-func CoordinatesLine(l *Line, indent int) (x, y int) {
+func CoordinatesLine(l *Line, indent, width int) (x, y int) {
 	line := string(*l)
 	lines := strings.Split(line, "\n")
 	usedY, usedX := 0, 0
 
 	for i, line := range lines {
-		x, y := strutil.LineSpan([]rune(line), i, indent)
+		x, y := strutil.LineSpan([]rune(line), i, indent, width)
 		usedY += y
 		usedX = x
 	}
@@ -677,13 +723,18 @@ func closeToken(idx, count, cpos, match int, pos map[int]int, line []rune, split
 	return count, split
 }
 
-// newlines gives the indexes of all newline characters in the line.
+// newlines gives the indexes of all newline characters in the line,
+// and of an additional one at the end of the line.
 func (l *Line) newlines() [][]int {
-	line := string(*l)
-	line += string(inputrc.Newline)
-	nl := regexp.MustCompile(string(inputrc.Newline))
+	var newlines [][]int
+
+	for pos, char := range *l {
+		if char == inputrc.Newline {
+			newlines = append(newlines, []int{pos, pos + 1})
+		}
+	}
 
-	return nl.FindAllStringIndex(line, -1)
+	return append(newlines, []int{l.Len(), l.Len() + 1})
 }
 
 // returns bpos, epos ordered and true if either is valid.
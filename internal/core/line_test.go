@@ -1,7 +1,9 @@
 package core
 
 import (
+	"io"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/reeflective/readline/internal/term"
@@ -9,7 +11,7 @@ import (
 
 // getTermWidth is used as a variable so that we can
 // use specific terminal widths in our tests.
-var getTermWidth = term.GetWidth
+var getTermWidth = func() int { return 80 }
 
 func TestLine_Insert(t *testing.T) {
 	line := Line("multiple-ambiguous 10.203.23.45")
@@ -224,6 +226,60 @@ func TestLine_CutRune(t *testing.T) {
 	}
 }
 
+func TestLine_Grapheme(t *testing.T) {
+	// A letter with a combining accent, a family emoji (ZWJ sequence) and a flag.
+	line := Line("e\u0301t\U0001F468\u200D\U0001F469\u200D\U0001F467\U0001F1EB\U0001F1F7\n!")
+
+	tests := []struct {
+		name     string
+		pos      int
+		wantBpos int
+		wantEpos int
+	}{
+		{name: "Combining accent (base)", pos: 0, wantBpos: 0, wantEpos: 2},
+		{name: "Combining accent (mark)", pos: 1, wantBpos: 0, wantEpos: 2},
+		{name: "Single rune", pos: 2, wantBpos: 2, wantEpos: 3},
+		{name: "ZWJ sequence", pos: 5, wantBpos: 3, wantEpos: 8},
+		{name: "Flag", pos: 9, wantBpos: 8, wantEpos: 10},
+		{name: "Newline", pos: 10, wantBpos: 10, wantEpos: 11},
+		{name: "After newline", pos: 11, wantBpos: 11, wantEpos: 12},
+		{name: "End of line", pos: 12, wantBpos: 12, wantEpos: 12},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bpos, epos := line.Grapheme(test.pos)
+			if bpos != test.wantBpos || epos != test.wantEpos {
+				t.Errorf("Line.Grapheme() = (%d, %d), want (%d, %d)", bpos, epos, test.wantBpos, test.wantEpos)
+			}
+		})
+	}
+}
+
+func TestLine_CutGrapheme(t *testing.T) {
+	tests := []struct {
+		name string
+		line Line
+		pos  int
+		want string
+	}{
+		{name: "Combining accent", line: Line("cafe\u0301s"), pos: 3, want: "cafs"},
+		{name: "ZWJ sequence", line: Line("a\U0001F468\u200D\U0001F469b"), pos: 1, want: "ab"},
+		{name: "End of line, append mode", line: Line("ab\U0001F1EB\U0001F1F7"), pos: 4, want: "ab"},
+		{name: "Invalid position (not removed)", line: Line("ab"), pos: 3, want: "ab"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.line.CutGrapheme(test.pos)
+
+			if string(test.line) != test.want {
+				t.Errorf("Line: %q, wanted %q", string(test.line), test.want)
+			}
+		})
+	}
+}
+
 func TestLine_Len(t *testing.T) {
 	line := Line("basic -f \"commands.go,line.go\" -cp=/usr")
 
@@ -1038,7 +1094,7 @@ func TestDisplayLine(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			DisplayLine(tt.l, tt.args.indent)
+			DisplayLine(term.NewTerminal(nil, io.Discard, nil), tt.l, tt.args.indent)
 		})
 	}
 }
@@ -1047,6 +1103,7 @@ func TestCoordinatesLine(t *testing.T) {
 	indent := 10
 	line := Line("basic -f \"commands.go,line.go\" -cp=/usr --option [value1 value2]")
 	multiline := Line("basic -f \"commands.go \nanother testing\" --alternate \"another\nquote\" -v { expression here } -a [value1 value2]")
+	wide := Line(strings.Repeat("-", 69) + "日本")
 
 	// Reassign the function for getting the terminal width to a fixed value
 	getTermWidth = func() int { return 80 }
@@ -1076,11 +1133,18 @@ func TestCoordinatesLine(t *testing.T) {
 			wantY: 2,
 			wantX: indent + 48,
 		},
+		{
+			name:  "Wide characters wrapped before the end of the row",
+			l:     &wide,
+			args:  args{indent: indent},
+			wantY: 1,
+			wantX: 4,
+		},
 	}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			gotX, gotY := CoordinatesLine(test.l, test.args.indent)
+			gotX, gotY := CoordinatesLine(test.l, test.args.indent, getTermWidth())
 			if gotX != test.wantX {
 				t.Errorf("CoordinatesLine() gotX = %v, want %v", gotX, test.wantX)
 			}
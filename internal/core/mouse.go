@@ -0,0 +1,132 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/reeflective/readline/inputrc"
+)
+
+// MouseKey is the key sequence replacing each mouse event in the input keys, so
+// that mouse events can be bound to a command (see Keys.Mouse). It is the legacy
+// xterm mouse sequence, which is also the mouse key of terminfo entries (kmous).
+const MouseKey = "\x1b[M"
+
+// Mouse buttons, as reported by terminals.
+const (
+	MouseLeft      = 0
+	MouseMiddle    = 1
+	MouseRight     = 2
+	MouseWheelUp   = 64
+	MouseWheelDown = 65
+)
+
+// Bits of mouse buttons holding the modifiers and the motion flag.
+const (
+	mouseModifiers = 4 | 8 | 16
+	mouseMotion    = 32
+)
+
+// MouseEvent is a mouse button press reported by the terminal.
+type MouseEvent struct {
+	Button int // Button pressed (see the Mouse* constants).
+	X, Y   int // Terminal coordinates of the event, starting at 1.
+}
+
+// Mouse returns the mouse event for which the MouseKey sequence
+// has been read, or false if there is no such event pending.
+func (k *Keys) Mouse() (event MouseEvent, found bool) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if len(k.mouse) == 0 {
+		return event, false
+	}
+
+	event = k.mouse[0]
+	k.mouse = k.mouse[1:]
+
+	return event, true
+}
+
+// decodeMouse extracts the mouse events reported by the terminal in the input keys, when the
+// enable-mouse option is on: button presses are replaced with MouseKey, and queued so that the
+// command bound to it can get them, while releases and motion events are dropped. Events are
+// reported with SGR sequences ("CSI < button;x;y M/m"), or with legacy ones ("CSI M" + 3 bytes).
+func (k *Keys) decodeMouse(keys []byte) []byte {
+	if k.cfg == nil || !k.cfg.GetBool("enable-mouse") {
+		return keys
+	}
+
+	decoded := make([]byte, 0, len(keys))
+
+	for len(keys) > 0 {
+		event, size, isPress := parseMouse(keys)
+
+		switch {
+		case size == 0:
+			decoded = append(decoded, keys[0])
+			keys = keys[1:]
+
+			continue
+		case isPress:
+			k.mutex.Lock()
+			k.mouse = append(k.mouse, event)
+			k.mutex.Unlock()
+
+			decoded = append(decoded, MouseKey...)
+		}
+
+		keys = keys[size:]
+	}
+
+	return decoded
+}
+
+// parseMouse parses the mouse event sequence at the beginning of keys, if any,
+// and returns the event and its length, along with whether it is a button press.
+func parseMouse(keys []byte) (event MouseEvent, size int, isPress bool) {
+	if len(keys) < 3 || rune(keys[0]) != inputrc.Esc || keys[1] != '[' {
+		return
+	}
+
+	var button int
+	var release bool
+
+	switch {
+	case keys[2] == '<':
+		end := 3
+		for end < len(keys) && strings.IndexByte("0123456789;", keys[end]) != -1 {
+			end++
+		}
+
+		if end == len(keys) || (keys[end] != 'M' && keys[end] != 'm') {
+			return
+		}
+
+		params := strings.Split(string(keys[3:end]), ";")
+		if len(params) != 3 {
+			return
+		}
+
+		button, _ = strconv.Atoi(params[0])
+		event.X, _ = strconv.Atoi(params[1])
+		event.Y, _ = strconv.Atoi(params[2])
+		release = keys[end] == 'm'
+		size = end + 1
+
+	case keys[2] == 'M' && len(keys) >= 6:
+		button = int(keys[3]) - ' '
+		event.X, event.Y = int(keys[4])-' ', int(keys[5])-' '
+		release = button&3 == 3 && button < MouseWheelUp
+		size = 6
+
+	default:
+		return
+	}
+
+	event.Button = button &^ mouseModifiers
+	isPress = !release && button&mouseMotion == 0
+
+	return event, size, isPress
+}
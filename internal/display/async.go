@@ -0,0 +1,257 @@
+package display
+
+import (
+	"strings"
+
+	"github.com/reeflective/readline/internal/color"
+	"github.com/reeflective/readline/internal/strutil"
+	"github.com/reeflective/readline/internal/term"
+)
+
+// message is a string printed above the prompt by a caller of the shell.
+type message struct {
+	text      string
+	transient bool
+}
+
+// Start must be called when the shell starts reading a line:
+// from then on, messages printed with PrintAbove are either
+// printed right away, or queued until the display is released.
+func (e *Engine) Start() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.active = true
+	e.waiting = false
+	e.suspended = false
+}
+
+// Stop must be called once the shell has returned the line: any message
+// still queued is printed, and subsequent ones will be printed as is.
+func (e *Engine) Stop() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// The terminal is still in raw mode.
+	for _, msg := range e.queued {
+		e.term.Print(strings.ReplaceAll(msg.text, "\n", term.NewlineReturn))
+	}
+
+	e.queued = nil
+	e.active = false
+	e.waiting = false
+	e.suspended = false
+	e.resumed = nil
+}
+
+// Release must be called when the shell has refreshed its interface
+// and is about to wait for input keys: queued messages are printed,
+// and until Acquire is called, messages are printed without delay.
+func (e *Engine) Release() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// The process has been resumed while running a command.
+	if e.resumed != nil {
+		e.restoreTerminal(e.resumed)
+		e.resumed = nil
+	}
+
+	for _, msg := range e.queued {
+		e.printAbove(msg)
+	}
+
+	e.queued = nil
+	e.waiting = true
+}
+
+// Acquire must be called when the shell has read some input keys,
+// and before running any command: messages will be queued until
+// the display is released again.
+func (e *Engine) Acquire() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.waiting = false
+}
+
+// Suspend is called when the shell is running a command using other programs
+// on the terminal: the helpers below the input line are cleared, and the cursor
+// is put on the first column of the row below the line, where those programs
+// can start displaying. Until Resume is called, messages are queued, and the
+// interface is not redisplayed. It returns false if the shell is not reading
+// a line, or if the display is already suspended.
+func (e *Engine) Suspend() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.active || e.suspended {
+		return false
+	}
+
+	e.suspended = true
+
+	e.CursorBelowLine()
+	e.term.Print(term.ClearScreenBelow)
+
+	return true
+}
+
+// Suspended returns true if the display is suspended (see Suspend).
+func (e *Engine) Suspended() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	return e.suspended
+}
+
+// Resume displays the whole interface again once other programs are done using
+// the terminal. If inPlace is true, they are assumed to have put the cursor back
+// where Suspend left it (like full-screen programs and pickers do), and the
+// interface is displayed where it was: otherwise, it is displayed on the row
+// of the cursor, since the programs might have printed anything above it.
+func (e *Engine) Resume(inPlace bool) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.suspended {
+		return
+	}
+
+	e.suspended = false
+	e.resumed = nil
+
+	if inPlace {
+		e.term.MoveCursorBackwards(e.term.Width())
+		e.term.MoveCursorUp(e.lineRows + 1 + e.prompt.PrimaryUsed())
+		e.term.Print(term.ClearScreenBelow)
+	}
+
+	e.Redisplay()
+}
+
+// PrintAbove prints a message above the shell interface, and redisplays the
+// prompt, the input line and helpers below it. It is safe to call this function
+// concurrently with the shell, including from within a command it is running.
+//
+// If transient is true, the message is printed in place of the current prompt
+// and input line, otherwise it is printed below the current input line.
+//
+// It returns the number of bytes of the message written, and the error of the
+// terminal output if the write failed. If the shell is using the display, the
+// message is queued and printed later: 0 is returned, along with no error.
+func (e *Engine) PrintAbove(text string, transient bool) (n int, err error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	msg := message{text: text, transient: transient}
+
+	if e.active && !e.waiting {
+		e.queued = append(e.queued, msg)
+		return 0, nil
+	}
+
+	return e.printAbove(msg)
+}
+
+// refreshAsync redisplays the interface if the shell is waiting for keys,
+// since otherwise, the shell will itself refresh before waiting for them.
+func (e *Engine) refreshAsync() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.active && e.waiting {
+		e.Refresh()
+	}
+}
+
+// resume restores the terminal when the process is resumed while the shell is reading a
+// line, and redisplays the interface. If the shell is running a command, both are done once
+// it is done, since the restore function uses the shell state: see Release. Nothing is done
+// when the display is suspended, since other programs (or the suspend-process command) are
+// then using the terminal, and the terminal is restored when resuming the display.
+func (e *Engine) resume(restore func() error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.active || e.suspended {
+		return
+	}
+
+	if !e.waiting {
+		e.resumed = restore
+		return
+	}
+
+	e.restoreTerminal(restore)
+}
+
+// restoreTerminal restores the terminal, and redisplays the whole interface,
+// since the screen might have been changed while the process was suspended.
+func (e *Engine) restoreTerminal(restore func() error) {
+	if err := restore(); err != nil {
+		e.hint.SetTemporary(color.FgRed + "terminal error: " + err.Error())
+	}
+
+	e.Redisplay()
+}
+
+func (e *Engine) printAbove(msg message) (n int, err error) {
+	row := e.promptRowBelow(msg)
+
+	if msg.transient {
+		// Go back to the beginning of the line/prompt, and clear
+		// everything below (prompt/line/hints/completions).
+		e.CursorToLineStart()
+		e.term.MoveCursorBackwards(e.term.Width())
+		e.term.MoveCursorUp(e.prompt.PrimaryUsed())
+	} else {
+		// Go back to the last line of the input line,
+		// and clear everything below (hints and completions).
+		e.CursorBelowLine()
+		e.term.MoveCursorBackwards(e.term.Width())
+	}
+
+	e.term.Print(term.ClearScreenBelow)
+
+	// The terminal might be in raw mode, so newlines
+	// do not move the cursor to the first column.
+	if _, err = e.term.Write([]byte(strings.ReplaceAll(msg.text, "\n", term.NewlineReturn))); err == nil {
+		n = len(msg.text)
+	}
+
+	// Redisplay the prompt, input line and active helpers. The cursor
+	// position is not queried, since the shell might be reading keys.
+	e.PrintPrimaryPrompt()
+	e.printedRow, e.printed = row, true
+	e.Refresh()
+
+	return n, err
+}
+
+// promptRowBelow returns the terminal row on which the last line of the primary prompt is
+// printed below a message, computed from the row of the last frame, or -1 if it is unknown.
+func (e *Engine) promptRowBelow(msg message) int {
+	top := e.frameTop()
+	if top == -1 {
+		return -1
+	}
+
+	width, height := e.term.Width(), e.term.Height()
+
+	row := top + e.lineRows + 1
+	if msg.transient {
+		row = max(top-e.prompt.PrimaryUsed(), 1)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(msg.text, "\n"), "\n") {
+		col, rows := strutil.LineSpan([]rune(line), 0, 0, width)
+		if col > 0 || rows == 0 {
+			rows++
+		}
+
+		row += rows
+	}
+
+	return min(row+e.prompt.PrimaryUsed(), height)
+}
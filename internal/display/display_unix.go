@@ -13,14 +13,39 @@ import (
 func WatchResize(eng *Engine) chan<- bool {
 	done := make(chan bool, 1)
 
-	resizeChannel := make(chan os.Signal)
+	resizeChannel := make(chan os.Signal, 1)
 	signal.Notify(resizeChannel, syscall.SIGWINCH)
 
 	go func() {
 		for {
 			select {
 			case <-resizeChannel:
-				eng.Refresh()
+				eng.refreshAsync()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return done
+}
+
+// WatchResume restores the terminal with the restore function (in raw mode, with the modes
+// used by the shell), and redisplays the interface, when the process is resumed (SIGCONT)
+// after having been suspended, like with job control.
+func WatchResume(eng *Engine, restore func() error) chan<- bool {
+	done := make(chan bool, 1)
+
+	resumeChannel := make(chan os.Signal, 1)
+	signal.Notify(resumeChannel, syscall.SIGCONT)
+
+	go func() {
+		defer signal.Stop(resumeChannel)
+
+		for {
+			select {
+			case <-resumeChannel:
+				eng.resume(restore)
 			case <-done:
 				return
 			}
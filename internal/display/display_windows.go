@@ -3,6 +3,11 @@
 
 package display
 
+// WatchResume does nothing on Windows, where processes cannot be suspended.
+func WatchResume(eng *Engine, restore func() error) chan<- bool {
+	return make(chan<- bool)
+}
+
 // WatchResize redisplays the interface on terminal resize events on Windows.
 // Currently not implemented, see related issue in repo: too buggy right now.
 func WatchResize(eng *Engine) chan<- bool {
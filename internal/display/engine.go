@@ -2,6 +2,8 @@ package display
 
 import (
 	"fmt"
+	"strings"
+	"sync"
 
 	"github.com/reeflective/readline/inputrc"
 	"github.com/reeflective/readline/internal/color"
@@ -23,6 +25,8 @@ var (
 type Engine struct {
 	// Operating parameters
 	highlighter    func(line []rune) string
+	secret         bool
+	mask           rune
 	startCols      int
 	startRows      int
 	lineCol        int
@@ -32,8 +36,20 @@ type Engine struct {
 	hintRows       int
 	compRows       int
 	primaryPrinted bool
+	view           viewport
+
+	// Differential rendering
+	frame      *frame // Last frame displayed on the terminal.
+	next       *frame // Frame being rendered, if any.
+	restore    func() // Restores the terminal output once the frame is rendered.
+	frameRow   int    // Terminal row on which the frame being rendered starts.
+	frameWrite uint64 // Terminal changes count once the last frame was displayed.
+	frameSize  int    // Terminal height once the last frame was displayed.
+	printed    bool   // The frame is rendered below a message: its row is known.
+	printedRow int    // Terminal row of the last prompt line below the message, or -1.
 
 	// UI components
+	term      *term.Terminal
 	keys      *core.Keys
 	line      *core.Line
 	suggested core.Line
@@ -44,11 +60,20 @@ type Engine struct {
 	hint      *ui.Hint
 	completer *completion.Engine
 	opts      *inputrc.Config
+
+	// Asynchronous messages
+	mutex     sync.Mutex   // Synchronizes the display with messages from other goroutines.
+	active    bool         // The shell is reading a line.
+	waiting   bool         // The shell is waiting for keys, and not using the display.
+	suspended bool         // Other programs are using the terminal.
+	queued    []message    // Messages to print once the shell waits for keys.
+	resumed   func() error // Restores the terminal once the shell waits for keys, if resumed.
 }
 
 // NewEngine is a required constructor for the display engine.
-func NewEngine(k *core.Keys, s *core.Selection, h *history.Sources, p *ui.Prompt, i *ui.Hint, c *completion.Engine, opts *inputrc.Config) *Engine {
+func NewEngine(t *term.Terminal, k *core.Keys, s *core.Selection, h *history.Sources, p *ui.Prompt, i *ui.Hint, c *completion.Engine, opts *inputrc.Config) *Engine {
 	return &Engine{
+		term:      t,
 		keys:      k,
 		selection: s,
 		histories: h,
@@ -66,17 +91,30 @@ func Init(e *Engine, highlighter func([]rune) string) {
 	e.highlighter = highlighter
 }
 
+// SetSecret is used when the shell reads a secret (like a password): while set,
+// each character of the input line is displayed as the mask rune, or nothing at all
+// is displayed if the latter is zero. No highlighting nor suggestion is displayed.
+func SetSecret(e *Engine, secret bool, mask rune) {
+	e.secret = secret
+	e.mask = mask
+}
+
 // Refresh recomputes and redisplays the entire readline interface, except
 // the first lines of the primary prompt when the latter is a multiline one.
+// The interface is rendered offscreen, and only the parts of it which have
+// changed since the last refresh are displayed (see the frame type).
 func (e *Engine) Refresh() {
-	fmt.Print(term.HideCursor)
+	e.renderFrame()
+	defer e.displayFrame()
+
+	e.term.Print(term.HideCursor)
 
 	// Go back to the first column, and if the primary prompt
 	// was not printed yet, back up to the line's beginning row.
-	term.MoveCursorBackwards(term.GetWidth())
+	e.term.MoveCursorBackwards(e.term.Width())
 
 	if !e.primaryPrinted {
-		term.MoveCursorUp(e.cursorRow)
+		e.term.MoveCursorUp(e.cursorRow)
 	}
 
 	// Print either all or the last line of the prompt.
@@ -94,7 +132,19 @@ func (e *Engine) Refresh() {
 	// Go back to the start of the line, then to cursor.
 	e.cursorHintToLineStart()
 	e.lineStartToCursorPos()
-	fmt.Print(term.ShowCursor)
+	e.term.Print(term.ShowCursor)
+}
+
+// Redisplay displays the whole interface again, starting with the primary prompt on
+// the first column of the current terminal row, instead of only the changes since the
+// last refresh: this is needed when other programs have used the terminal, like when
+// the shell process has been suspended and resumed.
+func (e *Engine) Redisplay() {
+	e.frame = nil
+
+	e.term.MoveCursorBackwards(e.term.Width())
+	e.PrintPrimaryPrompt()
+	e.Refresh()
 }
 
 // PrintPrimaryPrompt redraws the primary prompt.
@@ -108,12 +158,12 @@ func (e *Engine) PrintPrimaryPrompt() {
 // ClearHelpers clears the hint and completion sections below the line.
 func (e *Engine) ClearHelpers() {
 	e.CursorBelowLine()
-	fmt.Print(term.ClearScreenBelow)
+	e.term.Print(term.ClearScreenBelow)
 
-	term.MoveCursorUp(1)
-	term.MoveCursorUp(e.lineRows)
-	term.MoveCursorDown(e.cursorRow)
-	term.MoveCursorForwards(e.cursorCol)
+	e.term.MoveCursorUp(1)
+	e.term.MoveCursorUp(e.lineRows)
+	e.term.MoveCursorDown(e.cursorRow)
+	e.term.MoveCursorForwards(e.cursorCol)
 }
 
 // ResetHelpers cancels all active hints and completions.
@@ -131,44 +181,83 @@ func (e *Engine) AcceptLine() {
 
 	e.computeCoordinates(false)
 
-	// Go back to the end of the non-suggested line.
-	term.MoveCursorBackwards(term.GetWidth())
-	term.MoveCursorDown(e.lineRows)
-	term.MoveCursorForwards(e.lineCol)
-	fmt.Print(term.ClearScreenBelow)
+	if e.view.top > 0 || e.view.bottom < e.view.lines-1 {
+		// Display all lines of a scrolled line, so that they
+		// are kept in the terminal scrollback buffer.
+		e.view.top, e.view.bottom = 0, e.view.lines-1
+		e.suggested = *e.line
+
+		e.term.Print(term.ClearScreenBelow)
+		e.computeLineCoordinates(*e.line)
+		e.displayLine()
+	} else {
+		// Go back to the end of the non-suggested line.
+		e.term.MoveCursorBackwards(e.term.Width())
+		e.term.MoveCursorDown(e.lineRows)
+		e.term.MoveCursorForwards(e.lineCol)
+	}
+
+	e.term.Print(term.ClearScreenBelow)
 
 	// Reprint the right-side prompt if it's not a tooltip one.
 	e.prompt.RightPrint(e.lineCol, false)
 
 	// Go below this non-suggested line and clear everything.
-	term.MoveCursorBackwards(term.GetWidth())
-	fmt.Print(term.NewlineReturn)
+	e.term.MoveCursorBackwards(e.term.Width())
+	e.term.Print(term.NewlineReturn)
+}
+
+// MarkOutput marks, with an OSC 133 sequence, the start of the command output
+// for the terminal, once the line accepted is displayed as it will stay.
+// Nothing is printed unless the enable-shell-integration option is on.
+func (e *Engine) MarkOutput() {
+	if e.opts.GetBool("enable-shell-integration") {
+		e.term.Print(term.OutputStart)
+	}
+}
+
+// MarkCommandEnd marks, with an OSC 133 sequence, the end of the command output
+// for the terminal, along with the exit status of the command.
+// Nothing is printed unless the enable-shell-integration option is on.
+func (e *Engine) MarkCommandEnd(status int) {
+	if e.opts.GetBool("enable-shell-integration") {
+		e.term.Print(fmt.Sprintf(term.CommandEnd, status))
+	}
+}
+
+// ClearLine erases the primary prompt, the input line and all helpers
+// below it, and puts the cursor where the primary prompt started.
+func (e *Engine) ClearLine() {
+	e.CursorToLineStart()
+	e.term.MoveCursorUp(e.prompt.PrimaryUsed())
+	e.term.MoveCursorBackwards(e.term.Width())
+	e.term.Print(term.ClearScreenBelow)
 }
 
 // RefreshTransient goes back to the first line of the input buffer
 // and displays the transient prompt, then redisplays the input line.
 func (e *Engine) RefreshTransient() {
-	if !e.opts.GetBool("prompt-transient") {
+	if !e.opts.GetBool("prompt-transient") || e.secret {
 		return
 	}
 
 	// Go to the beginning of the primary prompt.
 	e.CursorToLineStart()
-	term.MoveCursorUp(e.prompt.PrimaryUsed())
+	e.term.MoveCursorUp(e.prompt.PrimaryUsed())
 
 	// And redisplay the transient/primary/line.
 	e.prompt.TransientPrint()
 	e.displayLine()
-	fmt.Print(term.NewlineReturn)
+	e.term.Print(term.NewlineReturn)
 }
 
 // CursorToLineStart moves the cursor just after the primary prompt.
 // This function should only be called when the cursor is on its
 // "cursor" position on the input line.
 func (e *Engine) CursorToLineStart() {
-	term.MoveCursorBackwards(e.cursorCol)
-	term.MoveCursorUp(e.cursorRow)
-	term.MoveCursorForwards(e.startCols)
+	e.term.MoveCursorBackwards(e.cursorCol)
+	e.term.MoveCursorUp(e.cursorRow)
+	e.term.MoveCursorForwards(e.startCols)
 }
 
 // CursorBelowLine moves the cursor to the leftmost
@@ -176,104 +265,221 @@ func (e *Engine) CursorToLineStart() {
 // This function should only be called when the cursor
 // is on its "cursor" position on the input line.
 func (e *Engine) CursorBelowLine() {
-	term.MoveCursorUp(e.cursorRow)
-	term.MoveCursorDown(e.lineRows)
-	fmt.Print(term.NewlineReturn)
+	e.term.MoveCursorUp(e.cursorRow)
+	e.term.MoveCursorDown(e.lineRows)
+	e.term.Print(term.NewlineReturn)
 }
 
 // lineStartToCursorPos can be used if the cursor is currently
 // at the very start of the input line, that is just after the
 // last character of the prompt.
 func (e *Engine) lineStartToCursorPos() {
-	term.MoveCursorDown(e.cursorRow)
-	term.MoveCursorBackwards(term.GetWidth())
-	term.MoveCursorForwards(e.cursorCol)
+	e.term.MoveCursorDown(e.cursorRow)
+	e.term.MoveCursorBackwards(e.term.Width())
+	e.term.MoveCursorForwards(e.cursorCol)
 }
 
 // cursor is on the line below the last line of input.
 func (e *Engine) cursorHintToLineStart() {
-	term.MoveCursorUp(1)
-	term.MoveCursorUp(e.lineRows - e.cursorRow)
+	e.term.MoveCursorUp(1)
+	e.term.MoveCursorUp(e.lineRows - e.cursorRow)
 	e.CursorToLineStart()
 }
 
+// renderFrame starts rendering a new frame, in which all output is captured
+// until it is displayed. The frame starts on the row of the last prompt line.
+func (e *Engine) renderFrame() {
+	row := e.cursorRow
+	if e.primaryPrinted {
+		row = 0
+	}
+
+	// The terminal row on which the shell is displayed is needed for helpers: it is
+	// known if the frame is displayed below a message printed asynchronously, or if
+	// the last frame is still on the screen, with the cursor where it has left it.
+	// Otherwise (like after a resize, a resume or a clear), the cursor is queried.
+	var col, termRow int
+
+	switch {
+	case e.printed:
+		col, termRow = e.prompt.LastUsed()+1, e.printedRow
+	case e.onScreen() && e.frameTop() != -1:
+		col, termRow = e.frame.x+1, e.frameTop()+e.frame.y
+	default:
+		col, termRow = e.keys.GetCursorPos()
+	}
+
+	e.printed = false
+
+	e.frameRow = termRow - row
+	if termRow == -1 {
+		e.frameRow = -1
+	}
+
+	e.next = newFrame(e.term.Width(), col-1, row)
+	e.restore = e.term.Redirect(e.next)
+}
+
+// displayFrame displays the frame just rendered: only its changes are displayed
+// if the last frame is still on the screen as is, or the whole frame otherwise (like
+// on terminals whose cursor cannot be moved, on which the frame is simply rewritten).
+func (e *Engine) displayFrame() {
+	e.restore()
+
+	prev, next := e.frame, e.next
+	height := e.term.Height()
+
+	valid := e.onScreen() && len(prev.rows) <= height && len(next.rows) <= height
+	e.frame, e.next = next, nil
+
+	output := string(next.raw)
+	if valid {
+		output = next.diff(prev)
+	}
+
+	if output != "" && e.term.Features().SyncOutput {
+		output = term.SynchronizedOutputOn + output + term.SynchronizedOutputOff
+	}
+
+	e.term.Print(output)
+	e.frameWrite, e.frameSize = e.term.Changes(), height
+}
+
+// onScreen returns true if the last frame displayed is still on the screen as is, with the
+// cursor where it has left it: nothing has been written to the terminal since, the latter
+// has not been resized, and its cursor can be moved (otherwise, frames are rewritten).
+func (e *Engine) onScreen() bool {
+	return e.frame != nil && e.term.Changes() == e.frameWrite && e.term.Features().CursorControl &&
+		e.frame.width == e.term.Width() && e.frameSize == e.term.Height()
+}
+
 func (e *Engine) computeCoordinates(suggested bool) {
 	// Get the new input line and auto-suggested one.
 	e.line, e.cursor = e.completer.Line()
-	if e.completer.IsInserting() {
+
+	switch {
+	case e.secret:
+		e.line, e.cursor = e.maskLine(e.line, e.cursor)
 		e.suggested = *e.line
-	} else {
+	case e.completer.IsInserting():
+		e.suggested = *e.line
+	default:
 		e.suggested = e.histories.Suggest(e.line)
 	}
 
 	// Get the position of the line's beginning by querying
-	// the terminal for the cursor position.
-	e.startCols, e.startRows = e.keys.GetCursorPos()
+	// the terminal for the cursor position, or in the frame.
+	startCols := e.startCols
+
+	if e.next != nil {
+		e.startCols, e.startRows = e.next.x+1, e.frameRow+e.next.y
+
+		if e.frameRow == -1 {
+			e.startRows = -1
+		}
+	} else {
+		e.startCols, e.startRows = e.keys.GetCursorPos()
+	}
 
 	if e.startCols > 0 {
 		e.startCols--
 	}
 
-	// Cursor position might be misleading if invalid (negative).
-	if e.startCols == -1 {
+	// Cursor position might be misleading if invalid (negative), like when reading
+	// keys has been aborted: the line starts where it did on the last refresh, if any.
+	switch {
+	case e.startCols == -1 && e.frame != nil:
+		e.startCols = startCols
+	case e.startCols == -1:
 		e.startCols = e.prompt.LastUsed()
 	}
 
-	e.cursorCol, e.cursorRow = core.CoordinatesCursor(e.cursor, e.startCols)
+	e.cursorCol, e.cursorRow = core.CoordinatesCursor(e.cursor, e.startCols, e.term.Width())
 
-	// Get the number of rows used by the line, and the end line X pos.
+	line := *e.line
 	if e.opts.GetBool("history-autosuggest") && suggested {
-		e.lineCol, e.lineRows = core.CoordinatesLine(&e.suggested, e.startCols)
-	} else {
-		e.lineCol, e.lineRows = core.CoordinatesLine(e.line, e.startCols)
+		line = e.suggested
 	}
 
+	// Only display the part of the line fitting on the screen.
+	e.computeViewport(line)
+	e.computeLineCoordinates(line)
+
 	e.primaryPrinted = false
 }
 
+// computeLineCoordinates gets the number of rows used by the line
+// as displayed in the viewport, and the end line X pos.
+func (e *Engine) computeLineCoordinates(line core.Line) {
+	displayed := core.Line([]rune(e.view.display(strutil.FormatTabs(string(line)))))
+	e.lineCol, e.lineRows = core.CoordinatesLine(&displayed, e.startCols, e.term.Width())
+}
+
 func (e *Engine) displayLine() {
 	var line string
 
 	// Apply user-defined highlighter to the input line.
-	if e.highlighter != nil {
+	// A secret line is masked, and never highlighted.
+	switch {
+	case e.secret:
+		line = string(*e.line)
+	case e.highlighter != nil:
 		line = e.highlighter(*e.line)
-	} else {
+	default:
 		line = string(*e.line)
 	}
 
 	// Highlight matching parenthesis
-	if e.opts.GetBool("blink-matching-paren") {
+	if e.opts.GetBool("blink-matching-paren") && !e.secret {
 		core.HighlightMatchers(e.selection)
 		defer core.ResetMatchers(e.selection)
 	}
 
 	// Apply visual selections highlighting if any
-	line = e.highlightLine([]rune(line), *e.selection)
+	if !e.secret {
+		line = e.highlightLine([]rune(line), *e.selection)
+	}
 
 	// Get the subset of the suggested line to print.
 	if len(e.suggested) > e.line.Len() && e.opts.GetBool("history-autosuggest") {
 		line += color.Dim + color.Fmt(color.Fg+"242") + string(e.suggested[e.line.Len():]) + color.Reset
 	}
 
-	// Format tabs as spaces, for consistent display
-	line = strutil.FormatTabs(line) + term.ClearLineAfter
+	// Format tabs as spaces, for consistent display,
+	// and only display the lines in the viewport.
+	line = e.view.display(strutil.FormatTabs(line)) + term.ClearLineAfter
 
 	// And display the line.
 	e.suggested.Set([]rune(line)...)
-	core.DisplayLine(&e.suggested, e.startCols)
+	core.DisplayLine(e.term, &e.suggested, e.startCols)
 
 	// Adjust the cursor if the line fits exactly in the terminal width.
 	if e.lineCol == 0 {
-		fmt.Print(term.NewlineReturn)
-		fmt.Print(term.ClearLineAfter)
+		e.term.Print(term.NewlineReturn)
+		e.term.Print(term.ClearLineAfter)
 	}
 }
 
+// maskLine returns a copy of a secret line in which each character is replaced
+// with the mask rune (or an empty line if there is none), and its cursor.
+func (e *Engine) maskLine(line *core.Line, cursor *core.Cursor) (*core.Line, *core.Cursor) {
+	masked := new(core.Line)
+
+	if e.mask != 0 {
+		masked.Set([]rune(strings.Repeat(string(e.mask), line.Len()))...)
+	}
+
+	maskedCursor := core.NewCursor(masked)
+	maskedCursor.Set(cursor.Pos())
+
+	return masked, maskedCursor
+}
+
 // displayHelpers renders the hint and completion sections.
 // It assumes that the cursor is on the last line of input,
 // and goes back to this same line after displaying this.
 func (e *Engine) displayHelpers() {
-	fmt.Print(term.NewlineReturn)
+	e.term.Print(term.NewlineReturn)
 
 	// Recompute completions and hints if autocompletion is on.
 	e.completer.Autocomplete()
@@ -285,15 +491,15 @@ func (e *Engine) displayHelpers() {
 	e.compRows = completion.Coordinates(e.completer)
 
 	// Go back to the first line below the input line.
-	term.MoveCursorBackwards(term.GetWidth())
-	term.MoveCursorUp(e.compRows)
-	term.MoveCursorUp(ui.CoordinatesHint(e.hint))
+	e.term.MoveCursorBackwards(e.term.Width())
+	e.term.MoveCursorUp(e.compRows)
+	e.term.MoveCursorUp(ui.CoordinatesHint(e.hint))
 }
 
 // AvailableHelperLines returns the number of lines available below the hint section.
 // It returns half the terminal space if we currently have less than 1/3rd of it below.
 func (e *Engine) AvailableHelperLines() int {
-	termHeight := term.GetLength()
+	termHeight := e.term.Height()
 	compLines := termHeight - e.startRows - e.lineRows - e.hintRows
 
 	if compLines < (termHeight / oneThirdTerminalHeight) {
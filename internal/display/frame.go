@@ -0,0 +1,426 @@
+package display
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rivo/uniseg"
+
+	"github.com/reeflective/readline/internal/term"
+)
+
+// frame is a model of the screen region used by the shell interface, starting
+// at the first column of the row on which the last line of the prompt is printed.
+// A frame is rendered offscreen, by interpreting the output of the display engine
+// like a terminal would, so that it can be compared with the previously displayed
+// one: only the cells that have changed are then redisplayed on the terminal.
+type frame struct {
+	width       int
+	rows        [][]cell
+	x, y        int
+	savedX      int
+	savedY      int
+	wrapPending bool   // The last column has been written, but the cursor is not wrapped yet.
+	style       string // Current graphic rendition, as the SGR sequences since the last reset.
+	parser      []byte // Incomplete escape sequence or UTF-8 character.
+	raw         []byte // All output written to the frame, to be displayed as is if needed.
+}
+
+// cell is a printable grapheme on the screen, or nothing if it is part of a wide one.
+type cell struct {
+	text  string
+	style string
+}
+
+var blankCell = cell{text: " "}
+
+// newFrame returns a blank frame, with the cursor at the given position.
+func newFrame(width, x, y int) *frame {
+	f := &frame{width: max(width, 1)}
+	f.moveTo(x, y)
+
+	return f
+}
+
+// Write interprets the output of the display engine, like a terminal would.
+func (f *frame) Write(p []byte) (n int, err error) {
+	f.raw = append(f.raw, p...)
+	f.parser = append(f.parser, p...)
+
+	for len(f.parser) > 0 {
+		used := f.parse(f.parser)
+		if used == 0 {
+			break
+		}
+
+		f.parser = f.parser[used:]
+	}
+
+	return len(p), nil
+}
+
+// diff returns the output updating the screen from the previous frame, which
+// must be displayed with the cursor at its position, to this one. Each changed
+// span of cells is redisplayed, and the cursor is then moved to its position.
+func (f *frame) diff(prev *frame) string {
+	var out strings.Builder
+
+	// The rows of the previous frame are on the screen: others
+	// are added below them by printing newlines, which scrolls
+	// the screen up if needed.
+	x, y, rows := prev.x, prev.y, len(prev.rows)
+
+	moveTo := func(col, row int) {
+		switch {
+		case row < y:
+			fmt.Fprintf(&out, "\x1b[%dA\r", y-row)
+		case row > y && row < rows:
+			fmt.Fprintf(&out, "\x1b[%dB\r", row-y)
+		case row > y:
+			if y < rows-1 {
+				fmt.Fprintf(&out, "\x1b[%dB", rows-1-y)
+			}
+
+			out.WriteString(strings.Repeat(term.NewlineReturn, row-(rows-1)))
+			rows = row + 1
+		default:
+			out.WriteString("\r")
+		}
+
+		if col > 0 {
+			fmt.Fprintf(&out, "\x1b[%dC", col)
+		}
+
+		x, y = col, row
+	}
+
+	for row := 0; row < len(f.rows); row++ {
+		start, end := f.changed(prev, row)
+		if start == -1 {
+			continue
+		}
+
+		moveTo(start, row)
+		f.printCells(&out, row, start, end)
+	}
+
+	// Erase the rows not used anymore.
+	if len(prev.rows) > len(f.rows) {
+		moveTo(0, len(f.rows))
+		out.WriteString(term.ClearScreenBelow)
+	}
+
+	if out.Len() == 0 && x == f.x && y == f.y {
+		return ""
+	}
+
+	moveTo(f.x, f.y)
+
+	return term.HideCursor + out.String() + term.ShowCursor
+}
+
+// changed returns the first and last columns of a row whose cells differ between the
+// previous frame and this one, adjusted so as not to split wide graphemes, or -1 if none.
+// The end is the width of the terminal if the row must be erased after the start.
+func (f *frame) changed(prev *frame, row int) (start, end int) {
+	start, end = -1, -1
+
+	for col := 0; col < f.width; col++ {
+		if f.cell(col, row) != prev.cell(col, row) {
+			if start == -1 {
+				start = col
+			}
+
+			end = col
+		}
+	}
+
+	if start == -1 {
+		return -1, -1
+	}
+
+	for start > 0 && (f.cell(start, row).text == "" || prev.cell(start, row).text == "") {
+		start--
+	}
+
+	for end < f.width-1 && (f.cell(end+1, row).text == "" || prev.cell(end+1, row).text == "") {
+		end++
+	}
+
+	// Erase the rest of the row if it is blank.
+	if end >= f.lastUsed(row) {
+		end = f.width
+	}
+
+	return start, end
+}
+
+// printCells prints the cells of a row, from start to end (included): if the end
+// is the width of the terminal, the cells are printed until the last non-blank one,
+// and the rest of the row is erased.
+func (f *frame) printCells(out *strings.Builder, row, start, end int) {
+	erase := end == f.width
+	if erase {
+		end = f.lastUsed(row)
+	}
+
+	// The current style of the terminal is unknown.
+	style := "\x00"
+
+	for col := start; col <= end; col++ {
+		cell := f.cell(col, row)
+		if cell.text == "" {
+			continue
+		}
+
+		if cell.style != style {
+			out.WriteString("\x1b[0m" + cell.style)
+			style = cell.style
+		}
+
+		out.WriteString(cell.text)
+	}
+
+	if style != "" {
+		out.WriteString("\x1b[0m")
+	}
+
+	// Erasing from the last column would erase its cell.
+	if erase && end < f.width-1 {
+		out.WriteString(term.ClearLineAfter)
+	}
+}
+
+// lastUsed returns the last non-blank column of a row, or -1 if the row is blank.
+func (f *frame) lastUsed(row int) int {
+	for col := f.width - 1; col >= 0; col-- {
+		if f.cell(col, row) != blankCell {
+			return col
+		}
+	}
+
+	return -1
+}
+
+func (f *frame) cell(col, row int) cell {
+	if row >= len(f.rows) || col >= len(f.rows[row]) {
+		return blankCell
+	}
+
+	return f.rows[row][col]
+}
+
+// parse interprets the first character or sequence of buf, and returns
+// the number of bytes used, or 0 if the sequence is not complete yet.
+func (f *frame) parse(buf []byte) int {
+	switch buf[0] {
+	case '\x1b':
+		return f.parseEscape(buf)
+	case '\r':
+		f.x = 0
+		f.wrapPending = false
+	case '\n', '\v', '\f':
+		f.moveTo(f.x, f.y+1)
+	case '\b':
+		f.moveTo(f.x-1, f.y)
+	case '\t':
+		f.moveTo((f.x/8+1)*8, f.y)
+	default:
+		if buf[0] < ' ' || buf[0] == 0x7f {
+			return 1
+		}
+
+		return f.parseGrapheme(buf)
+	}
+
+	return 1
+}
+
+// parseGrapheme prints the first grapheme cluster of buf, if complete.
+func (f *frame) parseGrapheme(buf []byte) int {
+	end := len(buf)
+
+	for i, char := range buf {
+		if char < ' ' || char == 0x7f {
+			end = i
+			break
+		}
+	}
+
+	cluster, rest, width, _ := uniseg.FirstGraphemeCluster(buf[:end], -1)
+
+	// The cluster might continue in a subsequent write.
+	if len(rest) == 0 && end == len(buf) && strings.ToValidUTF8(string(cluster), "") != string(cluster) {
+		return 0
+	}
+
+	f.print(string(cluster), width)
+
+	return len(cluster)
+}
+
+// parseEscape interprets an escape sequence: other sequences than
+// those used by the display engine are parsed, but have no effect.
+func (f *frame) parseEscape(buf []byte) int {
+	if len(buf) < 2 {
+		return 0
+	}
+
+	switch buf[1] {
+	case '[':
+		return f.parseCSI(buf)
+	case ']', 'P', '_', '^':
+		for i := 2; i < len(buf); i++ {
+			if buf[i] == '\a' || (buf[i] == '\\' && buf[i-1] == '\x1b') {
+				return i + 1
+			}
+		}
+
+		return 0
+	case '7':
+		f.savedX, f.savedY = f.x, f.y
+	case '8':
+		f.moveTo(f.savedX, f.savedY)
+	}
+
+	return 2
+}
+
+// parseCSI interprets a Control Sequence Introducer sequence.
+func (f *frame) parseCSI(buf []byte) int {
+	end := 2
+	for end < len(buf) && (buf[end] < 0x40 || buf[end] > 0x7e) {
+		end++
+	}
+
+	if end == len(buf) {
+		return 0
+	}
+
+	params := string(buf[2:end])
+
+	// Private modes and sequences with intermediate bytes.
+	if strings.TrimLeft(params, "0123456789;:") != "" {
+		return end + 1
+	}
+
+	param := func(def int) int {
+		value, err := strconv.Atoi(strings.Split(params, ";")[0])
+		if err != nil || value == 0 {
+			return def
+		}
+
+		return value
+	}
+
+	switch buf[end] {
+	case 'A':
+		f.moveTo(f.x, f.y-param(1))
+	case 'B':
+		f.moveTo(f.x, f.y+param(1))
+	case 'C':
+		f.moveTo(f.x+param(1), f.y)
+	case 'D':
+		f.moveTo(f.x-param(1), f.y)
+	case 'G':
+		f.moveTo(param(1)-1, f.y)
+	case 'K':
+		f.eraseLine(param(0))
+	case 'J':
+		f.eraseBelow()
+	case 'm':
+		f.setStyle(params)
+	}
+
+	return end + 1
+}
+
+// setStyle updates the current graphic rendition.
+func (f *frame) setStyle(params string) {
+	switch {
+	case params == "" || params == "0":
+		f.style = ""
+	case strings.HasPrefix(params, "0;"):
+		f.style = "\x1b[" + params[2:] + "m"
+	default:
+		f.style += "\x1b[" + params + "m"
+	}
+}
+
+// print writes a grapheme cluster at the cursor position.
+func (f *frame) print(cluster string, width int) {
+	// Zero-width characters combine with the previous cell.
+	if width == 0 {
+		previous := f.x - 1
+		if f.wrapPending {
+			previous = f.x
+		}
+
+		if previous >= 0 {
+			f.rows[f.y][previous].text += cluster
+		}
+
+		return
+	}
+
+	if f.wrapPending || f.x+width > f.width {
+		f.moveTo(0, f.y+1)
+	}
+
+	f.rows[f.y][f.x] = cell{text: cluster, style: f.style}
+	for i := 1; i < width && f.x+i < f.width; i++ {
+		f.rows[f.y][f.x+i] = cell{style: f.style}
+	}
+
+	f.x += width
+
+	// Like most terminals, defer wrapping until the next character.
+	if f.x >= f.width {
+		f.x = f.width - 1
+		f.wrapPending = true
+	}
+}
+
+// moveTo moves the cursor, adding the rows needed to reach it.
+func (f *frame) moveTo(x, y int) {
+	f.x = min(max(x, 0), f.width-1)
+	f.y = max(y, 0)
+	f.wrapPending = false
+
+	for len(f.rows) <= f.y {
+		row := make([]cell, f.width)
+		for i := range row {
+			row[i] = blankCell
+		}
+
+		f.rows = append(f.rows, row)
+	}
+}
+
+func (f *frame) eraseLine(mode int) {
+	row := f.rows[f.y]
+
+	switch mode {
+	case 0:
+		f.erase(row[f.x:])
+	case 1:
+		f.erase(row[:f.x+1])
+	case 2:
+		f.erase(row)
+	}
+}
+
+func (f *frame) eraseBelow() {
+	f.eraseLine(0)
+
+	for _, row := range f.rows[f.y+1:] {
+		f.erase(row)
+	}
+}
+
+func (f *frame) erase(cells []cell) {
+	for i := range cells {
+		cells[i] = blankCell
+	}
+}
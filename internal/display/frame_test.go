@@ -0,0 +1,95 @@
+package display
+
+import "testing"
+
+func renderTestFrame(width int, output string) *frame {
+	f := newFrame(width, 0, 0)
+	f.Write([]byte(output))
+
+	return f
+}
+
+func TestFrame_diff(t *testing.T) {
+	tests := []struct {
+		name string
+		prev string
+		next string
+		want string
+	}{
+		{
+			name: "Unchanged frame",
+			prev: "> ls",
+			next: "> ls",
+			want: "",
+		},
+		{
+			name: "Cursor move only",
+			prev: "> ls",
+			next: "> ls\x1b[1D",
+			want: "\x1b[?25l\r\x1b[3C\x1b[?25h",
+		},
+		{
+			name: "Character appended",
+			prev: "> ls",
+			next: "> ls -",
+			want: "\x1b[?25l\r\x1b[5C\x1b[0m-\x1b[0K\r\x1b[6C\x1b[?25h",
+		},
+		{
+			name: "Character deleted",
+			prev: "> ls -l",
+			next: "> ls -",
+			want: "\x1b[?25l\r\x1b[6C\x1b[0m\x1b[0K\r\x1b[6C\x1b[?25h",
+		},
+		{
+			name: "Styled span changed",
+			prev: "> \x1b[31mls\x1b[0m",
+			next: "> \x1b[32mls\x1b[0m",
+			want: "\x1b[?25l\r\x1b[2C\x1b[0m\x1b[32mls\x1b[0m\x1b[0K\r\x1b[4C\x1b[?25h",
+		},
+		{
+			name: "Helper row added below",
+			prev: "> ls",
+			next: "> ls\r\nhint\x1b[1A\r\x1b[4C",
+			want: "\x1b[?25l\r\n\x1b[0mhint\x1b[0K\x1b[1A\r\x1b[4C\x1b[?25h",
+		},
+		{
+			name: "Helper rows removed",
+			prev: "> ls\r\nhint\r\nmore\x1b[2A\r\x1b[4C",
+			next: "> ls",
+			want: "\x1b[?25l\x1b[1B\r\x1b[0J\x1b[1A\r\x1b[4C\x1b[?25h",
+		},
+		{
+			name: "Wide grapheme replaced",
+			prev: "> 日本",
+			next: "> 日x",
+			want: "\x1b[?25l\r\x1b[4C\x1b[0mx\x1b[0K\r\x1b[5C\x1b[?25h",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			prev := renderTestFrame(20, test.prev)
+			next := renderTestFrame(20, test.next)
+
+			got := next.diff(prev)
+			if got != test.want {
+				t.Errorf("frame.diff() = %q, want %q", got, test.want)
+			}
+
+			// The previous frame updated with the diff must be the new one.
+			screen := renderTestFrame(20, test.prev+got)
+
+			for row := 0; row < max(len(screen.rows), len(next.rows)); row++ {
+				for col := 0; col < 20; col++ {
+					if got, want := screen.cell(col, row), next.cell(col, row); got != want {
+						t.Errorf("frame.diff() displays %q at (%d, %d), want %q", got, col, row, want)
+					}
+				}
+			}
+
+			if screen.x != next.x || screen.y != next.y {
+				t.Errorf("frame.diff() cursor = (%d, %d), want (%d, %d)", screen.x, screen.y, next.x, next.y)
+			}
+		})
+	}
+}
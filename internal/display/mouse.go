@@ -0,0 +1,53 @@
+package display
+
+import "github.com/reeflective/readline/internal/core"
+
+// LinePosition returns the position in the input line displayed at the given terminal
+// coordinates (starting at 1, like those of mouse events), or false if the line is not
+// displayed on this row. Clicking after the end of a row gives its last position.
+func (e *Engine) LinePosition(x, y int) (pos int, found bool) {
+	row := y - e.frameTop()
+	if e.frameTop() == -1 || e.line == nil || row < 0 || row > e.lineRows {
+		return -1, false
+	}
+
+	pos = -1
+	cursor := core.NewCursor(e.line)
+
+	for {
+		col, line := e.cursorCoordinates(cursor)
+		if line == row && (pos == -1 || col <= x-1) {
+			pos = cursor.Pos()
+		}
+
+		if cursor.Pos() >= e.line.Len() {
+			break
+		}
+
+		cursor.Inc()
+	}
+
+	return pos, pos != -1
+}
+
+// CompletionRow returns the row of the completions displayed at the given terminal
+// row (starting at 1, like those of mouse events), or false if it is above them:
+// whether a candidate is displayed on this row is known by the completion engine.
+func (e *Engine) CompletionRow(y int) (row int, found bool) {
+	row = y - e.frameTop() - e.lineRows - 1 - e.hintRows
+	if e.frameTop() == -1 || row < 0 {
+		return -1, false
+	}
+
+	return row, true
+}
+
+// frameTop returns the terminal row (starting at 1) on which the last frame displayed
+// starts, or -1 if unknown: frames ending below the screen have scrolled it up.
+func (e *Engine) frameTop() int {
+	if e.frame == nil || e.frameRow < 1 {
+		return -1
+	}
+
+	return min(e.frameRow, e.term.Height()-len(e.frame.rows)+1)
+}
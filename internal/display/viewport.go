@@ -0,0 +1,204 @@
+package display
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/reeflective/readline/internal/color"
+	"github.com/reeflective/readline/internal/core"
+	"github.com/reeflective/readline/internal/strutil"
+)
+
+// Minimum number of columns needed for each line in horizontal scroll mode.
+const minScrollColumns = 8
+
+// viewport is the part of the input line displayed on the screen. When the line needs more rows than
+// there are available, only the lines around the cursor are displayed, along with the number of lines
+// hidden above and below them. In horizontal scroll mode, each line is displayed on a single row, and
+// all lines are scrolled horizontally so that the cursor stays in view.
+type viewport struct {
+	top     int // First line displayed.
+	bottom  int // Last line displayed.
+	lines   int // Number of lines in the buffer.
+	columns int // Number of columns for each line, after the prompt.
+	offset  int // First column displayed, in horizontal scroll mode.
+	hidden  int // Number of rows used by the lines hidden above.
+	scroll  bool
+}
+
+// computeViewport finds the part of the line (with any suggestion) to be displayed,
+// and adjusts the cursor coordinates accordingly: the line coordinates must then be
+// computed on the line as it is displayed (see viewport.display).
+func (e *Engine) computeViewport(line []rune) {
+	width, height := e.term.Width(), e.term.Height()
+	lines := strings.Split(strutil.FormatTabs(string(line)), "\n")
+	cursorLine := strings.Count(string((*e.line)[:e.cursor.Pos()]), "\n")
+
+	prev := e.view
+	e.view = viewport{bottom: len(lines) - 1, lines: len(lines), columns: max(width-e.startCols-1, 1)}
+
+	// The cursor coordinates depend on the viewport being computed.
+	defer func() {
+		e.cursorCol, e.cursorRow = e.cursorCoordinates(e.cursor)
+	}()
+
+	// Horizontal scrolling, to keep the cursor in view.
+	if e.opts.GetBool("horizontal-scroll-mode") && e.view.columns >= minScrollColumns {
+		col, _ := core.CoordinatesCursor(e.cursor, 0, math.MaxInt32)
+
+		e.view.scroll = true
+		e.view.offset = scrollColumns(prev.offset, col, strutil.RealLength(lines[cursorLine]), e.view.columns)
+	}
+
+	rows := make([]int, len(lines))
+	used := 0
+
+	for i, line := range lines {
+		rows[i] = 1
+		if !e.view.scroll {
+			_, rows[i] = strutil.LineSpan([]rune(line), 1, e.startCols, width)
+		}
+
+		used += rows[i]
+	}
+
+	// Keep rows for the helpers displayed below the line during the last refresh,
+	// and for the hidden lines indicators above and below the lines displayed.
+	available := max(height-1-e.hintRows-e.compRows, 1)
+	if used <= available {
+		return
+	}
+
+	e.view.top, e.view.bottom = scrollLines(rows, prev.top, cursorLine, max(available-2, 1))
+
+	for _, count := range rows[:e.view.top] {
+		e.view.hidden += count
+	}
+}
+
+// cursorCoordinates returns the coordinates of a cursor in the line as displayed in the viewport,
+// relative to the beginning of the line: the indicator of the lines hidden above uses one row.
+func (e *Engine) cursorCoordinates(cursor *core.Cursor) (col, row int) {
+	col, row = core.CoordinatesCursor(cursor, e.startCols, e.term.Width())
+
+	if e.view.scroll {
+		col, _ = core.CoordinatesCursor(cursor, 0, math.MaxInt32)
+		col, row = e.startCols+col-e.view.offset, cursor.LinePos()
+	}
+
+	if e.view.top > 0 {
+		row -= e.view.hidden - 1
+	}
+
+	return col, row
+}
+
+// scrollLines returns the first and last lines to display in the available rows, starting
+// from the first line previously displayed if possible: the cursor line is always displayed.
+func scrollLines(rows []int, top, cursor, available int) (first, last int) {
+	first, last = min(top, cursor), min(top, cursor)-1
+	used := 0
+
+	for last+1 < len(rows) && used+rows[last+1] <= available {
+		last++
+		used += rows[last]
+	}
+
+	// Scroll down until the cursor line is the last one.
+	if last < cursor {
+		first, last, used = cursor, cursor, rows[cursor]
+	}
+
+	// And use the rows left, if any, for the lines above.
+	for first > 0 && used+rows[first-1] <= available {
+		first--
+		used += rows[first]
+	}
+
+	return first, last
+}
+
+// scrollColumns returns the first column to display so that the cursor column is visible,
+// scrolling by half the columns (like Bash) if it is not: when scrolled, the first and the
+// last columns might be used by the scroll indicators.
+func scrollColumns(offset, cursor, length, columns int) int {
+	if length <= columns {
+		return 0
+	}
+
+	if (offset == 0 || cursor > offset) && cursor < offset+columns-1 {
+		return offset
+	}
+
+	return max(cursor-columns/2, 0)
+}
+
+// display returns the part of the line, which might be highlighted, displayed in the viewport.
+func (v viewport) display(line string) string {
+	lines := strings.Split(line, "\n")
+	if len(lines) != v.lines || (v.top == 0 && v.bottom == len(lines)-1 && !v.scroll) {
+		return line
+	}
+
+	displayed := make([]string, 0, v.bottom-v.top+3)
+
+	if v.top > 0 {
+		displayed = append(displayed, v.hiddenLines(v.top, "above"))
+	}
+
+	// Escape sequences of the hidden lines are kept, for their colors.
+	var escapes string
+	for _, hidden := range lines[:v.top] {
+		escapes += strutil.SliceColumns(hidden, 0, 0)
+	}
+
+	for _, line := range lines[v.top : v.bottom+1] {
+		if v.scroll {
+			line = v.scrollLine(line)
+		}
+
+		displayed = append(displayed, escapes+line)
+		escapes = ""
+	}
+
+	if v.bottom < len(lines)-1 {
+		displayed = append(displayed, v.hiddenLines(len(lines)-1-v.bottom, "below"))
+	}
+
+	return strings.Join(displayed, "\n")
+}
+
+// scrollLine returns the columns of a line displayed in horizontal scroll mode, with
+// indicators in the first and last columns if there are more characters before
+// or after them, like Bash does.
+func (v viewport) scrollLine(line string) string {
+	length := strutil.RealLength(line)
+	start, end := v.offset, v.offset+v.columns
+	before, after := "", ""
+
+	if v.offset > 0 && length > 0 {
+		start++
+		before = "<"
+	}
+
+	if length > end {
+		end--
+		after = ">"
+	}
+
+	return before + strutil.SliceColumns(line, start, end) + after
+}
+
+// hiddenLines returns the indicator of lines hidden above or below the viewport,
+// which is truncated if needed so that it is displayed on a single row.
+func (v viewport) hiddenLines(count int, where string) string {
+	lines := "lines"
+	if count == 1 {
+		lines = "line"
+	}
+
+	indicator := fmt.Sprintf("%d more %s %s", count, lines, where)
+
+	return color.Reset + color.Dim + strutil.SliceColumns(indicator, 0, v.columns) + color.Reset
+}
@@ -0,0 +1,31 @@
+package display
+
+import "testing"
+
+func TestScrollLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		rows      []int
+		top       int
+		cursor    int
+		available int
+		wantFirst int
+		wantLast  int
+	}{
+		{name: "All lines fit", rows: []int{1, 1, 1}, cursor: 2, available: 3, wantFirst: 0, wantLast: 2},
+		{name: "Cursor below the viewport", rows: []int{1, 1, 1, 1, 1}, cursor: 4, available: 3, wantFirst: 2, wantLast: 4},
+		{name: "Cursor above the viewport", rows: []int{1, 1, 1, 1, 1}, top: 3, cursor: 1, available: 3, wantFirst: 1, wantLast: 3},
+		{name: "Cursor in the viewport", rows: []int{1, 1, 1, 1, 1}, top: 1, cursor: 2, available: 3, wantFirst: 1, wantLast: 3},
+		{name: "Wrapped lines", rows: []int{1, 3, 1, 2}, cursor: 3, available: 4, wantFirst: 2, wantLast: 3},
+		{name: "Cursor line taller than the screen", rows: []int{1, 5, 1}, cursor: 1, available: 3, wantFirst: 1, wantLast: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			first, last := scrollLines(test.rows, test.top, test.cursor, test.available)
+			if first != test.wantFirst || last != test.wantLast {
+				t.Errorf("scrollLines() = (%d, %d), want (%d, %d)", first, last, test.wantFirst, test.wantLast)
+			}
+		})
+	}
+}
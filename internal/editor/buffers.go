@@ -1,6 +1,7 @@
 package editor
 
 import (
+	"errors"
 	"fmt"
 	"sort"
 	"strconv"
@@ -8,10 +9,14 @@ import (
 	"sync"
 	"unicode"
 
+	"github.com/reeflective/readline/inputrc"
 	"github.com/reeflective/readline/internal/color"
 	"github.com/reeflective/readline/internal/completion"
 )
 
+// ErrSecret indicates that the buffer to edit is a secret, which must not be written to a file.
+var ErrSecret = errors.New("cannot edit a secret in the system editor")
+
 var (
 	// validRegisterKeys - All valid register IDs (keys) for read/write Vim registers.
 	// validRegisterKeys = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789/-\"".
@@ -29,7 +34,11 @@ type Buffers struct {
 	waiting  bool            // The user wants to use a still unidentified register
 	selected bool            // We have identified the register, and acting on it.
 	active   rune            // Any of the read/write registers ("/num/alpha)
+	secret   bool            // The line is a secret: nothing is written nor edited.
 	mutex    *sync.Mutex
+
+	clipboard Clipboard       // The system clipboard of the "+ and "* registers.
+	config    *inputrc.Config // The kill ring might be copied to the clipboard.
 }
 
 // NewBuffers is a required constructor to set up all the buffers/registers
@@ -43,6 +52,27 @@ func NewBuffers() *Buffers {
 	}
 }
 
+// Init is used once at shell creation time to pass further parameters to the buffers:
+// the shell configuration, and the default system clipboard (see SetClipboard).
+func Init(reg *Buffers, config *inputrc.Config, clipboard Clipboard) {
+	reg.config = config
+	reg.clipboard = clipboard
+}
+
+// SetClipboard sets the system clipboard used by the "+ and "* registers, and to which
+// the kill ring is copied if the kill-ring-clipboard option is on. By default, this is
+// the clipboard of the terminal, used with OSC 52 sequences (see NewTerminalClipboard).
+func (reg *Buffers) SetClipboard(clipboard Clipboard) {
+	reg.clipboard = clipboard
+}
+
+// SetSecret is used when the shell reads a secret (like a password): while set,
+// nothing is written to the kill ring nor to any register, and buffers cannot be
+// edited in the system editor, since the secret would be written to a temp file.
+func SetSecret(reg *Buffers, secret bool) {
+	reg.secret = secret
+}
+
 // SetActive sets the currently active register/buffer.
 // Valid values are letters (lower/upper), digits (1-9),
 // or read-only buffers ( . % : ).
@@ -79,6 +109,10 @@ func (reg *Buffers) Get(register rune) []rune {
 		return reg.GetKill()
 	}
 
+	if isClipboard(register) {
+		return reg.paste(register)
+	}
+
 	num, err := strconv.Atoi(string(register))
 	if err == nil {
 		return reg.num[num]
@@ -138,10 +172,14 @@ func (reg *Buffers) GetKill() []rune {
 // Write writes a slice to the currently active buffer, and/or to the kill one.
 // After the operation, the buffers are reset, eg. none is considered active.
 func (reg *Buffers) Write(content ...rune) {
-	buf := string(content)
-
 	defer reg.Reset()
 
+	if reg.secret {
+		return
+	}
+
+	buf := string(content)
+
 	if len(content) == 0 || buf == "" {
 		return
 	}
@@ -152,11 +190,20 @@ func (reg *Buffers) Write(content ...rune) {
 	} else {
 		reg.writeNum(-1, []rune(buf))
 	}
+
+	// And optionally, to the system clipboard.
+	if !reg.selected && reg.config != nil && reg.config.GetBool("kill-ring-clipboard") {
+		reg.copy('+', buf)
+	}
 }
 
 // WriteTo writes a slice directly to a target register.
 // If the register name is invalid, nothing is written anywhere.
 func (reg *Buffers) WriteTo(register rune, content ...rune) {
+	if reg.secret {
+		return
+	}
+
 	buf := string(content)
 
 	if len(content) == 0 || buf == "" {
@@ -168,6 +215,11 @@ func (reg *Buffers) WriteTo(register rune, content ...rune) {
 		return
 	}
 
+	if isClipboard(register) {
+		reg.copy(register, buf)
+		return
+	}
+
 	// If number register.
 	num, err := strconv.Atoi(string(register))
 	if num > 0 && num < 10 && err != nil {
@@ -229,6 +281,31 @@ func (reg *Buffers) Complete() completion.Values {
 	return comps
 }
 
+// copy writes to the system clipboard of a register.
+func (reg *Buffers) copy(register rune, buf string) {
+	if reg.clipboard != nil {
+		reg.clipboard.Copy(register, buf)
+	}
+}
+
+// paste reads the system clipboard of a register.
+func (reg *Buffers) paste(register rune) []rune {
+	if reg.clipboard == nil {
+		return nil
+	}
+
+	buf, err := reg.clipboard.Paste(register)
+	if err != nil {
+		return nil
+	}
+
+	return []rune(buf)
+}
+
+func isClipboard(register rune) bool {
+	return register == '+' || register == '*'
+}
+
 func (reg *Buffers) writeNum(register int, buf []rune) {
 	// No numbered register above 10
 	if register > numRegisters-1 {
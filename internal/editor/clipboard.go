@@ -0,0 +1,82 @@
+package editor
+
+import (
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/reeflective/readline/internal/core"
+	"github.com/reeflective/readline/internal/term"
+)
+
+// clipboardTimeout is how long to wait for the terminal to answer a clipboard query.
+const clipboardTimeout = time.Second
+
+// Clipboard is a system clipboard, to which the "+ and "* registers are written, and
+// from which they are read: the former is the clipboard, and the latter the primary
+// selection (on systems without one, both can be the clipboard).
+type Clipboard interface {
+	// Copy writes text to the clipboard of the register ('+' or '*').
+	Copy(register rune, text string) error
+	// Paste returns the text in the clipboard of the register ('+' or '*').
+	Paste(register rune) (text string, err error)
+}
+
+// terminalClipboard is the default clipboard, which is the one of the terminal,
+// written and queried with OSC 52 sequences: this works over SSH connections,
+// without access to any display server. Since most terminals do not allow
+// applications to read the clipboard, the text last copied is pasted when
+// the terminal does not answer, and the terminal is not queried anymore.
+type terminalClipboard struct {
+	term      *term.Terminal
+	keys      *core.Keys
+	copied    map[rune]string
+	noAnswers bool
+	mutex     sync.Mutex
+}
+
+// NewTerminalClipboard returns a clipboard using OSC 52 sequences on the terminal.
+func NewTerminalClipboard(t *term.Terminal, k *core.Keys) Clipboard {
+	return &terminalClipboard{
+		term:   t,
+		keys:   k,
+		copied: make(map[rune]string),
+	}
+}
+
+// Copy sends the text to the terminal clipboard.
+func (c *terminalClipboard) Copy(register rune, text string) error {
+	c.mutex.Lock()
+	c.copied[register] = text
+	c.mutex.Unlock()
+
+	c.term.Print("\x1b]52;" + string(selection(register)) + ";" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a")
+
+	return nil
+}
+
+// Paste queries the terminal clipboard, if the terminal answers such queries.
+func (c *terminalClipboard) Paste(register rune) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.noAnswers {
+		text, found := c.keys.QueryClipboard(selection(register), clipboardTimeout)
+		if found {
+			return text, nil
+		}
+
+		c.noAnswers = true
+	}
+
+	return c.copied[register], nil
+}
+
+// selection returns the OSC 52 selection of a register.
+func selection(register rune) byte {
+	if register == '*' {
+		return 'p'
+	}
+
+	return 'c'
+}
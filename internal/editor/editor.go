@@ -109,12 +109,12 @@ func readTempFile(name string) ([]byte, error) {
 
 func getSystemEditor(emacsDefault bool) (editor string) {
 	editor = os.Getenv("VISUAL")
-	if editor == "" {
+	if editor != "" {
 		return
 	}
 
 	editor = os.Getenv("EDITOR")
-	if editor == "" {
+	if editor != "" {
 		return
 	}
 
@@ -3,9 +3,12 @@
 
 package editor
 
-import "errors"
+import (
+	"errors"
+	"os/exec"
+)
 
 // EditBuffer is currently not supported on Plan9 operating systems.
-func (reg *Buffers) EditBuffer(buf []rune, filename, filetype string, emacs bool) ([]rune, error) {
+func (reg *Buffers) EditBuffer(buf []rune, filename, filetype string, emacs bool, run func(*exec.Cmd) error) ([]rune, error) {
 	return buf, errors.New("Not currently supported on Plan 9")
 }
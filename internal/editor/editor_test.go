@@ -0,0 +1,32 @@
+//go:build !windows
+// +build !windows
+
+package editor
+
+import "testing"
+
+func TestGetSystemEditor(t *testing.T) {
+	tests := []struct {
+		name         string
+		visual       string
+		editor       string
+		emacsDefault bool
+		want         string
+	}{
+		{name: "VISUAL first", visual: "code -w", editor: "nano", want: "code -w"},
+		{name: "EDITOR", editor: "nano", want: "nano"},
+		{name: "Vi default", want: "vi"},
+		{name: "Emacs default", emacsDefault: true, want: "emacs"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("VISUAL", test.visual)
+			t.Setenv("EDITOR", test.editor)
+
+			if got := getSystemEditor(test.emacsDefault); got != test.want {
+				t.Errorf("getSystemEditor() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
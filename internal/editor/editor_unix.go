@@ -5,7 +5,6 @@ package editor
 import (
 	"errors"
 	"fmt"
-	"os"
 	"os/exec"
 )
 
@@ -17,7 +16,13 @@ var ErrStart = errors.New("failed to start editor")
 // temp directory under this name.
 // If the filetype is not empty and if the system editor supports it, the
 // file will be opened with the specified filetype passed to the editor.
-func (reg *Buffers) EditBuffer(buf []rune, filename, filetype string, emacs bool) ([]rune, error) {
+// The editor command is run with the run function, which should run it
+// on the shell terminal (like Shell.RunExternal does) and wait for it.
+func (reg *Buffers) EditBuffer(buf []rune, filename, filetype string, emacs bool, run func(*exec.Cmd) error) ([]rune, error) {
+	if reg.secret {
+		return buf, ErrSecret
+	}
+
 	name, err := writeToFile([]byte(string(buf)), filename)
 	if err != nil {
 		return buf, err
@@ -34,15 +39,7 @@ func (reg *Buffers) EditBuffer(buf []rune, filename, filetype string, emacs bool
 
 	cmd := exec.Command(editor, args...)
 
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
-	if err = cmd.Start(); err != nil {
-		return buf, fmt.Errorf("%w: %s", ErrStart, err.Error())
-	}
-
-	if err = cmd.Wait(); err != nil {
+	if err = run(cmd); err != nil {
 		return buf, fmt.Errorf("%w: %s", ErrStart, err.Error())
 	}
 
@@ -2,10 +2,14 @@ package history
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -16,10 +20,22 @@ var (
 	errOutOfRangeIndex = errors.New("index requested greater than number of items in history")
 )
 
+// readChunkSize is the size of the chunks read when looking for the lines to read
+// at the end of a history file, when its oldest lines are not read (see start).
+const readChunkSize = 64 * 1024
+
 // fileHistory provides a history source based on a file.
 type fileHistory struct {
-	file  string
-	lines []Item
+	file   string
+	lines  []Item
+	offset int64       // Size of the part of the file read.
+	info   os.FileInfo // The file read, to know when it is replaced.
+	skip   bool        // The oldest lines of the file have not been read.
+
+	// Limits
+	maxLines int   // Maximum number of lines, or -1 if not limited.
+	maxSize  int64 // Maximum size of the file in bytes, or 0 if not limited.
+	rotate   int   // Number of rotated files kept when the size is exceeded.
 }
 
 // Item is the structure of an individual item in the History.list slice.
@@ -27,80 +43,256 @@ type Item struct {
 	Index    int
 	DateTime time.Time
 	Block    string
+	Dir      string
+	Session  string
+	Result   *Result
+}
+
+// record is a line of a history file: either an item, or the result of the command
+// of an item written before it, which has the same time and session, and no block.
+type record struct {
+	DateTime time.Time `json:"datetime"`
+	Block    string    `json:"block,omitempty"`
+	Dir      string    `json:"dir,omitempty"`
+	Session  string    `json:"session,omitempty"`
+	Result   *Result   `json:"result,omitempty"`
 }
 
 // NewSourceFromFile returns a new history source writing to and reading from a file.
+// The file can be shared by several processes: writes are done while holding a lock on
+// it, and the lines written by other processes are read before walking or searching it.
 func NewSourceFromFile(file string) (Source, error) {
-	var err error
-
 	hist := new(fileHistory)
 	hist.file = file
-	hist.lines, err = openHist(file)
+	hist.maxLines = -1
 
-	return hist, err
+	return hist, hist.open()
 }
 
-func openHist(filename string) (list []Item, err error) {
-	file, err := os.Open(filename)
+// open reads the history file for the first time: if its limits are set, only
+// its most recent lines are read (see start), so that huge files are read fast.
+func (h *fileHistory) open() error {
+	file, err := os.Open(h.file)
 	if err != nil {
-		return list, fmt.Errorf("%w: %s", errOpenHistoryFile, err.Error())
+		return fmt.Errorf("%w: %s", errOpenHistoryFile, err.Error())
+	}
+
+	defer file.Close()
+
+	return h.read(file)
+}
+
+// Reload reads the lines written to the history file since it was last read, like those
+// written by other processes sharing it. If the file has been replaced or truncated (like
+// when another process has compacted it), all of its lines are read again.
+func (h *fileHistory) Reload() error {
+	file, err := os.Open(h.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("%w: %s", errOpenHistoryFile, err.Error())
 	}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		var item Item
+	defer file.Close()
 
-		err := json.Unmarshal(scanner.Bytes(), &item)
-		if err != nil || len(item.Block) == 0 {
-			continue
+	return h.read(file)
+}
+
+// read reads the complete lines of the file after those already read: the last
+// one might still be being written, or be a corrupted line written by a process
+// which crashed while doing so, in which case it is skipped once terminated.
+func (h *fileHistory) read(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if info.Size() < h.offset || (h.info != nil && !os.SameFile(h.info, info)) {
+		h.lines, h.offset = nil, 0
+	}
+
+	h.info = info
+
+	if h.offset == 0 {
+		if h.offset, err = h.start(file, info.Size()); err != nil {
+			return err
 		}
 
-		item.Index = len(list)
-		list = append(list, item)
+		h.skip = h.offset > 0
 	}
 
-	file.Close()
+	if _, err = file.Seek(h.offset, io.SeekStart); err != nil {
+		return err
+	}
 
-	return list, nil
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+	h.offset += int64(len(data))
+
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		h.parse(line)
+	}
+
+	return nil
 }
 
-// Write item to history file.
-func (h *fileHistory) Write(s string) (int, error) {
-	block := strings.TrimSpace(s)
-	if block == "" {
+// start returns the offset of the first line read in a history file of the given size, when
+// it is read from its beginning. If the file has more lines than it can hold before being
+// compacted (see compact), or is bigger than its maximum size, the oldest lines are not read,
+// since they would be removed anyway: the file is scanned backwards, from its end, until
+// enough lines (not counting results) are found, or the maximum size is reached.
+func (h *fileHistory) start(file *os.File, size int64) (int64, error) {
+	var bound int64
+	if h.maxSize > 0 && size > h.maxSize {
+		bound = size - h.maxSize
+	}
+
+	limit := h.maxLines + h.maxLines/2
+	if h.maxLines < 0 && bound == 0 {
 		return 0, nil
+	} else if limit == 0 {
+		return size, nil
 	}
 
-	item := Item{
-		DateTime: time.Now(),
-		Block:    block,
-		Index:    len(h.lines),
+	var items int
+	var tail []byte
+
+	start, end := size, size
+
+	for end > bound {
+		begin := end - readChunkSize
+		if begin < bound {
+			begin = bound
+		}
+
+		data := make([]byte, end-begin, end-begin+int64(len(tail)))
+		if _, err := file.ReadAt(data, begin); err != nil {
+			return 0, err
+		}
+
+		data = append(data, tail...)
+
+		// Count the complete lines of the chunk, from the last one.
+		for last := len(data); ; {
+			newline := bytes.LastIndexByte(data[:last], '\n')
+			if newline < 0 {
+				tail = data[:last]
+				break
+			}
+
+			// The line after the last newline of the file is not complete.
+			if begin+int64(last) < size && isItem(data[newline+1:last]) {
+				items++
+			}
+
+			start, last = begin+int64(newline)+1, newline
+
+			if h.maxLines >= 0 && items == limit {
+				return start, nil
+			}
+		}
+
+		end = begin
 	}
 
-	if len(h.lines) == 0 || h.lines[len(h.lines)-1].Block != block {
-		h.lines = append(h.lines, item)
+	// The first line of the file is complete, but
+	// not the first one read when it is too big.
+	if bound == 0 {
+		return 0, nil
 	}
 
-	line := struct {
-		DateTime time.Time `json:"datetime"`
-		Block    string    `json:"block"`
-	}{
-		Block:    block,
-		DateTime: item.DateTime,
+	return start, nil
+}
+
+// isItem returns true if a line of a history file holds an item, and not a result.
+func isItem(data []byte) bool {
+	var line record
+
+	return json.Unmarshal(data, &line) == nil && len(line.Block) > 0
+}
+
+// parse adds the item of a line of the history file, or attaches the result it holds to
+// its item. Lines which are not valid records, like corrupted ones, are skipped.
+func (h *fileHistory) parse(data []byte) {
+	var line record
+
+	if err := json.Unmarshal(data, &line); err != nil {
+		return
 	}
 
-	data, err := json.Marshal(line)
-	if err != nil {
-		return h.Len(), err
+	if len(line.Block) == 0 {
+		setResult(h.lines, line)
+		return
 	}
 
-	f, err := os.OpenFile(h.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
-	if err != nil {
-		return 0, fmt.Errorf("%w: %s", errOpenHistoryFile, err.Error())
+	h.lines = append(h.lines, Item{
+		Index:    len(h.lines),
+		DateTime: line.DateTime,
+		Block:    line.Block,
+		Dir:      line.Dir,
+		Session:  line.Session,
+		Result:   line.Result,
+	})
+}
+
+// setResult attaches the result of a record to the last item with the same time and session.
+func setResult(list []Item, result record) {
+	if result.Result == nil {
+		return
+	}
+
+	for i := len(list) - 1; i >= 0; i-- {
+		if list[i].DateTime.Equal(result.DateTime) && list[i].Session == result.Session {
+			list[i].Result = result.Result
+			return
+		}
 	}
+}
 
-	_, err = f.Write(append(data, '\n'))
-	f.Close()
+// Write item to history file.
+func (h *fileHistory) Write(s string) (int, error) {
+	return h.WriteEntry(Entry{Line: s, Time: time.Now()})
+}
+
+// WriteEntry writes an item with its metadata to the history file.
+func (h *fileHistory) WriteEntry(entry Entry) (int, error) {
+	block := strings.TrimSpace(entry.Line)
+	if block == "" {
+		return 0, nil
+	}
+
+	line := record{
+		Block:    block,
+		DateTime: entry.Time,
+		Dir:      entry.Directory,
+		Session:  entry.Session,
+		Result:   entry.Result,
+	}
+
+	// Lines written by other processes are read when appending,
+	// so the item is added after them, like it is in the file.
+	err := h.locked(func() error {
+		err := h.append(line)
+
+		h.lines = append(h.lines, Item{
+			Index:    len(h.lines),
+			DateTime: line.DateTime,
+			Block:    block,
+			Dir:      line.Dir,
+			Session:  line.Session,
+			Result:   line.Result,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		return h.compact()
+	})
 
 	return h.Len(), err
 }
@@ -118,6 +310,78 @@ func (h *fileHistory) GetLine(pos int) (string, error) {
 	return "", errOutOfRangeIndex
 }
 
+// GetEntry returns a specific line from the history file, with its metadata.
+func (h *fileHistory) GetEntry(pos int) (Entry, error) {
+	if pos < 0 {
+		return Entry{}, errNegativeIndex
+	}
+
+	if pos >= len(h.lines) {
+		return Entry{}, errOutOfRangeIndex
+	}
+
+	item := h.lines[pos]
+
+	return Entry{
+		Line:      item.Block,
+		Time:      item.DateTime,
+		Directory: item.Dir,
+		Session:   item.Session,
+		Result:    item.Result,
+	}, nil
+}
+
+// SetResult attaches the result of its command to a line, and writes it to
+// the history file, so that it is attached to the line when read again.
+func (h *fileHistory) SetResult(pos int, result Result) error {
+	if pos < 0 {
+		return errNegativeIndex
+	}
+
+	if pos >= len(h.lines) {
+		return errOutOfRangeIndex
+	}
+
+	item := h.lines[pos]
+	line := record{DateTime: item.DateTime, Session: item.Session, Result: &result}
+
+	// The lines might be read again when appending, so the
+	// result is attached to the line once it has been written.
+	err := h.locked(func() error {
+		return h.append(line)
+	})
+
+	setResult(h.lines, line)
+
+	return err
+}
+
+// Erase removes all lines identical to the given one from the history file,
+// which is rewritten if it has any, along with the lines of other processes.
+func (h *fileHistory) Erase(line string) error {
+	block := strings.TrimSpace(line)
+
+	return h.locked(func() error {
+		if err := h.Reload(); err != nil {
+			return err
+		}
+
+		kept := make([]Item, 0, len(h.lines))
+
+		for _, item := range h.lines {
+			if item.Block != block {
+				kept = append(kept, item)
+			}
+		}
+
+		if len(kept) == len(h.lines) {
+			return nil
+		}
+
+		return h.rewrite(kept, false)
+	})
+}
+
 // Len returns the number of items in the history file.
 func (h *fileHistory) Len() int {
 	return len(h.lines)
@@ -127,3 +391,255 @@ func (h *fileHistory) Len() int {
 func (h *fileHistory) Dump() interface{} {
 	return h.lines
 }
+
+// Compact enforces the limits of the history file, if it exceeds them (see compact).
+func (h *fileHistory) Compact() error {
+	return h.locked(func() error {
+		if err := h.Reload(); err != nil {
+			return err
+		}
+
+		return h.compact()
+	})
+}
+
+// locked runs a function while holding an exclusive lock on the history file. The
+// lock is held on a separate file (history.lock), since the file can be replaced.
+func (h *fileHistory) locked(run func() error) error {
+	lock, err := os.OpenFile(h.file+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errOpenHistoryFile, err.Error())
+	}
+
+	defer lock.Close()
+
+	if err = lockFile(lock); err != nil {
+		return err
+	}
+
+	defer unlockFile(lock)
+
+	return run()
+}
+
+// append appends a record to the history file, which must be locked. Lines written
+// by other processes since the file was last read are read first, and the last line
+// is terminated if it is not, like when a process has crashed while writing it.
+func (h *fileHistory) append(line record) error {
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(h.file, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return fmt.Errorf("%w: %s", errOpenHistoryFile, err.Error())
+	}
+
+	defer file.Close()
+
+	if err = h.read(file); err != nil {
+		return err
+	}
+
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if size > h.offset {
+		data = append([]byte{'\n'}, data...)
+	}
+
+	data = append(data, '\n')
+
+	if _, err = file.Write(data); err != nil {
+		return err
+	}
+
+	h.offset = size + int64(len(data))
+
+	return nil
+}
+
+// compact replaces the history file, which must be locked and read, with its most recent lines
+// if it exceeds its limits: they are written to a temporary file, which is renamed over it. So
+// that the file is not rewritten on each write, the limits are only enforced with some slack:
+// the maximum number of lines is kept once it is exceeded by half, and when the size is exceeded,
+// the lines kept fill half of it. The file is then kept as history.1 if rotated files are kept
+// (history.1 becoming history.2...). Files whose oldest lines have not been read are compacted.
+func (h *fileHistory) compact() error {
+	oversized := h.maxSize > 0 && h.offset > h.maxSize
+	if !oversized && !h.skip && (h.maxLines < 0 || len(h.lines) <= h.maxLines+h.maxLines/2) {
+		return nil
+	}
+
+	size := h.maxSize
+	if oversized {
+		size /= 2
+	}
+
+	// Find the most recent lines fitting in the limits.
+	var kept int
+	var used int64
+
+	for pos := len(h.lines) - 1; pos >= 0; pos-- {
+		if h.maxLines >= 0 && kept == h.maxLines {
+			break
+		}
+
+		data, err := json.Marshal(h.lines[pos].record())
+		if err != nil {
+			return err
+		}
+
+		if size > 0 && used+int64(len(data))+1 > size {
+			break
+		}
+
+		kept++
+		used += int64(len(data)) + 1
+	}
+
+	return h.rewrite(h.lines[len(h.lines)-kept:], oversized && h.rotate > 0)
+}
+
+// rewrite replaces the history file, which must be locked, with the items kept: they are
+// written to a temporary file next to it, which is renamed over it. If rotate is true, the
+// file is kept as history.1 (history.1 becoming history.2...) before being replaced.
+func (h *fileHistory) rewrite(kept []Item, rotate bool) error {
+	temp, err := os.CreateTemp(filepath.Dir(h.file), filepath.Base(h.file)+".*")
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(temp)
+	encoder := json.NewEncoder(writer)
+
+	for _, item := range kept {
+		if err = encoder.Encode(item.record()); err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	// The lines must be on disk before the file replaces
+	// the history, which could otherwise be lost on a crash.
+	if err == nil {
+		err = temp.Sync()
+	}
+
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil && rotate {
+		err = h.rotateFiles()
+	}
+
+	if err == nil {
+		err = os.Rename(temp.Name(), h.file)
+	}
+
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+
+	syncDir(filepath.Dir(h.file))
+
+	lines := make([]Item, len(kept))
+
+	for pos, item := range kept {
+		item.Index = pos
+		lines[pos] = item
+	}
+
+	h.lines = lines
+	h.skip = false
+	h.info, err = os.Stat(h.file)
+
+	if err == nil {
+		h.offset = h.info.Size()
+	}
+
+	return err
+}
+
+// syncDir commits the renaming of files in a directory to disk. Errors are ignored,
+// since directories cannot be synced on all systems (like Windows), where renaming
+// files is either already durable, or cannot be made so.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// rotateFiles renames the rotated files to the next number (history.1 to history.2...), the
+// oldest one being overwritten if all are kept, and keeps the history file as history.1. The
+// latter is linked, or copied if links are not supported, since it must exist until the new
+// file is renamed over it: otherwise, it would appear empty to other processes in between.
+func (h *fileHistory) rotateFiles() error {
+	for num := h.rotate; num > 1; num-- {
+		err := os.Rename(h.rotated(num-1), h.rotated(num))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	err := os.Remove(h.rotated(1))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err = os.Link(h.file, h.rotated(1)); err == nil {
+		return nil
+	}
+
+	return copyFile(h.file, h.rotated(1))
+}
+
+// copyFile copies the contents of a file to a new one, and syncs it to disk.
+func copyFile(src, dst string) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer source.Close()
+
+	target, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(target, source); err == nil {
+		err = target.Sync()
+	}
+
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// rotated returns the path of a rotated history file.
+func (h *fileHistory) rotated(num int) string {
+	return h.file + "." + strconv.Itoa(num)
+}
+
+// record returns the line of the history file holding an item.
+func (item Item) record() record {
+	return record{
+		DateTime: item.DateTime,
+		Block:    item.Block,
+		Dir:      item.Dir,
+		Session:  item.Session,
+		Result:   item.Result,
+	}
+}
@@ -0,0 +1,571 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeHistory writes the contents of a history file in a temporary directory.
+func writeHistory(t *testing.T, contents string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "history")
+
+	if err := os.WriteFile(file, []byte(contents), 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+
+	return file
+}
+
+// readHistory returns the contents of a history file.
+func readHistory(t *testing.T, file string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("os.ReadFile() error = %v", err)
+	}
+
+	return string(data)
+}
+
+// appendHistory appends data to a history file, like another process would.
+func appendHistory(t *testing.T, file, data string) {
+	t.Helper()
+
+	histFile, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatalf("os.OpenFile() error = %v", err)
+	}
+
+	defer histFile.Close()
+
+	if _, err = histFile.WriteString(data); err != nil {
+		t.Fatalf("os.File.WriteString() error = %v", err)
+	}
+}
+
+// historyLines returns all lines of a history source.
+func historyLines(hist Source) []string {
+	var lines []string
+
+	for pos := 0; pos < hist.Len(); pos++ {
+		line, _ := hist.GetLine(pos)
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+func TestFileHistory_WriteEntry(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	hist, err := NewSourceFromFile(file)
+	if hist == nil {
+		t.Fatalf("NewSourceFromFile() = nil, %v", err)
+	}
+
+	entries := hist.(EntrySource)
+
+	if _, err = entries.WriteEntry(Entry{Line: "  ls -l ", Time: date, Directory: "/tmp", Session: "s1"}); err != nil {
+		t.Fatalf("fileHistory.WriteEntry() error = %v", err)
+	}
+
+	if _, err = entries.WriteEntry(Entry{Line: "   ", Time: date}); err != nil {
+		t.Fatalf("fileHistory.WriteEntry() error = %v", err)
+	}
+
+	if err = entries.SetResult(0, Result{Status: 1, Duration: time.Second}); err != nil {
+		t.Fatalf("fileHistory.SetResult() error = %v", err)
+	}
+
+	// Results are written as records without a block, after their line.
+	want := `{"datetime":"2024-01-02T03:04:05Z","block":"ls -l","dir":"/tmp","session":"s1"}` + "\n" +
+		`{"datetime":"2024-01-02T03:04:05Z","session":"s1","result":{"status":1,"duration":1000000000}}` + "\n"
+
+	if got := readHistory(t, file); got != want {
+		t.Errorf("history file = %q, want %q", got, want)
+	}
+
+	// The result is attached to its line when read again.
+	hist, err = NewSourceFromFile(file)
+	if err != nil {
+		t.Fatalf("NewSourceFromFile() error = %v", err)
+	}
+
+	wantEntry := Entry{
+		Line:      "ls -l",
+		Time:      date,
+		Directory: "/tmp",
+		Session:   "s1",
+		Result:    &Result{Status: 1, Duration: time.Second},
+	}
+
+	if got, err := hist.(EntrySource).GetEntry(0); err != nil || !reflect.DeepEqual(got, wantEntry) {
+		t.Errorf("fileHistory.GetEntry() = %+v, %v, want %+v", got, err, wantEntry)
+	}
+
+	if got := hist.Len(); got != 1 {
+		t.Errorf("fileHistory.Len() = %d, want 1", got)
+	}
+}
+
+func TestNewSourceFromFile_Legacy(t *testing.T) {
+	// Files written before lines had metadata hold whole items.
+	file := writeHistory(t, `{"Index":0,"DateTime":"2020-05-06T07:08:09Z","Block":"ls"}`+"\n"+
+		`{"Index":1,"DateTime":"2020-05-06T07:08:10Z","Block":"cd /"}`+"\n")
+
+	hist, err := NewSourceFromFile(file)
+	if err != nil {
+		t.Fatalf("NewSourceFromFile() error = %v", err)
+	}
+
+	if got, want := historyLines(hist), []string{"ls", "cd /"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("history lines = %q, want %q", got, want)
+	}
+
+	entry, err := hist.(EntrySource).GetEntry(1)
+	if want := time.Date(2020, 5, 6, 7, 8, 10, 0, time.UTC); err != nil || !entry.Time.Equal(want) || entry.Result != nil {
+		t.Errorf("fileHistory.GetEntry() = %+v, %v, want a line at %v without result", entry, err, want)
+	}
+
+	// New lines are appended in the new format, and both are read.
+	if _, err = hist.Write("pwd"); err != nil {
+		t.Fatalf("fileHistory.Write() error = %v", err)
+	}
+
+	if hist, err = NewSourceFromFile(file); err != nil {
+		t.Fatalf("NewSourceFromFile() error = %v", err)
+	}
+
+	if got, want := historyLines(hist), []string{"ls", "cd /", "pwd"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("history lines = %q, want %q", got, want)
+	}
+}
+
+func TestSetResult(t *testing.T) {
+	date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		result record
+		want   []int // Status of each item, or -1 if it has no result.
+	}{
+		{
+			name:   "Same time and session",
+			result: record{DateTime: date, Session: "s2", Result: &Result{Status: 2}},
+			want:   []int{-1, 2, -1},
+		},
+		{
+			name:   "Last item with the same time and session",
+			result: record{DateTime: date, Session: "s1", Result: &Result{Status: 1}},
+			want:   []int{-1, -1, 1},
+		},
+		{
+			name:   "Other time",
+			result: record{DateTime: date.Add(time.Second), Session: "s1", Result: &Result{Status: 1}},
+			want:   []int{-1, -1, -1},
+		},
+		{
+			name:   "Other session",
+			result: record{DateTime: date, Session: "s3", Result: &Result{Status: 1}},
+			want:   []int{-1, -1, -1},
+		},
+		{
+			name:   "No result",
+			result: record{DateTime: date, Session: "s1"},
+			want:   []int{-1, -1, -1},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			items := []Item{
+				{Block: "a", DateTime: date, Session: "s1"},
+				{Block: "b", DateTime: date, Session: "s2"},
+				{Block: "c", DateTime: date, Session: "s1"},
+			}
+
+			setResult(items, test.result)
+
+			for pos, item := range items {
+				got := -1
+				if item.Result != nil {
+					got = item.Result.Status
+				}
+
+				if got != test.want[pos] {
+					t.Errorf("items[%d] status = %d, want %d", pos, got, test.want[pos])
+				}
+			}
+		})
+	}
+}
+
+func TestFileHistory_Reload(t *testing.T) {
+	const (
+		ls  = `{"datetime":"2024-01-02T03:04:05Z","block":"ls"}` + "\n"
+		cd  = `{"datetime":"2024-01-02T03:04:06Z","block":"cd /"}` + "\n"
+		pwd = `{"datetime":"2024-01-02T03:04:07Z","block":"pwd"}` + "\n"
+	)
+
+	tests := []struct {
+		name   string
+		update func(t *testing.T, file string)
+		want   []string
+	}{
+		{
+			name: "Appended lines",
+			update: func(t *testing.T, file string) {
+				appendHistory(t, file, pwd)
+			},
+			want: []string{"ls", "cd /", "pwd"},
+		},
+		{
+			name: "Replaced file",
+			update: func(t *testing.T, file string) {
+				replaced := writeHistory(t, cd+pwd)
+
+				if err := os.Rename(replaced, file); err != nil {
+					t.Fatalf("os.Rename() error = %v", err)
+				}
+			},
+			want: []string{"cd /", "pwd"},
+		},
+		{
+			name: "Truncated file",
+			update: func(t *testing.T, file string) {
+				if err := os.WriteFile(file, []byte(pwd), 0o600); err != nil {
+					t.Fatalf("os.WriteFile() error = %v", err)
+				}
+			},
+			want: []string{"pwd"},
+		},
+		{
+			name: "Line being written",
+			update: func(t *testing.T, file string) {
+				appendHistory(t, file, pwd[:20])
+			},
+			want: []string{"ls", "cd /"},
+		},
+		{
+			name: "Removed file",
+			update: func(t *testing.T, file string) {
+				if err := os.Remove(file); err != nil {
+					t.Fatalf("os.Remove() error = %v", err)
+				}
+			},
+			want: []string{"ls", "cd /"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := writeHistory(t, ls+cd)
+
+			hist, err := NewSourceFromFile(file)
+			if err != nil {
+				t.Fatalf("NewSourceFromFile() error = %v", err)
+			}
+
+			test.update(t, file)
+
+			if err = hist.(Reloader).Reload(); err != nil {
+				t.Fatalf("fileHistory.Reload() error = %v", err)
+			}
+
+			if got := historyLines(hist); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("history lines = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestFileHistory_CorruptedLine(t *testing.T) {
+	// A process crashed while writing the last line.
+	file := writeHistory(t, `{"datetime":"2024-01-02T03:04:05Z","block":"ls"}`+"\n"+`{"datetime":"2024-01-02T`)
+
+	hist, err := NewSourceFromFile(file)
+	if err != nil {
+		t.Fatalf("NewSourceFromFile() error = %v", err)
+	}
+
+	if got, want := historyLines(hist), []string{"ls"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("history lines = %q, want %q", got, want)
+	}
+
+	// The corrupted line is terminated before appending, and skipped.
+	if _, err = hist.Write("pwd"); err != nil {
+		t.Fatalf("fileHistory.Write() error = %v", err)
+	}
+
+	if hist, err = NewSourceFromFile(file); err != nil {
+		t.Fatalf("NewSourceFromFile() error = %v", err)
+	}
+
+	if got, want := historyLines(hist), []string{"ls", "pwd"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("history lines = %q, want %q", got, want)
+	}
+}
+
+// historyRecords returns the records of lines "echo first" to "echo last-1".
+func historyRecords(first, last int) string {
+	var records strings.Builder
+
+	for num := first; num < last; num++ {
+		fmt.Fprintf(&records, `{"datetime":"2024-01-02T03:04:05Z","block":"echo %d"}`+"\n", num)
+	}
+
+	return records.String()
+}
+
+func TestFileHistory_Compact(t *testing.T) {
+	record := int64(len(historyRecords(0, 1)))
+
+	tests := []struct {
+		name     string
+		lines    int
+		maxLines int
+		maxSize  int64
+		rotate   int
+		want     string
+		rotated  []string
+	}{
+		{
+			name:     "No limits",
+			lines:    10,
+			maxLines: -1,
+			want:     historyRecords(0, 10),
+		},
+		{
+			name:     "Lines within the slack",
+			lines:    6,
+			maxLines: 4,
+			want:     historyRecords(0, 6),
+		},
+		{
+			name:     "Lines exceeding the slack",
+			lines:    7,
+			maxLines: 4,
+			want:     historyRecords(3, 7),
+		},
+		{
+			name:     "Size exceeded",
+			lines:    10,
+			maxLines: -1,
+			maxSize:  8 * record,
+			want:     historyRecords(6, 10),
+		},
+		{
+			name:     "Size exceeded with a line limit",
+			lines:    10,
+			maxLines: 3,
+			maxSize:  8 * record,
+			want:     historyRecords(7, 10),
+		},
+		{
+			name:     "Size exceeded with rotation",
+			lines:    10,
+			maxLines: -1,
+			maxSize:  8 * record,
+			rotate:   2,
+			want:     historyRecords(6, 10),
+			rotated:  []string{historyRecords(0, 10)},
+		},
+		{
+			name:     "Lines exceeded without rotation",
+			lines:    7,
+			maxLines: 4,
+			rotate:   2,
+			want:     historyRecords(3, 7),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := writeHistory(t, historyRecords(0, test.lines))
+
+			hist, err := NewSourceFromFile(file)
+			if err != nil {
+				t.Fatalf("NewSourceFromFile() error = %v", err)
+			}
+
+			fileHist := hist.(*fileHistory)
+			fileHist.maxLines, fileHist.maxSize, fileHist.rotate = test.maxLines, test.maxSize, test.rotate
+
+			if err = fileHist.Compact(); err != nil {
+				t.Fatalf("fileHistory.Compact() error = %v", err)
+			}
+
+			if got := readHistory(t, file); got != test.want {
+				t.Errorf("history file = %q, want %q", got, test.want)
+			}
+
+			// Lines are indexed from the first one kept.
+			if got, want := len(fileHist.lines), strings.Count(test.want, "\n"); got != want {
+				t.Errorf("fileHistory.Len() = %d, want %d", got, want)
+			}
+
+			for pos, item := range fileHist.lines {
+				if item.Index != pos {
+					t.Errorf("lines[%d].Index = %d, want %d", pos, item.Index, pos)
+				}
+			}
+
+			for num := 1; num <= test.rotate+1; num++ {
+				data, err := os.ReadFile(fileHist.rotated(num))
+
+				switch {
+				case num <= len(test.rotated) && (err != nil || string(data) != test.rotated[num-1]):
+					t.Errorf("rotated file %d = %q, %v, want %q", num, data, err, test.rotated[num-1])
+				case num > len(test.rotated) && err == nil:
+					t.Errorf("rotated file %d exists, want none", num)
+				}
+			}
+		})
+	}
+}
+
+func TestFileHistory_open(t *testing.T) {
+	record := int64(len(historyRecords(0, 1)))
+	result := `{"datetime":"2024-01-02T03:04:05Z","result":{"status":0}}` + "\n"
+
+	tests := []struct {
+		name     string
+		contents string
+		maxLines int
+		maxSize  int64
+		want     []string
+		compact  string
+	}{
+		{
+			name:     "No limits",
+			contents: historyRecords(0, 10),
+			maxLines: -1,
+			want:     strings.Split(strings.TrimSpace(historyRecords(0, 10)), "\n"),
+		},
+		{
+			name:     "Lines within the slack",
+			contents: historyRecords(0, 6),
+			maxLines: 4,
+			want:     strings.Split(strings.TrimSpace(historyRecords(0, 6)), "\n"),
+		},
+		{
+			name:     "Lines exceeding the slack",
+			contents: historyRecords(0, 10),
+			maxLines: 4,
+			want:     strings.Split(strings.TrimSpace(historyRecords(4, 10)), "\n"),
+			compact:  historyRecords(6, 10),
+		},
+		{
+			name:     "Results not counted",
+			contents: historyRecords(0, 4) + result + historyRecords(4, 7) + result,
+			maxLines: 4,
+			want:     strings.Split(strings.TrimSpace(historyRecords(1, 7)), "\n"),
+			compact: strings.Replace(historyRecords(3, 4), "}\n", `,"result":{"status":0}}`+"\n", 1) +
+				historyRecords(4, 6) + strings.Replace(historyRecords(6, 7), "}\n", `,"result":{"status":0}}`+"\n", 1),
+		},
+		{
+			name:     "Incomplete last line",
+			contents: historyRecords(0, 7) + `{"datetime":"2024-01-02T03:04:05Z","block":"echo 7"}`,
+			maxLines: 4,
+			want:     strings.Split(strings.TrimSpace(historyRecords(1, 7)), "\n"),
+			compact:  historyRecords(3, 7),
+		},
+		{
+			name:     "Lines in several chunks",
+			contents: historyRecords(0, 3000),
+			maxLines: 1000,
+			want:     strings.Split(strings.TrimSpace(historyRecords(1500, 3000)), "\n"),
+			compact:  historyRecords(2000, 3000),
+		},
+		{
+			name:     "Size exceeded",
+			contents: historyRecords(0, 10),
+			maxLines: -1,
+			maxSize:  4*record + 3,
+			want:     strings.Split(strings.TrimSpace(historyRecords(6, 10)), "\n"),
+			compact:  historyRecords(8, 10),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file := writeHistory(t, test.contents)
+
+			hist := &fileHistory{file: file, maxLines: test.maxLines, maxSize: test.maxSize}
+			if err := hist.open(); err != nil {
+				t.Fatalf("fileHistory.open() error = %v", err)
+			}
+
+			var got []string
+
+			for _, item := range hist.lines {
+				got = append(got, fmt.Sprintf(`{"datetime":"2024-01-02T03:04:05Z","block":%q}`, item.Block))
+			}
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("fileHistory.open() lines = %q, want %q", got, test.want)
+			}
+
+			// Files whose oldest lines have not been read are compacted.
+			if err := hist.Compact(); err != nil {
+				t.Fatalf("fileHistory.Compact() error = %v", err)
+			}
+
+			want := test.compact
+			if want == "" {
+				want = test.contents
+			}
+
+			if got := readHistory(t, file); got != want {
+				t.Errorf("history file = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestFileHistory_Rotate(t *testing.T) {
+	record := int64(len(historyRecords(0, 1)))
+	file := writeHistory(t, "")
+
+	hist, err := NewSourceFromFile(file)
+	if err != nil {
+		t.Fatalf("NewSourceFromFile() error = %v", err)
+	}
+
+	fileHist := hist.(*fileHistory)
+	fileHist.maxLines, fileHist.maxSize, fileHist.rotate = -1, 4*record, 2
+
+	// The file is rotated once its size is exceeded, after 5 lines, and compacted
+	// to half of it (2 lines), then again after 3 more lines: rotated files hold
+	// the whole file, including the lines still kept in the new one.
+	for num := 0; num < 10; num++ {
+		if _, err = hist.(EntrySource).WriteEntry(Entry{Line: fmt.Sprintf("echo %d", num), Time: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)}); err != nil {
+			t.Fatalf("fileHistory.WriteEntry() error = %v", err)
+		}
+	}
+
+	want := map[string]string{
+		file:                historyRecords(6, 10),
+		fileHist.rotated(1): historyRecords(3, 8),
+		fileHist.rotated(2): historyRecords(0, 5),
+	}
+
+	for path, contents := range want {
+		if data, err := os.ReadFile(path); err != nil || string(data) != contents {
+			t.Errorf("file %s = %q, %v, want %q", filepath.Base(path), data, err, contents)
+		}
+	}
+
+	if _, err = os.Stat(fileHist.rotated(3)); err == nil {
+		t.Errorf("rotated file 3 exists, want at most 2")
+	}
+}
@@ -1,5 +1,10 @@
 package history
 
+import (
+	"strings"
+	"time"
+)
+
 var defaultSourceName = "default history"
 
 // Source is an interface to allow you to write your own history logging tools.
@@ -24,10 +29,62 @@ type Source interface {
 	Dump() interface{}
 }
 
+// EntrySource is a history source storing the metadata of its lines, along with them.
+// Lines are written to such sources with WriteEntry instead of Write, and the result
+// of their command can be attached to them once it has run (see Sources.Finish).
+// Both the file and in-memory history sources implement this interface.
+type EntrySource interface {
+	Source
+
+	// WriteEntry writes a line with its metadata, and returns the updated number of lines.
+	WriteEntry(entry Entry) (int, error)
+
+	// GetEntry returns the line at the given index, along with its metadata.
+	GetEntry(pos int) (Entry, error)
+
+	// SetResult attaches the result of its command to the line at the given index.
+	SetResult(pos int, result Result) error
+}
+
+// Reloader is a history source which can be shared by several shells, like the file
+// source: before walking or searching it, the lines written by other shells since it
+// has last been read are read with Reload, like the share_history option of zsh does.
+type Reloader interface {
+	Source
+
+	// Reload reads the lines written by other shells since the source has been read.
+	Reload() error
+}
+
+// EraseSource is a history source from which lines can be removed, like older copies
+// of the lines written when the history policy erases duplicates (erasedups).
+type EraseSource interface {
+	Source
+
+	// Erase removes all lines identical to the given one.
+	Erase(line string) error
+}
+
+// Entry is a history line, along with the metadata of the command it has been run as.
+type Entry struct {
+	Line      string    // The command line.
+	Time      time.Time // When the line has been accepted.
+	Directory string    // Working directory of the shell when the line has been accepted.
+	Session   string    // Identifier of the shell session in which the line has been accepted.
+	Result    *Result   // Result of the command, or nil if not known (yet).
+}
+
+// Result is the result of the command run for a history line.
+type Result struct {
+	Status   int               `json:"status"`             // Exit status of the command.
+	Duration time.Duration     `json:"duration,omitempty"` // Run time of the command.
+	Tags     map[string]string `json:"tags,omitempty"`     // Arbitrary metadata.
+}
+
 // memory is an in memory history.
 // One such history is bound to the readline shell by default.
 type memory struct {
-	items []string
+	items []Entry
 }
 
 // NewInMemoryHistory creates a new in-memory command history source.
@@ -37,7 +94,12 @@ func NewInMemoryHistory() Source {
 
 // Write to history.
 func (h *memory) Write(s string) (int, error) {
-	h.items = append(h.items, s)
+	return h.WriteEntry(Entry{Line: s, Time: time.Now()})
+}
+
+// WriteEntry writes a line to history, with its metadata.
+func (h *memory) WriteEntry(entry Entry) (int, error) {
+	h.items = append(h.items, entry)
 	return len(h.items), nil
 }
 
@@ -47,9 +109,44 @@ func (h *memory) GetLine(i int) (string, error) {
 		return "", nil
 	}
 
+	return h.items[i].Line, nil
+}
+
+// GetEntry returns a line from history, with its metadata.
+func (h *memory) GetEntry(i int) (Entry, error) {
+	if i < 0 || i >= len(h.items) {
+		return Entry{}, errOutOfRangeIndex
+	}
+
 	return h.items[i], nil
 }
 
+// SetResult attaches the result of its command to a line.
+func (h *memory) SetResult(i int, result Result) error {
+	if i < 0 || i >= len(h.items) {
+		return errOutOfRangeIndex
+	}
+
+	h.items[i].Result = &result
+
+	return nil
+}
+
+// Erase removes all lines identical to the given one from history.
+func (h *memory) Erase(line string) error {
+	kept := h.items[:0]
+
+	for _, item := range h.items {
+		if strings.TrimSpace(item.Line) != strings.TrimSpace(line) {
+			kept = append(kept, item)
+		}
+	}
+
+	h.items = kept
+
+	return nil
+}
+
 // Len returns the number of lines in history.
 func (h *memory) Len() int {
 	return len(h.items)
@@ -57,5 +154,21 @@ func (h *memory) Len() int {
 
 // Dump returns the entire history.
 func (h *memory) Dump() interface{} {
-	return h.items
+	lines := make([]string, len(h.items))
+	for i, item := range h.items {
+		lines[i] = item.Line
+	}
+
+	return lines
+}
+
+// getEntry returns a line of a source with its metadata, if the source stores them.
+func getEntry(source Source, pos int) (Entry, error) {
+	if entries, ok := source.(EntrySource); ok {
+		return entries.GetEntry(pos)
+	}
+
+	line, err := source.GetLine(pos)
+
+	return Entry{Line: line}, err
 }
@@ -0,0 +1,11 @@
+//go:build (!unix && !windows) || aix
+
+package history
+
+import "os"
+
+// lockFile does nothing, since files cannot be locked on this platform.
+func lockFile(*os.File) error { return nil }
+
+// unlockFile does nothing, since files cannot be locked on this platform.
+func unlockFile(*os.File) error { return nil }
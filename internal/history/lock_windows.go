@@ -0,0 +1,30 @@
+//go:build windows
+
+package history
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// Locks on Windows are mandatory, so the byte locked is far beyond
+// the end of the file, where it does not prevent reading the file.
+const (
+	lockOffset     = ^uint32(0)
+	lockOffsetHigh = ^uint32(0) >> 1
+)
+
+// lockFile waits for an exclusive lock on a file.
+func lockFile(file *os.File) error {
+	overlapped := &windows.Overlapped{Offset: lockOffset, OffsetHigh: lockOffsetHigh}
+
+	return windows.LockFileEx(windows.Handle(file.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK, 0, 1, 0, overlapped)
+}
+
+// unlockFile releases the lock on a file.
+func unlockFile(file *os.File) error {
+	overlapped := &windows.Overlapped{Offset: lockOffset, OffsetHigh: lockOffsetHigh}
+
+	return windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, overlapped)
+}
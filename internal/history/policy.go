@@ -0,0 +1,121 @@
+package history
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Policy decides which accepted lines are written to history sources, like the HISTCONTROL
+// and HISTIGNORE variables of Bash. It applies to all sources, and by default, only lines
+// identical to the last one are not written (ignoredups).
+type Policy struct {
+	IgnoreSpace bool // Lines starting with a space are not written (ignorespace).
+	IgnoreDups  bool // Lines identical to the last one are not written (ignoredups).
+	EraseDups   bool // Older copies of lines are removed from sources supporting it (erasedups).
+
+	// Lines matching any of those patterns are not written. Glob patterns match
+	// whole lines, with '*' matching any string, '?' any character, and brackets
+	// any character in them (HISTIGNORE). Regexp patterns match parts of lines.
+	Ignore       []string
+	IgnoreRegexp []*regexp.Regexp
+
+	// IgnoreFunc returns true for lines which must not be written, if not nil.
+	IgnoreFunc func(line string) bool
+}
+
+// ignores returns true if the line must not be written to any source.
+func (p Policy) ignores(line string) bool {
+	if p.IgnoreSpace && strings.HasPrefix(line, " ") {
+		return true
+	}
+
+	for _, pattern := range p.Ignore {
+		if globRegexp(pattern).MatchString(line) {
+			return true
+		}
+	}
+
+	for _, pattern := range p.IgnoreRegexp {
+		if pattern != nil && pattern.MatchString(line) {
+			return true
+		}
+	}
+
+	return p.IgnoreFunc != nil && p.IgnoreFunc(line)
+}
+
+// globRegexp returns the regular expression matching whole lines matched by a glob pattern.
+// If the brackets of the pattern are invalid, it only matches lines identical to it.
+func globRegexp(pattern string) *regexp.Regexp {
+	var expr strings.Builder
+
+	expr.WriteString("^(?s:")
+
+	for pos := 0; pos < len(pattern); pos++ {
+		switch char := pattern[pos]; char {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		case '[':
+			end := classEnd(pattern, pos)
+			if end == -1 {
+				expr.WriteString(`\[`)
+				continue
+			}
+
+			class := pattern[pos+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			expr.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			pos = end
+		case '\\':
+			if pos+1 < len(pattern) {
+				pos++
+			}
+
+			expr.WriteString(regexp.QuoteMeta(pattern[pos : pos+1]))
+		default:
+			expr.WriteString(regexp.QuoteMeta(pattern[pos : pos+1]))
+		}
+	}
+
+	expr.WriteString(")$")
+
+	glob, err := regexp.Compile(expr.String())
+	if err != nil {
+		return regexp.MustCompile("^" + regexp.QuoteMeta(pattern) + "$")
+	}
+
+	return glob
+}
+
+// classEnd returns the position of the bracket closing the class opened at pos in a glob
+// pattern, or -1 if it is not closed. Like in Bash, a closing bracket right after the opening
+// one (or after its negation) is part of the class, as are those of character classes ([:alpha:]).
+func classEnd(pattern string, pos int) int {
+	pos++
+
+	if pos < len(pattern) && (pattern[pos] == '!' || pattern[pos] == '^') {
+		pos++
+	}
+
+	if pos < len(pattern) && pattern[pos] == ']' {
+		pos++
+	}
+
+	for ; pos < len(pattern); pos++ {
+		switch {
+		case pattern[pos] == ']':
+			return pos
+		case strings.HasPrefix(pattern[pos:], "[:"):
+			if end := strings.Index(pattern[pos+2:], ":]"); end != -1 {
+				pos += end + 3
+			}
+		}
+	}
+
+	return -1
+}
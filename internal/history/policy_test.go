@@ -0,0 +1,76 @@
+package history
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGlobRegexp(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		line    string
+		want    bool
+	}{
+		{name: "Whole line", pattern: "ls", line: "ls", want: true},
+		{name: "Part of a line", pattern: "ls", line: "ls -l", want: false},
+		{name: "Any string", pattern: "git *", line: "git commit -m 'a\nb'", want: true},
+		{name: "Any character", pattern: "l?", line: "ll", want: true},
+		{name: "Any character is not empty", pattern: "l?", line: "l", want: false},
+		{name: "Regexp characters", pattern: "a.b+(c)", line: "a.b+(c)", want: true},
+		{name: "Regexp characters are literal", pattern: "a.b", line: "axb", want: false},
+		{name: "Bracket", pattern: "[bc]d", line: "cd", want: true},
+		{name: "Bracket range", pattern: "echo [0-9]", line: "echo 7", want: true},
+		{name: "Negated bracket", pattern: "[!x]y", line: "xy", want: false},
+		{name: "Negated bracket match", pattern: "[!x]y", line: "zy", want: true},
+		{name: "Closing bracket in bracket", pattern: "[]x]", line: "]", want: true},
+		{name: "Closing bracket in negated bracket", pattern: "[!]]", line: "]", want: false},
+		{name: "Character class", pattern: "echo [[:digit:]]", line: "echo 7", want: true},
+		{name: "Character class mismatch", pattern: "echo [[:digit:]]", line: "echo x", want: false},
+		{name: "Backslash in bracket", pattern: `[\]`, line: `\`, want: true},
+		{name: "Unterminated bracket", pattern: "ls [a", line: "ls [a", want: true},
+		{name: "Unterminated bracket is literal", pattern: "ls [a", line: "ls a", want: false},
+		{name: "Escaped star", pattern: `echo \*`, line: "echo *", want: true},
+		{name: "Escaped star is literal", pattern: `echo \*`, line: "echo a", want: false},
+		{name: "Escaped bracket", pattern: `\[a]`, line: "[a]", want: true},
+		{name: "Escaped backslash", pattern: `a\\b`, line: `a\b`, want: true},
+		{name: "Trailing backslash", pattern: `a\`, line: `a\`, want: true},
+		{name: "Invalid bracket", pattern: "[z-a]", line: "[z-a]", want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := globRegexp(test.pattern).MatchString(test.line); got != test.want {
+				t.Errorf("globRegexp(%q).MatchString(%q) = %v, want %v", test.pattern, test.line, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_ignores(t *testing.T) {
+	policy := Policy{
+		IgnoreSpace:  true,
+		Ignore:       []string{"ls", "exit"},
+		IgnoreRegexp: []*regexp.Regexp{regexp.MustCompile(`^rm -rf`), nil},
+		IgnoreFunc:   func(line string) bool { return len(line) > 20 },
+	}
+
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{line: " secret", want: true},
+		{line: "ls", want: true},
+		{line: "ls -la", want: false},
+		{line: "rm -rf /tmp/x", want: true},
+		{line: "echo rm -rf", want: false},
+		{line: "echo a very long command line", want: true},
+		{line: "cd /", want: false},
+	}
+
+	for _, test := range tests {
+		if got := policy.ignores(test.line); got != test.want {
+			t.Errorf("Policy.ignores(%q) = %v, want %v", test.line, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package history
+
+import (
+	"regexp"
+	"strings"
+)
+
+// RedactPlaceholder replaces secrets in lines written to history sources, by default.
+const RedactPlaceholder = "<redacted>"
+
+// Secret is a detector of secrets in lines written to history sources. If its
+// pattern has a group, only the text matched by the first one is redacted (like
+// the value of a password= argument), otherwise the entire match is redacted.
+type Secret struct {
+	Name    string
+	Pattern *regexp.Regexp
+}
+
+// Values of secret arguments, which might be quoted, and names of
+// these arguments: they are only detected when assigned a value,
+// so that commands like "grep token: file" are not redacted.
+const (
+	secretValue = `("[^"]*"|'[^']*'|[^\s"']+)`
+	quotedValue = `("[^"]*"|'[^']*')`
+	secretNames = `(?:passw(?:or)?d|secret|token|api[_-]?key)`
+)
+
+// defaultSecrets are the secrets redacted by default.
+var defaultSecrets = []Secret{
+	{"aws-access-key", regexp.MustCompile(`\b((?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16})\b`)},
+	{"aws-secret-key", regexp.MustCompile(`(?i)aws_?secret_?access_?key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`)},
+	{"jwt", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)},
+	{"bearer-token", regexp.MustCompile(`(?i)\bbearer\s+([A-Za-z0-9._~+/-]+=*)`)},
+	{"password", regexp.MustCompile(`(?i)` + secretNames + `=` + secretValue)},
+	{"password-quoted", regexp.MustCompile(`(?i)` + secretNames + `["']?\s*[=:]\s*` + quotedValue)},
+	{"password-flag", regexp.MustCompile(`(?i)(?:^|\s)--?(?:passw(?:or)?d|secret|token|api-key)\s+` + secretValue)},
+	{"private-key", regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY-----(?s:.*?)-----END [A-Z0-9 ]*PRIVATE KEY-----`)},
+}
+
+// DefaultSecrets returns the detectors of secrets redacted by default: AWS keys, JSON Web
+// Tokens, bearer tokens, PEM private keys, and values assigned to password/secret/token/api-key
+// arguments, either as flags (--password value), assignments (PASSWORD=value) or quoted values
+// (password: "value").
+func DefaultSecrets() []Secret {
+	return append([]Secret(nil), defaultSecrets...)
+}
+
+// Redaction redacts secrets (credentials, tokens, private keys...) from lines before they are
+// written to any history source. The line returned to the caller, and the one being edited,
+// are not redacted. By default, the default secrets are replaced with RedactPlaceholder.
+type Redaction struct {
+	Secrets     []Secret // Detectors of secrets: none are redacted if empty.
+	Placeholder string   // Replaces secrets, or RedactPlaceholder if empty.
+	Drop        bool     // Lines with secrets are not written at all, instead of being redacted.
+}
+
+// redact returns the line with its secrets replaced with the placeholder,
+// and whether it has any: the line must not be written if they are dropped.
+func (r Redaction) redact(line string) (redacted string, found bool) {
+	placeholder := r.Placeholder
+	if placeholder == "" {
+		placeholder = RedactPlaceholder
+	}
+
+	for _, secret := range r.Secrets {
+		if secret.Pattern == nil {
+			continue
+		}
+
+		matches := secret.Pattern.FindAllStringSubmatchIndex(line, -1)
+		if len(matches) == 0 {
+			continue
+		}
+
+		var replaced strings.Builder
+		last := 0
+
+		for _, match := range matches {
+			start, end := match[0], match[1]
+			if len(match) > 3 && match[2] >= 0 {
+				start, end = match[2], match[3]
+			}
+
+			replaced.WriteString(line[last:start] + placeholder)
+			last = end
+		}
+
+		replaced.WriteString(line[last:])
+		line, found = replaced.String(), true
+	}
+
+	return line, found
+}
@@ -0,0 +1,88 @@
+package history
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRedaction_redact(t *testing.T) {
+	tests := []struct {
+		name      string
+		redaction Redaction
+		line      string
+		want      string
+		wantFound bool
+	}{
+		{
+			name:      "Group is redacted",
+			redaction: Redaction{Secrets: []Secret{{"pin", regexp.MustCompile(`--pin (\d+)`)}}},
+			line:      "unlock --pin 1234 --pin 5678",
+			want:      "unlock --pin <redacted> --pin <redacted>",
+			wantFound: true,
+		},
+		{
+			name:      "Whole match is redacted",
+			redaction: Redaction{Secrets: []Secret{{"pin", regexp.MustCompile(`pin-\d+`)}}},
+			line:      "unlock pin-1234",
+			want:      "unlock <redacted>",
+			wantFound: true,
+		},
+		{
+			name:      "Optional group not matched",
+			redaction: Redaction{Secrets: []Secret{{"pin", regexp.MustCompile(`pin(?:=(\d+))?`)}}},
+			line:      "unlock pin",
+			want:      "unlock <redacted>",
+			wantFound: true,
+		},
+		{
+			name:      "Placeholder",
+			redaction: Redaction{Secrets: []Secret{{"pin", regexp.MustCompile(`\d+`)}}, Placeholder: "***"},
+			line:      "unlock 1234",
+			want:      "unlock ***",
+			wantFound: true,
+		},
+		{
+			name:      "Detectors without pattern",
+			redaction: Redaction{Secrets: []Secret{{Name: "none"}}},
+			line:      "unlock 1234",
+			want:      "unlock 1234",
+		},
+		{
+			name: "No secrets",
+			line: "unlock 1234",
+			want: "unlock 1234",
+		},
+		{
+			name:      "Default secrets",
+			redaction: Redaction{Secrets: DefaultSecrets()},
+			line:      `mysql --password=hunter2 -u root; curl -d '{"token": "abc"}'; export API_KEY=xyz`,
+			want:      `mysql --password=<redacted> -u root; curl -d '{"token": <redacted>}'; export API_KEY=<redacted>`,
+			wantFound: true,
+		},
+		{
+			name:      "Default secrets without values",
+			redaction: Redaction{Secrets: DefaultSecrets()},
+			line:      "grep token: config.yml; kubectl get secret: foo; grep -r password= src; echo $TOKEN",
+			want:      "grep token: config.yml; kubectl get secret: foo; grep -r password= src; echo $TOKEN",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, found := test.redaction.redact(test.line)
+			if got != test.want || found != test.wantFound {
+				t.Errorf("Redaction.redact() = %q, %v, want %q, %v", got, found, test.want, test.wantFound)
+			}
+		})
+	}
+}
+
+func TestDefaultSecrets(t *testing.T) {
+	// Changing the returned detectors does not change the default ones.
+	secrets := DefaultSecrets()
+	secrets[0].Pattern = nil
+
+	if DefaultSecrets()[0].Pattern == nil {
+		t.Errorf("DefaultSecrets()[0].Pattern = nil, want the default pattern")
+	}
+}
@@ -2,9 +2,11 @@ package history
 
 import (
 	"fmt"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/reeflective/readline/inputrc"
 	"github.com/reeflective/readline/internal/color"
@@ -22,12 +24,11 @@ type Sources struct {
 	config *inputrc.Config
 
 	// History sources
-	list       map[string]Source // Sources of history lines
-	names      []string          // Names of histories stored in rl.histories
-	maxEntries int               // Inputrc configured maximum number of entries.
-	sourcePos  int               // The index of the currently used history
-	hpos       int               // Index used for navigating the history lines with arrows/j/k
-	cpos       int               // A temporary cursor position used when searching/moving around.
+	list      map[string]Source // Sources of history lines
+	names     []string          // Names of histories stored in rl.histories
+	sourcePos int               // The index of the currently used history
+	hpos      int               // Index used for navigating the history lines with arrows/j/k
+	cpos      int               // A temporary cursor position used when searching/moving around.
 
 	// Line changes history
 	skip    bool                            // Skip saving the current line state.
@@ -41,6 +42,17 @@ type Sources struct {
 	acceptHold bool      // Should we reuse the same accepted line on the next loop.
 	acceptLine core.Line // The line to return to the caller.
 	acceptErr  error     // An error to return to the caller.
+	secret     bool      // The line is a secret, and is never written nor saved.
+
+	// Lines written
+	policy    Policy    // Lines not written to sources, and duplicates erased.
+	redaction Redaction // Secrets redacted from lines before writing them.
+
+	// Lines metadata
+	session    string           // Identifier of the shell session, stored with lines.
+	filter     func(Entry) bool // Lines matched by searches and completions, if not all.
+	acceptTime time.Time        // When the last line written has been accepted.
+	written    map[string]int   // Index of the last line written, for each source storing results.
 }
 
 // NewSources is a required constructor for the history sources manager type.
@@ -57,21 +69,17 @@ func NewSources(line *core.Line, cur *core.Cursor, hint *ui.Hint, opts *inputrc.
 		hpos:   -1,
 		hint:   hint,
 		config: opts,
+		// Lines written
+		policy:    Policy{IgnoreDups: true},
+		redaction: Redaction{Secrets: DefaultSecrets()},
+		// Lines metadata
+		session: newSession(),
+		written: make(map[string]int),
 	}
 
 	sources.names = append(sources.names, defaultSourceName)
 	sources.list[defaultSourceName] = new(memory)
 
-	// Inputrc settings.
-	sources.maxEntries = opts.GetInt("history-size")
-	sizeSet := opts.GetString("history-size") != ""
-
-	if sources.maxEntries == 0 && !sizeSet {
-		sources.maxEntries = -1
-	} else if sources.maxEntries == 0 && sizeSet {
-		sources.maxEntries = 500
-	}
-
 	return sources
 }
 
@@ -113,6 +121,85 @@ func Init(hist *Sources) {
 	hist.infer = false
 }
 
+// SetSecret is used when the shell reads a secret (like a password): the line is
+// neither written to history sources nor saved as undo states, and no history line
+// is suggested for it. When the secret mode is left, the accepted line is zeroed.
+func SetSecret(hist *Sources, secret bool) {
+	hist.secret = secret
+
+	if !secret {
+		clear(hist.acceptLine)
+		hist.acceptHold = false
+		hist.acceptLine = nil
+	}
+}
+
+// SetSession sets the identifier of the shell session, which is stored with the lines
+// written to the sources storing metadata. By default, it is unique to each shell.
+func (h *Sources) SetSession(id string) {
+	h.session = id
+}
+
+// Session returns the identifier of the shell session.
+func (h *Sources) Session() string {
+	return h.session
+}
+
+// SetPolicy sets the policy deciding which accepted lines are written to history sources,
+// and whether their older copies are removed, like HISTCONTROL and HISTIGNORE in Bash.
+func (h *Sources) SetPolicy(policy Policy) {
+	h.policy = policy
+}
+
+// SetRedaction sets how secrets are redacted from lines before writing them to history
+// sources: redaction is disabled with an empty Redaction, which has no secret detectors.
+func (h *Sources) SetRedaction(redaction Redaction) {
+	h.redaction = redaction
+}
+
+// AddSecret adds a detector of secrets redacted from lines before writing them to
+// history sources (see Secret for the part of the matches which is redacted).
+func (h *Sources) AddSecret(name string, pattern *regexp.Regexp) {
+	h.redaction.Secrets = append(h.redaction.Secrets, Secret{Name: name, Pattern: pattern})
+}
+
+// SetFilter restricts the lines matched by history searches, suggestions and completions
+// to those for which the filter returns true, like the lines whose command has succeeded
+// in the current directory: lines of sources without metadata only have their Line field.
+// A nil filter matches all lines again.
+func (h *Sources) SetFilter(filter func(entry Entry) bool) {
+	h.filter = filter
+}
+
+// Finish attaches the result of the command run for the last line accepted (its exit status,
+// its duration since the line was accepted, and any tags) to the sources storing metadata,
+// and returns the first error of those sources, if any. It should be called once the command
+// has run, and before reading the next line: it does nothing if the line was not written.
+func (h *Sources) Finish(status int, tags map[string]string) error {
+	result := Result{
+		Status:   status,
+		Duration: time.Since(h.acceptTime),
+		Tags:     tags,
+	}
+
+	var err error
+
+	for name, pos := range h.written {
+		source, ok := h.list[name].(EntrySource)
+		if !ok {
+			continue
+		}
+
+		if setErr := source.SetResult(pos, result); setErr != nil && err == nil {
+			err = setErr
+		}
+	}
+
+	h.written = make(map[string]int)
+
+	return err
+}
+
 // Add adds a source of history lines bound to a given name (printed above this source when used).
 // If the shell currently has only an in-memory (default) history source available, the call will
 // drop this source and replace it with the provided one. Following calls add to the list.
@@ -124,14 +211,27 @@ func (h *Sources) Add(name string, hist Source) {
 
 	h.names = append(h.names, name)
 	h.list[name] = hist
+
+	// Files exceeding the limits are compacted
+	// now, so that they are read faster next time.
+	if file, isFile := hist.(*fileHistory); isFile {
+		h.limit(file)
+
+		if err := file.Compact(); err != nil {
+			h.hint.Set(color.FgRed + "history error: " + err.Error())
+		}
+	}
 }
 
 // AddFromFile adds a command history source from a file path.
 // The name is used when using/searching the history source.
 func (h *Sources) AddFromFile(name, file string) {
-	hist := new(fileHistory)
-	hist.file = file
-	hist.lines, _ = openHist(file)
+	hist := &fileHistory{file: file}
+
+	// Limits are set before reading the file,
+	// so that only its recent lines are read.
+	h.limit(hist)
+	hist.open()
 
 	h.Add(name, hist)
 }
@@ -168,6 +268,7 @@ func (h *Sources) Delete(sources ...string) {
 // If at the end of it, the main input buffer and cursor position is restored.
 func (h *Sources) Walk(pos int) {
 	history := h.Current()
+	h.reload(history)
 
 	if history == nil || history.Len() == 0 {
 		return
@@ -292,6 +393,10 @@ func (h *Sources) Current() Source {
 // If infer is true, the next history initialization will automatically insert the next
 // history line event after the first match of the line, which one is then NOT written.
 func (h *Sources) Write(infer bool) {
+	if h.secret {
+		return
+	}
+
 	if infer {
 		h.infer = true
 		return
@@ -299,31 +404,67 @@ func (h *Sources) Write(infer bool) {
 
 	line := string(*h.line)
 
-	if len(strings.TrimSpace(line)) == 0 {
+	// Results of previous lines cannot be attached anymore.
+	h.written = make(map[string]int)
+
+	if len(strings.TrimSpace(line)) == 0 || h.policy.ignores(line) {
+		return
+	}
+
+	// Secrets are redacted from the line written only.
+	line, hasSecrets := h.redaction.redact(line)
+	if hasSecrets && h.redaction.Drop {
 		return
 	}
 
-	for _, history := range h.list {
+	h.acceptTime = time.Now()
+	entry := Entry{
+		Line:    line,
+		Time:    h.acceptTime,
+		Session: h.session,
+	}
+	entry.Directory, _ = os.Getwd()
+
+	for name, history := range h.list {
 		if history == nil {
 			continue
 		}
 
-		// Don't write it if the history source has reached
-		// the maximum number of lines allowed (inputrc)
-		if h.maxEntries == 0 || h.maxEntries >= history.Len() {
+		// Don't write it if no lines are kept (inputrc),
+		// and apply the limits before writing to files.
+		if h.maxEntries() == 0 {
 			continue
 		}
 
+		if file, isFile := history.(*fileHistory); isFile {
+			h.limit(file)
+		}
+
 		var err error
 
-		// Don't write the line if it's identical to the last one.
+		// Don't write the line if it's identical to the last one,
+		// and remove its older copies if duplicates are erased.
 		last, err := history.GetLine(history.Len() - 1)
-		if err == nil && last != "" && strings.TrimSpace(last) == strings.TrimSpace(line) {
-			return
+		if h.policy.IgnoreDups && err == nil && last != "" && strings.TrimSpace(last) == strings.TrimSpace(line) {
+			continue
+		}
+
+		if eraser, ok := history.(EraseSource); ok && h.policy.EraseDups {
+			if err = eraser.Erase(line); err != nil {
+				h.hint.Set(color.FgRed + err.Error())
+			}
 		}
 
 		// Save the line and notify through hints if an error raised.
-		_, err = history.Write(line)
+		if entries, ok := history.(EntrySource); ok {
+			var count int
+			if count, err = entries.WriteEntry(entry); err == nil {
+				h.written[name] = count - 1
+			}
+		} else {
+			_, err = history.Write(line)
+		}
+
 		if err != nil {
 			h.hint.Set(color.FgRed + err.Error())
 		}
@@ -452,7 +593,7 @@ func (h *Sources) InferNext() {
 // so that caller can use for things like history autosuggestion.
 // If no line matches the current line, it will return the latter.
 func (h *Sources) Suggest(line *core.Line) core.Line {
-	if len(h.list) == 0 || len(*line) == 0 {
+	if len(h.list) == 0 || len(*line) == 0 || h.secret {
 		return *line
 	}
 
@@ -482,6 +623,7 @@ func Complete(h *Sources, forward, filter bool, maxLines int, regex *regexp.Rege
 		return completion.Values{}
 	}
 
+	h.reload(history)
 	h.hint.Set(color.Bold + color.FgCyanBright + h.names[h.sourcePos] + color.Reset)
 
 	compLines := make([]completion.Candidate, 0)
@@ -520,6 +662,8 @@ func Complete(h *Sources, forward, filter bool, maxLines int, regex *regexp.Rege
 			continue
 		} else if regex != nil && !regex.MatchString(line) {
 			continue
+		} else if !h.matchFilter(history, histPos) {
+			continue
 		}
 
 		display := strings.ReplaceAll(line, "\n", ` `)
@@ -562,6 +706,8 @@ func (h *Sources) match(match *core.Line, cur *core.Cursor, usePos, fwd, regex b
 		return
 	}
 
+	h.reload(history)
+
 	// Set up iteration clauses
 	var histPos int
 	var done func(i int) bool
@@ -615,6 +761,10 @@ func (h *Sources) match(match *core.Line, cur *core.Cursor, usePos, fwd, regex b
 			}
 		}
 
+		if !h.matchFilter(history, histPos) {
+			continue
+		}
+
 		// Else we have our history match.
 		return histline, histPos, true
 	}
@@ -623,6 +773,60 @@ func (h *Sources) match(match *core.Line, cur *core.Cursor, usePos, fwd, regex b
 	return "", 0, false
 }
 
+// matchFilter returns true if the line of a source at the given index is matched by the
+// filter, if any. Lines which cannot be read with their metadata are not matched.
+func (h *Sources) matchFilter(history Source, pos int) bool {
+	if h.filter == nil {
+		return true
+	}
+
+	entry, err := getEntry(history, pos)
+
+	return err == nil && h.filter(entry)
+}
+
+// maxEntries returns the maximum number of lines kept in history sources (history-size
+// option), or -1 if not limited: like Bash, it is 500 if the option has an invalid value.
+func (h *Sources) maxEntries() int {
+	maxEntries := h.config.GetInt("history-size")
+	sizeSet := h.config.GetString("history-size") != ""
+
+	if maxEntries == 0 && !sizeSet {
+		return -1
+	} else if maxEntries == 0 && sizeSet {
+		return 500
+	}
+
+	return maxEntries
+}
+
+// limit sets the limits of a history file (history-size, history-file-max-size and
+// history-file-rotate options), which are enforced each time a line is written to it.
+func (h *Sources) limit(file *fileHistory) {
+	file.maxLines = h.maxEntries()
+	file.maxSize = int64(h.config.GetInt("history-file-max-size"))
+	file.rotate = h.config.GetInt("history-file-rotate")
+}
+
+// reload reads the lines written to a source by other processes since it has been read, if
+// it supports it (like file sources), so that the lines of all shells sharing it can be used.
+// This is not done while walking the history, since positions are relative to its last line.
+func (h *Sources) reload(history Source) {
+	source, isReloader := history.(Reloader)
+	if !isReloader || h.hpos > 0 {
+		return
+	}
+
+	if err := source.Reload(); err != nil {
+		h.hint.Set(color.FgRed + "history error: " + err.Error())
+	}
+}
+
+// newSession returns a session identifier unique to the process and to the time it is called.
+func newSession() string {
+	return strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
 // use the "main buffer" and its cursor if no line/cursor has been provided to match against.
 func (h *Sources) getLine(line *core.Line, cur *core.Cursor) (*core.Line, *core.Cursor) {
 	if h.hpos == -1 {
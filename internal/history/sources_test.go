@@ -0,0 +1,83 @@
+package history
+
+import (
+	"testing"
+
+	"github.com/reeflective/readline/inputrc"
+	"github.com/reeflective/readline/internal/core"
+	"github.com/reeflective/readline/internal/ui"
+)
+
+// newTestSources returns history sources with an in-memory source holding the given entries.
+func newTestSources(t *testing.T, entries ...Entry) *Sources {
+	t.Helper()
+
+	line := new(core.Line)
+	hist := NewSources(line, core.NewCursor(line), new(ui.Hint), inputrc.NewDefaultConfig())
+
+	for _, entry := range entries {
+		if _, err := hist.Current().(EntrySource).WriteEntry(entry); err != nil {
+			t.Fatalf("memory.WriteEntry() error = %v", err)
+		}
+	}
+
+	return hist
+}
+
+func TestSources_SetFilter(t *testing.T) {
+	hist := newTestSources(t,
+		Entry{Line: "make test", Directory: "/src", Result: &Result{Status: 0}},
+		Entry{Line: "make build", Directory: "/src", Result: &Result{Status: 2}},
+		Entry{Line: "make clean", Directory: "/tmp", Result: &Result{Status: 0}},
+		Entry{Line: "make run", Directory: "/src"},
+	)
+
+	tests := []struct {
+		name     string
+		filter   func(Entry) bool
+		wantLine string
+		wantPos  int
+	}{
+		{
+			name:     "No filter",
+			wantLine: "make run",
+			wantPos:  3,
+		},
+		{
+			name:     "Succeeded",
+			filter:   func(entry Entry) bool { return entry.Result != nil && entry.Result.Status == 0 },
+			wantLine: "make clean",
+			wantPos:  2,
+		},
+		{
+			name: "Succeeded in directory",
+			filter: func(entry Entry) bool {
+				return entry.Directory == "/src" && entry.Result != nil && entry.Result.Status == 0
+			},
+			wantLine: "make test",
+			wantPos:  0,
+		},
+		{
+			name:    "No line matched",
+			filter:  func(entry Entry) bool { return false },
+			wantPos: -1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			hist.SetFilter(test.filter)
+
+			search := core.Line("make")
+
+			line, pos, found := hist.match(&search, nil, false, false, false)
+			if !found {
+				pos = -1
+			}
+
+			if line != test.wantLine || pos != test.wantPos {
+				t.Errorf("Sources.match() = %q, %d, want %q, %d", line, pos, test.wantLine, test.wantPos)
+			}
+		})
+	}
+}
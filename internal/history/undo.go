@@ -23,7 +23,7 @@ type undoItem struct {
 func (h *Sources) Save() {
 	defer h.Reset()
 
-	if h.skip {
+	if h.skip || h.secret {
 		return
 	}
 
@@ -34,6 +34,7 @@ var isearchCommands = []string{
 	"vi-movement-mode",
 	"yank",
 	"self-insert",
+	"bracketed-paste-begin",
 
 	// History
 	"accept-and-infer-next-history",
@@ -64,6 +65,7 @@ var nonIsearchCommands = []string{
 	"unix-word-rubout",
 	"vi-unix-word-rubout",
 	"self-insert",
+	"bracketed-paste-begin",
 }
 
 // getContextBinds is in charge of returning the precise list of binds
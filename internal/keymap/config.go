@@ -1,29 +1,40 @@
 package keymap
 
 import (
-	"fmt"
 	"os"
 	"os/user"
 	"sort"
 	"strings"
 
 	"github.com/reeflective/readline/inputrc"
+	"github.com/reeflective/readline/internal/core"
+	"github.com/reeflective/readline/internal/term"
 )
 
 // readline global options specific to this library.
 var readlineOptions = map[string]interface{}{
 	// General edition
-	"autopairs": false,
+	"autopairs":            false,
+	"enable-extended-keys": false,
+	"enable-mouse":         false,
+	"kill-ring-clipboard":  false,
 
 	// Completion
 	"autocomplete":               false,
 	"completion-list-separator":  "--",
 	"completion-selection-style": "\x1b[1;30m",
 
+	// History
+	"history-file-max-size": 0,
+	"history-file-rotate":   0,
+
 	// Prompt & General UI
 	"transient-prompt":    false,
 	"usage-hint-always":   false,
 	"history-autosuggest": false,
+
+	// Terminal integration
+	"enable-shell-integration": false,
 }
 
 // ReloadConfig parses all valid .inputrc configurations and immediately
@@ -66,6 +77,8 @@ func (m *Engine) ReloadConfig(opts ...inputrc.Option) (err error) {
 	m.overrideBindsSpecial()
 
 	// Startup editing mode
+	defer m.notify(m.current())
+
 	switch m.config.GetString("editing-mode") {
 	case "emacs":
 		m.main = Emacs
@@ -112,9 +125,14 @@ func (m *Engine) loadBuiltinBinds() {
 	m.config.Binds[string(MenuSelect)] = menuselectKeys
 	m.config.Binds[string(Isearch)] = menuselectKeys
 
-	// Default TTY binds
+	// Default TTY binds, and mouse events (see core.MouseKey).
 	for _, keymap := range m.config.Binds {
 		keymap[inputrc.Unescape(`\C-C`)] = inputrc.Bind{Action: "abort"}
+		keymap[core.MouseKey] = inputrc.Bind{Action: "mouse-event"}
+
+		if suspendKey != "" {
+			keymap[inputrc.Unescape(suspendKey)] = inputrc.Bind{Action: "suspend-process"}
+		}
 	}
 }
 
@@ -133,7 +151,7 @@ func (m *Engine) overrideBindsSpecial() {
 	}
 }
 
-func printBindsReadable(commands []string, all map[string][]string) {
+func printBindsReadable(t *term.Terminal, commands []string, all map[string][]string) {
 	for _, command := range commands {
 		commandBinds := all[command]
 		sort.Strings(commandBinds)
@@ -148,7 +166,7 @@ func printBindsReadable(commands []string, all map[string][]string) {
 			}
 
 			bindsStr := strings.Join(firstBinds, ", ")
-			fmt.Printf("%s can be found on %s ...\n", command, bindsStr)
+			t.Printf("%s can be found on %s ...\n", command, bindsStr)
 
 		default:
 			var firstBinds []string
@@ -158,19 +176,19 @@ func printBindsReadable(commands []string, all map[string][]string) {
 			}
 
 			bindsStr := strings.Join(firstBinds, ", ")
-			fmt.Printf("%s can be found on %s\n", command, bindsStr)
+			t.Printf("%s can be found on %s\n", command, bindsStr)
 		}
 	}
 }
 
-func printBindsInputrc(commands []string, all map[string][]string) {
+func printBindsInputrc(t *term.Terminal, commands []string, all map[string][]string) {
 	for _, command := range commands {
 		commandBinds := all[command]
 		sort.Strings(commandBinds)
 
 		if len(commandBinds) > 0 {
 			for _, bind := range commandBinds {
-				fmt.Printf("\"%s\": %s\n", bind, command)
+				t.Printf("\"%s\": %s\n", bind, command)
 			}
 		}
 	}
@@ -0,0 +1,7 @@
+//go:build unix
+
+package keymap
+
+// suspendKey is bound to suspend-process in all keymaps, like
+// the suspend character of the terminal is in cooked mode.
+const suspendKey = `\C-Z`
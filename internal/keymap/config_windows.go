@@ -0,0 +1,6 @@
+//go:build windows
+
+package keymap
+
+// suspendKey is not bound on Windows, which has no job control.
+const suspendKey = ""
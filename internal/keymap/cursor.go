@@ -50,22 +50,27 @@ var defaultCursors = map[Mode]CursorStyle{
 
 // PrintCursor prints the cursor for the given keymap mode,
 // either default value or the one specified in inputrc file.
+// Nothing is printed if the terminal cursor shape cannot change.
 func (m *Engine) PrintCursor(keymap Mode) {
 	var cursor CursorStyle
 
+	if !m.term.Features().CursorShape {
+		return
+	}
+
 	// Check for a configured cursor in .inputrc file.
 	cursorOptname := fmt.Sprintf("cursor-%s", string(keymap))
 	modeSet := strings.TrimSpace(m.config.GetString(cursorOptname))
 
 	if _, valid := cursors[CursorStyle(modeSet)]; valid {
-		fmt.Print(cursors[CursorStyle(modeSet)])
+		m.term.Print(cursors[CursorStyle(modeSet)])
 		return
 	}
 
 	if defaultCur, valid := defaultCursors[keymap]; valid {
-		fmt.Print(cursors[defaultCur])
+		m.term.Print(cursors[defaultCur])
 		return
 	}
 
-	fmt.Print(cursors[cursor])
+	m.term.Print(cursors[cursor])
 }
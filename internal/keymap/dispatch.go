@@ -3,6 +3,7 @@ package keymap
 import (
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/reeflective/readline/inputrc"
 	"github.com/reeflective/readline/internal/core"
@@ -104,6 +105,22 @@ func (m *Engine) dispatchKeys(binds map[string]inputrc.Bind) (bind inputrc.Bind,
 		// This is because the default binds map is built with byte sequences, not runes, and this
 		// has some implications if the terminal is sending 8-bit characters (extanded alphabet).
 		key, empty := core.PopKey(m.keys)
+
+		// The keys read so far match a bind, but also the beginning of
+		// longer ones: wait for more keys during the keyseq-timeout, and
+		// if none is input, the bind matching the keys read is used.
+		if empty && prefix && m.prefixed.Action != "" {
+			if !core.WaitKeys(m.keys, m.keyseqTimeout()) {
+				prefix = false
+				m.active = m.prefixed
+				m.prefixed = inputrc.Bind{}
+
+				break
+			}
+
+			key, empty = core.PopKey(m.keys)
+		}
+
 		if empty {
 			break
 		}
@@ -148,6 +165,11 @@ func (m *Engine) dispatchKeys(binds map[string]inputrc.Bind) (bind inputrc.Bind,
 	return m.active, prefix, read, matched
 }
 
+// keyseqTimeout returns the duration to wait for the end of an ambiguous key sequence.
+func (m *Engine) keyseqTimeout() time.Duration {
+	return time.Duration(m.config.GetInt("keyseq-timeout")) * time.Millisecond
+}
+
 func (m *Engine) matchBind(keys []byte, binds map[string]inputrc.Bind) (inputrc.Bind, []inputrc.Bind) {
 	var match inputrc.Bind
 	var prefixed []inputrc.Bind
@@ -5,6 +5,7 @@ import (
 
 	"github.com/reeflective/readline/inputrc"
 	"github.com/reeflective/readline/internal/core"
+	"github.com/reeflective/readline/internal/term"
 )
 
 // Engine is used to manage the main and local keymaps for the shell.
@@ -18,17 +19,20 @@ type Engine struct {
 	isCaller     bool
 	nonIncSearch bool
 
+	term       *term.Terminal
 	keys       *core.Keys
 	iterations *core.Iterations
 	config     *inputrc.Config
 	commands   map[string]func()
+	changed    func(old, new Mode)
 }
 
 // NewEngine is a required constructor for the keymap modes manager.
 // It initializes the keymaps to their defaults or configured values.
-func NewEngine(keys *core.Keys, i *core.Iterations, opts ...inputrc.Option) (*Engine, *inputrc.Config) {
+func NewEngine(t *term.Terminal, keys *core.Keys, i *core.Iterations, opts ...inputrc.Option) (*Engine, *inputrc.Config) {
 	modes := &Engine{
 		main:       Emacs,
+		term:       t,
 		keys:       keys,
 		iterations: i,
 		config:     inputrc.NewDefaultConfig(),
@@ -41,6 +45,13 @@ func NewEngine(keys *core.Keys, i *core.Iterations, opts ...inputrc.Option) (*En
 	return modes, modes.config
 }
 
+// Init is used once at shell creation time to pass further parameters to the engine:
+// the changed function is called each time the current keymap (the local one if any,
+// or the main one) changes, with both the previous and the new keymaps.
+func Init(eng *Engine, changed func(old, new Mode)) {
+	eng.changed = changed
+}
+
 // Register adds command functions to the list of available commands.
 // Each key of the map should be a unique name, not yet used by any
 // other builtin/user command, in order not to "overload" the builtins.
@@ -55,6 +66,8 @@ func (m *Engine) Register(commands map[string]func()) {
 // - emacs, emacs-meta, emacs-ctlx, emacs-standard.
 // - vi, vi-insert, vi-command, vi-move.
 func (m *Engine) SetMain(keymap string) {
+	defer m.notify(m.current())
+
 	m.main = Mode(keymap)
 	m.UpdateCursor()
 }
@@ -69,6 +82,8 @@ func (m *Engine) Main() Mode {
 // - vi-opp, vi-visual. (used in commands like yank, change, delete, etc.)
 // - isearch, menu-select (used in search and completion).
 func (m *Engine) SetLocal(keymap string) {
+	defer m.notify(m.current())
+
 	m.local = Mode(keymap)
 	m.UpdateCursor()
 }
@@ -80,10 +95,28 @@ func (m *Engine) Local() Mode {
 
 // ResetLocal deactivates the local keymap of the shell.
 func (m *Engine) ResetLocal() {
+	defer m.notify(m.current())
+
 	m.local = ""
 	m.UpdateCursor()
 }
 
+// current returns the keymap in use: the local one if any, or the main one.
+func (m *Engine) current() Mode {
+	if m.local != "" {
+		return m.local
+	}
+
+	return m.main
+}
+
+// notify calls the keymap change function, if the current keymap is not the old one.
+func (m *Engine) notify(old Mode) {
+	if m.changed != nil && m.current() != old {
+		m.changed(old, m.current())
+	}
+}
+
 // UpdateCursor reprints the cursor corresponding to the current keymaps.
 func (m *Engine) UpdateCursor() {
 	switch m.local {
@@ -159,9 +192,9 @@ func (m *Engine) PrintBinds(keymap string, inputrcFormat bool) {
 	}
 
 	if inputrcFormat {
-		printBindsInputrc(commands, allBinds)
+		printBindsInputrc(m.term, commands, allBinds)
 	} else {
-		printBindsReadable(commands, allBinds)
+		printBindsReadable(m.term, commands, allBinds)
 	}
 }
 
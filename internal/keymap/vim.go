@@ -51,6 +51,7 @@ var vicmdKeys = map[string]inputrc.Bind{
 	unescape(`\e[3;5~`): {Action: "kill-word"},
 	unescape(`\e[1;5C`): {Action: "forward-word"},
 	unescape(`\e[1;5D`): {Action: "backward-word"},
+	unescape(`\e[200~`): {Action: "bracketed-paste-begin"},
 	unescape(" "):       {Action: "vi-forward-char"},
 	unescape("$"):       {Action: "vi-end-of-line"},
 	unescape("%"):       {Action: "vi-match"},
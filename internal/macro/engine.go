@@ -1,13 +1,13 @@
 package macro
 
 import (
-	"fmt"
 	"sort"
 	"strings"
 
 	"github.com/reeflective/readline/inputrc"
 	"github.com/reeflective/readline/internal/color"
 	"github.com/reeflective/readline/internal/core"
+	"github.com/reeflective/readline/internal/term"
 	"github.com/reeflective/readline/internal/ui"
 )
 
@@ -23,16 +23,18 @@ type Engine struct {
 	macros     map[rune]string // All previously recorded macros.
 	started    bool
 
-	keys   *core.Keys // The engine feeds macros directly in the key stack.
-	hint   *ui.Hint   // The engine notifies when macro recording starts/stops.
-	status string     // The hint status displaying the currently recorded macro.
+	term   *term.Terminal // The terminal on which macros are printed.
+	keys   *core.Keys     // The engine feeds macros directly in the key stack.
+	hint   *ui.Hint       // The engine notifies when macro recording starts/stops.
+	status string         // The hint status displaying the currently recorded macro.
 }
 
 // NewEngine is a required constructor to setup a working macro engine.
-func NewEngine(keys *core.Keys, hint *ui.Hint) *Engine {
+func NewEngine(t *term.Terminal, keys *core.Keys, hint *ui.Hint) *Engine {
 	return &Engine{
 		current: make([]rune, 0),
 		macros:  make(map[rune]string),
+		term:    t,
 		keys:    keys,
 		hint:    hint,
 	}
@@ -152,7 +154,7 @@ func (e *Engine) PrintLastMacro() {
 	// Print the macro and the prompt.
 	// The shell takes care of clearing itself
 	// before printing, and refreshing after.
-	fmt.Printf("\n%s\n", e.macros[e.currentKey])
+	e.term.Printf("\n%s\n", e.macros[e.currentKey])
 }
 
 // PrintAllMacros dumps all macros to the screen, which one line
@@ -178,7 +180,7 @@ func (e *Engine) PrintAllMacros() {
 			macro = '"'
 		}
 
-		fmt.Printf("\"%s\": %s\n", string(macro), sequence)
+		e.term.Printf("\"%s\": %s\n", string(macro), sequence)
 	}
 }
 
@@ -4,7 +4,6 @@ import (
 	"strings"
 
 	"github.com/reeflective/readline/internal/color"
-	"github.com/reeflective/readline/internal/term"
 	"github.com/rivo/uniseg"
 )
 
@@ -25,13 +24,31 @@ func RealLength(s string) int {
 
 // LineSpan computes the number of columns and lines that are needed for a given line,
 // accounting for any ANSI escapes/color codes, and tabulations replaced with 4 spaces.
-func LineSpan(line []rune, idx, indent int) (x, y int) {
-	termWidth := term.GetWidth()
-	lineLen := RealLength(string(line))
-	lineLen += indent
+// The width is the number of columns of the terminal on which the line is displayed:
+// like terminals do, wide characters (like CJK ones) not fitting at the end of a row
+// are displayed on the next one.
+func LineSpan(line []rune, idx, indent, termWidth int) (x, y int) {
+	text := strings.ReplaceAll(color.Strip(string(line)), "\t", "     ")
+	cursorX, cursorY := indent%termWidth, indent/termWidth
+	state := -1
 
-	cursorY := lineLen / termWidth
-	cursorX := lineLen % termWidth
+	for len(text) > 0 {
+		var width int
+
+		_, text, width, state = uniseg.FirstGraphemeClusterInString(text, state)
+
+		if cursorX+width > termWidth {
+			cursorX = 0
+			cursorY++
+		}
+
+		cursorX += width
+
+		if cursorX == termWidth {
+			cursorX = 0
+			cursorY++
+		}
+	}
 
 	// Empty lines are still considered a line.
 	if idx != 0 {
@@ -40,3 +57,63 @@ func LineSpan(line []rune, idx, indent int) (x, y int) {
 
 	return cursorX, cursorY
 }
+
+// SliceColumns returns the graphemes of a string displayed from the start column (included)
+// to the end one (excluded), along with all the escape sequences of the string, so that the
+// graphemes keep their colors. Wide graphemes cut by either bound are replaced with spaces.
+func SliceColumns(s string, start, end int) string {
+	var slice strings.Builder
+
+	col := 0
+
+	for len(s) > 0 {
+		if s[0] == '\x1b' {
+			size := escapeLength(s)
+			slice.WriteString(s[:size])
+			s = s[size:]
+
+			continue
+		}
+
+		cluster, rest, width, _ := uniseg.FirstGraphemeClusterInString(s, -1)
+		s = rest
+
+		switch {
+		case col >= start && col+width <= end:
+			slice.WriteString(cluster)
+		case col < end && col+width > start:
+			slice.WriteString(strings.Repeat(" ", min(col+width, end)-max(col, start)))
+		}
+
+		col += width
+	}
+
+	return slice.String()
+}
+
+// escapeLength returns the length of the escape sequence at the beginning of s.
+func escapeLength(s string) int {
+	switch {
+	case len(s) < 2:
+		return len(s)
+	case s[1] == '[':
+		end := 2
+		for end < len(s) && (s[end] < 0x40 || s[end] > 0x7e) {
+			end++
+		}
+
+		return min(end+1, len(s))
+	case s[1] == ']':
+		if end := strings.IndexByte(s, '\a'); end != -1 {
+			return end + 1
+		}
+
+		if end := strings.Index(s, "\x1b\\"); end != -1 {
+			return end + 2
+		}
+
+		return len(s)
+	}
+
+	return 2
+}
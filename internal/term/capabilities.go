@@ -0,0 +1,308 @@
+package term
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// Features are the features of a terminal to which the shell adapts its behavior.
+type Features struct {
+	Colors         int  // Number of colors supported, or zero if none.
+	CursorShape    bool // The cursor shape can be changed (DECSCUSR sequences).
+	BracketedPaste bool // Pasted text can be enclosed in bracketed paste sequences.
+	SyncOutput     bool // Updates can be displayed at once (synchronized output mode).
+	CursorControl  bool // The cursor can be moved, and its position queried.
+
+	ansi bool // The terminal understands ECMA-48 (ANSI) control sequences.
+}
+
+// Features of terminals without a terminfo entry, which are assumed to be xterm-compatible.
+var xtermFeatures = Features{
+	Colors:         256,
+	CursorShape:    true,
+	BracketedPaste: true,
+	SyncOutput:     true,
+	CursorControl:  true,
+	ansi:           true,
+}
+
+// Terminal families supporting xterm features not always found in their terminfo entries.
+var xtermFamilies = []string{"xterm", "tmux", "alacritty", "foot", "wezterm", "contour"}
+
+// SetType sets the type of the terminal (like the TERM environment variable), whose terminfo
+// entry is used to translate the control sequences written by the shell, and to find which
+// features the terminal supports. If the type is empty or has no terminfo entry, sequences
+// are written as is (they are those of xterm: see the package constants), and all features
+// are assumed to be supported.
+func (t *Terminal) SetType(name string) {
+	info, err := LoadTerminfo(name)
+	if err != nil {
+		t.info = nil
+		t.features = xtermFeatures
+
+		return
+	}
+
+	ansi := strings.HasPrefix(info.String("cup"), "\x1b[")
+
+	t.info = info
+	t.features = Features{
+		Colors:         max(info.Number("colors"), 0),
+		CursorShape:    info.String("Ss") != "" || isXtermFamily(name),
+		BracketedPaste: info.String("BE") != "" || isXtermFamily(name),
+		SyncOutput:     info.String("Sync") != "" || isXtermFamily(name),
+		CursorControl:  ansi,
+		ansi:           ansi,
+	}
+}
+
+// Features returns the features supported by the terminal.
+func (t *Terminal) Features() Features {
+	return t.features
+}
+
+// translate replaces the xterm control sequences written by the shell with their
+// equivalent for the terminal, as found in its terminfo entry. Sequences without
+// equivalent are removed, so that they are not displayed as garbage.
+func (t *Terminal) translate(buf []byte) []byte {
+	out := make([]byte, 0, len(buf))
+
+	for len(buf) > 0 {
+		esc := bytes.IndexByte(buf, '\x1b')
+		if esc == -1 {
+			return append(out, buf...)
+		}
+
+		out = append(out, buf[:esc]...)
+		buf = buf[esc:]
+
+		seq, size := t.translateEscape(buf)
+		out = append(out, seq...)
+		buf = buf[size:]
+	}
+
+	return out
+}
+
+// translateEscape translates the escape sequence at the beginning
+// of buf, and returns it along with the length of the original one.
+func (t *Terminal) translateEscape(buf []byte) (seq string, size int) {
+	if len(buf) < 2 {
+		return string(buf), len(buf)
+	}
+
+	switch buf[1] {
+	case '7':
+		return t.info.String("sc"), 2
+	case '8':
+		return t.info.String("rc"), 2
+	case '[':
+		return t.translateCSI(buf)
+	case ']', 'P', '_', '^':
+		size = len(buf)
+
+		for i := 2; i < len(buf); i++ {
+			if buf[i] == '\a' || (buf[i] == '\\' && buf[i-1] == '\x1b') {
+				size = i + 1
+				break
+			}
+		}
+
+		return t.ansiOnly(buf[:size]), size
+	}
+
+	return t.ansiOnly(buf[:2]), 2
+}
+
+// translateCSI translates a Control Sequence Introducer sequence.
+func (t *Terminal) translateCSI(buf []byte) (seq string, size int) {
+	end := 2
+	for end < len(buf) && buf[end] >= 0x30 && buf[end] <= 0x3f {
+		end++
+	}
+
+	params := string(buf[2:end])
+
+	for end < len(buf) && buf[end] >= 0x20 && buf[end] <= 0x2f {
+		end++
+	}
+
+	if end == len(buf) || buf[end] < 0x40 || buf[end] > 0x7e {
+		return t.ansiOnly(buf[:end]), end
+	}
+
+	intermediate := string(buf[2+len(params) : end])
+	final := buf[end]
+	raw := buf[:end+1]
+
+	param := func(value string, def int) int {
+		number, err := strconv.Atoi(value)
+		if err != nil || number == 0 {
+			return def
+		}
+
+		return number
+	}
+
+	switch {
+	case intermediate == " " && final == 'q':
+		return t.cursorShape(param(params, 0)), len(raw)
+	case intermediate != "":
+	case params == "?25" && final == 'h':
+		return t.info.String("cnorm"), len(raw)
+	case params == "?25" && final == 'l':
+		return t.info.String("civis"), len(raw)
+	case params == "?2004" && (final == 'h' || final == 'l'):
+		return t.bracketedPaste(raw, final == 'h'), len(raw)
+	case params == "?2026" && (final == 'h' || final == 'l'):
+		return t.syncOutput(raw, final == 'h'), len(raw)
+	case strings.ContainsAny(params, "<=>?"):
+	case final == 'A':
+		return t.move("cuu", "cuu1", param(params, 1)), len(raw)
+	case final == 'B':
+		return t.move("cud", "cud1", param(params, 1)), len(raw)
+	case final == 'C':
+		return t.move("cuf", "cuf1", param(params, 1)), len(raw)
+	case final == 'D':
+		return t.move("cub", "cub1", param(params, 1)), len(raw)
+	case final == 'K':
+		return t.clear(param(params, 0), "el", "el1"), len(raw)
+	case final == 'J':
+		return t.clear(param(params, 0), "ed", "clear"), len(raw)
+	case final == 'H' && params == "":
+		return t.info.String("home"), len(raw)
+	case final == 'H':
+		row, col, _ := strings.Cut(params, ";")
+		return t.info.Param("cup", param(row, 1)-1, param(col, 1)-1), len(raw)
+	case final == 'm':
+		return t.sgr(params), len(raw)
+	}
+
+	return t.ansiOnly(raw), len(raw)
+}
+
+// move returns the sequence moving the cursor by count cells, either with
+// the parameterized capability, or by repeating the single move one.
+func (t *Terminal) move(parameterized, single string, count int) string {
+	if seq := t.info.Param(parameterized, count); seq != "" {
+		return seq
+	}
+
+	return strings.Repeat(t.info.String(single), count)
+}
+
+// clear returns the sequence erasing the line or the screen: after the cursor (0),
+// before it (1, lines only), or all of it (2 and 3, the latter with the scrollback).
+// The shell always moves the cursor to the top-left corner before clearing the screen.
+func (t *Terminal) clear(mode int, after, other string) string {
+	switch {
+	case mode == 0:
+		return t.info.String(after)
+	case mode == 1 && after == "el":
+		return t.info.String(other)
+	case mode == 2 && after == "el":
+		return t.info.String(other) + t.info.String(after)
+	case mode == 2:
+		return t.info.String(other)
+	case mode == 3:
+		return t.info.String("E3")
+	}
+
+	return ""
+}
+
+// cursorShape returns the sequence setting the cursor shape (0 being the user default).
+func (t *Terminal) cursorShape(shape int) string {
+	switch {
+	case !t.features.CursorShape:
+		return ""
+	case shape == 0 && t.info.String("Se") != "":
+		return t.info.String("Se")
+	case t.info.String("Ss") != "":
+		return t.info.Param("Ss", shape)
+	}
+
+	return "\x1b[" + strconv.Itoa(shape) + " q"
+}
+
+// bracketedPaste returns the sequence enabling or disabling bracketed paste.
+func (t *Terminal) bracketedPaste(raw []byte, enable bool) string {
+	switch {
+	case !t.features.BracketedPaste:
+		return ""
+	case enable && t.info.String("BE") != "":
+		return t.info.String("BE")
+	case !enable && t.info.String("BD") != "":
+		return t.info.String("BD")
+	}
+
+	return string(raw)
+}
+
+// syncOutput returns the sequence beginning or ending a synchronized update.
+func (t *Terminal) syncOutput(raw []byte, begin bool) string {
+	switch {
+	case !t.features.SyncOutput:
+		return ""
+	case t.info.String("Sync") != "":
+		return t.info.Param("Sync", boolInt(!begin)+1)
+	}
+
+	return string(raw)
+}
+
+// sgr returns a Select Graphic Rendition sequence (colors and text attributes),
+// without its colors if the terminal has none, or nothing if it has no attributes.
+func (t *Terminal) sgr(params string) string {
+	switch {
+	case t.info.String("sgr0") == "":
+		return ""
+	case t.features.Colors > 0:
+		return "\x1b[" + params + "m"
+	}
+
+	fields := strings.Split(params, ";")
+	attributes := make([]string, 0, len(fields))
+
+	for i := 0; i < len(fields); i++ {
+		value, _ := strconv.Atoi(strings.Split(fields[i], ":")[0])
+
+		switch {
+		case (value == 38 || value == 48) && !strings.Contains(fields[i], ":"):
+			// Skip the 256 colors index, or the RGB values.
+			if i+1 < len(fields) && fields[i+1] == "5" {
+				i += 2
+			} else if i+1 < len(fields) && fields[i+1] == "2" {
+				i += 4
+			}
+		case value < 30:
+			attributes = append(attributes, fields[i])
+		}
+	}
+
+	if len(attributes) == 0 && params != "" {
+		return ""
+	}
+
+	return "\x1b[" + strings.Join(attributes, ";") + "m"
+}
+
+// ansiOnly returns the sequence as is if the terminal understands ECMA-48 sequences.
+func (t *Terminal) ansiOnly(raw []byte) string {
+	if !t.features.ansi {
+		return ""
+	}
+
+	return string(raw)
+}
+
+func isXtermFamily(name string) bool {
+	for _, family := range xtermFamilies {
+		if strings.HasPrefix(name, family) {
+			return true
+		}
+	}
+
+	return false
+}
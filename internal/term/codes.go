@@ -1,6 +1,7 @@
 package term
 
-// Terminal control sequences.
+// Terminal control sequences. Those are the xterm ones, which are
+// translated when written to terminals with another terminfo entry.
 const (
 	NewlineReturn = "\r\n"
 
@@ -16,9 +17,38 @@ const (
 	RestoreCursorPos = "\x1b8"
 	HideCursor       = "\x1b[?25l"
 	ShowCursor       = "\x1b[?25h"
+
+	BracketedPasteOn  = "\x1b[?2004h"
+	BracketedPasteOff = "\x1b[?2004l"
+
+	// The terminal displays updates at once, instead of while they are written.
+	SynchronizedOutputOn  = "\x1b[?2026h"
+	SynchronizedOutputOff = "\x1b[?2026l"
+
+	// Extended keys: kitty keyboard protocol (disambiguated
+	// escape codes) and xterm modifyOtherKeys (mode 2).
+	ExtendedKeysOn  = "\x1b[>1u\x1b[>4;2m"
+	ExtendedKeysOff = "\x1b[<u\x1b[>4m"
+
+	// Mouse reporting: button presses and releases (1000), with SGR coordinates (1006).
+	MouseOn  = "\x1b[?1000h\x1b[?1006h"
+	MouseOff = "\x1b[?1006l\x1b[?1000l"
+
+	// Shell integration marks (OSC 133): start of the prompt, of the input
+	// line, and of the command output, then end of the command and its status.
+	PromptStart = "\x1b]133;A\a"
+	InputStart  = "\x1b]133;B\a"
+	OutputStart = "\x1b]133;C\a"
+	CommandEnd  = "\x1b]133;D;%d\a"
+
+	// Window title (OSC 2), and working directory as a file URL (OSC 7).
+	WindowTitle      = "\x1b]2;%s\a"
+	WorkingDirectory = "\x1b]7;%s\a"
 )
 
-// Some core keys needed by some stuff.
+// Some core keys needed by some stuff. Those are sent in the normal cursor keys
+// mode, while terminfo entries describe the keys sent in the application mode
+// (keypad_xmit), which the shell does not enable: they are used for all terminals.
 var (
 	ArrowUp    = string([]byte{27, 91, 65}) // ^[[A
 	ArrowDown  = string([]byte{27, 91, 66}) // ^[[B
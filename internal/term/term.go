@@ -2,55 +2,235 @@ package term
 
 import (
 	"fmt"
+	"io"
 	"os"
-
-	"golang.org/x/term"
+	"strings"
+	"sync/atomic"
 )
 
-// Those variables are very important to realine low-level code: all virtual terminal
-// escape sequences should always be sent and read through the raw terminal file, even
-// if people start using io.MultiWriters and os.Pipes involving basic IO.
-var (
-	stdoutTerm *os.File
-	stdinTerm  *os.File
-	stderrTerm *os.File
-)
+// fallback terminal width when we can't get it through query.
+var defaultTermWidth = 80
 
-func init() {
-	stdoutTerm = os.Stdout
-	stdoutTerm = os.Stderr
-	stderrTerm = os.Stdin
+// Terminal gives access to the input and output streams used by a shell,
+// and to the dimensions of the terminal these streams are bound to. All
+// virtual terminal escape sequences should be written and read through it,
+// so that several shells can run at once on different terminals.
+//
+// The shell writes xterm control sequences, which are translated for the
+// terminal type (see SetType): the latter is $TERM by default.
+type Terminal struct {
+	in       io.Reader
+	out      io.Writer
+	size     func() (width, height int)
+	info     *Terminfo // Terminal description, nil for xterm-compatible terminals.
+	features Features
+	redirect io.Writer     // Output is written there instead, when not nil.
+	changes  atomic.Uint64 // Number of writes which might have changed the screen.
 }
 
-// fallback terminal width when we can't get it through query.
-var defaultTermWidth = 80
+// NewTerminal returns a terminal reading input keys from in, and writing all output to out.
+// The size function should return the current dimensions of the terminal, and is called on
+// each redisplay: if nil, the size is queried on out (if it is a terminal file), or falls
+// back to 80 columns and 80 rows.
+func NewTerminal(in io.Reader, out io.Writer, size func() (width, height int)) *Terminal {
+	if in == nil {
+		in = os.Stdin
+	}
+
+	if out == nil {
+		out = os.Stdout
+	}
+
+	t := &Terminal{
+		in:   in,
+		out:  out,
+		size: size,
+	}
+
+	if t.size == nil {
+		t.size = t.querySize
+	}
+
+	t.SetType(os.Getenv("TERM"))
+
+	return t
+}
+
+// Read reads input keys from the terminal input stream.
+func (t *Terminal) Read(p []byte) (n int, err error) {
+	return t.in.Read(p)
+}
+
+// Write writes to the terminal output stream, translating control sequences if needed.
+func (t *Terminal) Write(p []byte) (n int, err error) {
+	if t.redirect != nil {
+		return t.redirect.Write(p)
+	}
+
+	if changesScreen(p) {
+		t.changes.Add(1)
+	}
+
+	if t.info == nil {
+		return t.out.Write(p)
+	}
+
+	if _, err = t.out.Write(t.translate(p)); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Redirect makes all subsequent writes to the terminal be written as is to w instead,
+// until the returned function is called. This is used to render the interface offscreen.
+func (t *Terminal) Redirect(w io.Writer) (restore func()) {
+	t.redirect = w
+
+	return func() { t.redirect = nil }
+}
+
+// Changes returns the number of writes to the terminal which might have changed the
+// screen (contents or cursor position), so that callers can detect if the screen has
+// been changed since they last wrote to it. Modes, styles and queries are not counted.
+func (t *Terminal) Changes() uint64 {
+	return t.changes.Load()
+}
+
+// Print formats using the default formats for its operands and writes to the terminal.
+func (t *Terminal) Print(a ...any) {
+	fmt.Fprint(t, a...)
+}
 
-// GetWidth returns the width of Stdout or 80 if the width cannot be established.
-func GetWidth() (termWidth int) {
-	var err error
-	fd := int(stdoutTerm.Fd())
-	termWidth, _, err = GetSize(fd)
+// Println formats using the default formats for its operands and writes
+// to the terminal. Spaces are always added between operands and a newline
+// is appended.
+func (t *Terminal) Println(a ...any) {
+	fmt.Fprintln(t, a...)
+}
 
-	if err != nil || termWidth == 0 {
-		termWidth = defaultTermWidth
+// Printf formats according to a format specifier and writes to the terminal.
+func (t *Terminal) Printf(format string, a ...any) (n int, err error) {
+	return fmt.Fprintf(t, format, a...)
+}
+
+// Input returns the input stream of the terminal.
+func (t *Terminal) Input() io.Reader {
+	return t.in
+}
+
+// Output returns the output stream of the terminal, to which
+// sequences are written as is, without being translated.
+func (t *Terminal) Output() io.Writer {
+	return t.out
+}
+
+// Fd returns the file descriptor of the terminal input stream,
+// and false if the latter is not a file (like a network stream).
+func (t *Terminal) Fd() (fd int, ok bool) {
+	file, isFile := t.in.(interface{ Fd() uintptr })
+	if !isFile {
+		return -1, false
 	}
 
-	return
+	return int(file.Fd()), true
 }
 
-// GetLength returns the length of the terminal
-// (Y length), or 80 if it cannot be established.
-func GetLength() int {
-	_, length, err := term.GetSize(0)
+// IsTerminal returns false if the input stream is a file which is not a terminal,
+// like a pipe or a regular file, and true otherwise: streams that are not files
+// (like network ones) are assumed to be bound to a remote terminal.
+func (t *Terminal) IsTerminal() bool {
+	descriptor, isFile := t.Fd()
 
-	if err != nil || length == 0 {
+	return !isFile || IsTerminal(descriptor)
+}
+
+// Width returns the width of the terminal, or 80 if it cannot be established.
+func (t *Terminal) Width() int {
+	width, _ := t.size()
+	if width <= 0 {
+		return defaultTermWidth
+	}
+
+	return width
+}
+
+// Height returns the height of the terminal, or 80 if it cannot be established.
+func (t *Terminal) Height() int {
+	_, height := t.size()
+	if height <= 0 {
 		return defaultTermWidth
 	}
 
-	return length
+	return height
+}
+
+// querySize is the default size function, which queries the
+// output stream for its dimensions if it is a terminal file.
+func (t *Terminal) querySize() (width, height int) {
+	file, isFile := t.out.(interface{ Fd() uintptr })
+	if !isFile {
+		return defaultTermWidth, defaultTermWidth
+	}
+
+	width, height, err := GetSize(int(file.Fd()))
+	if err != nil {
+		return defaultTermWidth, defaultTermWidth
+	}
+
+	return width, height
 }
 
-func printf(format string, a ...interface{}) {
-	s := fmt.Sprintf(format, a...)
-	fmt.Print(s)
+// changesScreen returns true if the output contains any character, or any
+// escape sequence other than mode changes (like the cursor shape), styles,
+// queries (like the cursor position one) or operating system commands (like
+// clipboard ones), all of which leave the screen contents and the cursor
+// position as they are.
+func changesScreen(p []byte) bool {
+	for i := 0; i < len(p); i++ {
+		if p[i] == '\x1b' && i+1 < len(p) && p[i+1] == ']' {
+			end := oscEnd(p[i:])
+			if end == -1 {
+				return true
+			}
+
+			i += end - 1
+
+			continue
+		}
+
+		if p[i] != '\x1b' || i+1 == len(p) || p[i+1] != '[' {
+			return true
+		}
+
+		end := i + 2
+		for end < len(p) && (p[end] < 0x40 || p[end] > 0x7e) {
+			end++
+		}
+
+		if end == len(p) || !strings.ContainsRune("hlmnqu", rune(p[end])) {
+			return true
+		}
+
+		// Without private parameters, this restores the cursor position.
+		if p[end] == 'u' && (end == i+2 || !strings.ContainsRune("<=>?", rune(p[i+2]))) {
+			return true
+		}
+
+		i = end
+	}
+
+	return false
+}
+
+// oscEnd returns the length of the operating system command at the beginning
+// of p, terminated by a bell or a string terminator, or -1 if it is incomplete.
+func oscEnd(p []byte) int {
+	for i := 2; i < len(p); i++ {
+		if p[i] == '\a' || (p[i] == '\\' && p[i-1] == '\x1b') {
+			return i + 1
+		}
+	}
+
+	return -1
 }
@@ -0,0 +1,31 @@
+package term
+
+import "testing"
+
+func TestChangesScreen(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{name: "Text", output: "ls", want: true},
+		{name: "Cursor move", output: "\x1b[2A", want: true},
+		{name: "Line erased", output: "\x1b[0K", want: true},
+		{name: "Cursor hidden and shown", output: HideCursor + ShowCursor},
+		{name: "Style", output: "\x1b[1;31m"},
+		{name: "Cursor position query", output: "\x1b[6n"},
+		{name: "Extended keys", output: ExtendedKeysOn + ExtendedKeysOff},
+		{name: "Cursor restored", output: "\x1b[u", want: true},
+		{name: "Style followed by text", output: "\x1b[0mls", want: true},
+		{name: "Operating system commands", output: "\x1b]52;c;bHM=\a\x1b]52;p;?\x1b\\"},
+		{name: "Incomplete operating system command", output: "\x1b]52;c;", want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := changesScreen([]byte(test.output)); got != test.want {
+				t.Errorf("changesScreen(%q) = %v, want %v", test.output, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,539 @@
+package term
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Magic numbers of compiled terminfo entries, with 16-bit or 32-bit numbers.
+const (
+	terminfoMagic   = 0o432
+	terminfoMagic32 = 0o1036
+)
+
+// ErrNoTerminfo is returned when no terminfo entry is found for a terminal type.
+var ErrNoTerminfo = errors.New("terminfo entry not found")
+
+// errTerminfoFormat is returned when reading an invalid compiled terminfo entry.
+var errTerminfoFormat = errors.New("invalid terminfo entry")
+
+// Indexes of the standard capabilities used by the shell,
+// in the capability arrays of compiled terminfo entries.
+var (
+	terminfoBools = map[string]int{
+		"am":   1,
+		"xenl": 4,
+	}
+
+	terminfoNumbers = map[string]int{
+		"colors": 13,
+	}
+
+	terminfoStrings = map[string]int{
+		"clear": 5,
+		"el":    6,
+		"ed":    7,
+		"cup":   10,
+		"cud1":  11,
+		"home":  12,
+		"civis": 13,
+		"cub1":  14,
+		"cnorm": 16,
+		"cuf1":  17,
+		"cuu1":  19,
+		"sgr0":  39,
+		"cud":   107,
+		"cub":   111,
+		"cuf":   112,
+		"cuu":   114,
+		"rc":    126,
+		"sc":    128,
+		"el1":   269,
+	}
+)
+
+// Padding delays, which are not needed by terminal emulators.
+var rxTerminfoPadding = regexp.MustCompile(`\$<[0-9.]+[*/]*>`)
+
+// Terminfo is the description of a terminal type, read from the compiled terminfo
+// database. Only the standard capabilities used by the shell are accessible, along
+// with all extended (user-defined) capabilities, like Ss or BE.
+type Terminfo struct {
+	Names []string
+
+	bools   []bool
+	numbers []int
+	strings []string
+
+	extBools   map[string]bool
+	extNumbers map[string]int
+	extStrings map[string]string
+}
+
+// LoadTerminfo finds and reads the compiled terminfo entry of the given terminal type
+// (like the TERM environment variable), in the same directories as ncurses does.
+func LoadTerminfo(name string) (*Terminfo, error) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return nil, ErrNoTerminfo
+	}
+
+	for _, dir := range terminfoDirs() {
+		// Entries are either in a directory named after their first
+		// character, or after its hexadecimal code (like on MacOS).
+		for _, sub := range []string{name[:1], strconv.FormatInt(int64(name[0]), 16)} {
+			data, err := os.ReadFile(filepath.Join(dir, sub, name))
+			if err != nil {
+				continue
+			}
+
+			return parseTerminfo(data)
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrNoTerminfo, name)
+}
+
+// Bool returns the value of a boolean capability.
+func (ti *Terminfo) Bool(name string) bool {
+	if index, found := terminfoBools[name]; found {
+		return index < len(ti.bools) && ti.bools[index]
+	}
+
+	return ti.extBools[name]
+}
+
+// Number returns the value of a numeric capability, or -1 if absent.
+func (ti *Terminfo) Number(name string) int {
+	if index, found := terminfoNumbers[name]; found {
+		if index < len(ti.numbers) {
+			return ti.numbers[index]
+		}
+
+		return -1
+	}
+
+	if number, found := ti.extNumbers[name]; found {
+		return number
+	}
+
+	return -1
+}
+
+// String returns the value of a string capability, or an empty string if
+// absent. The value is returned without its padding delays, if any.
+func (ti *Terminfo) String(name string) string {
+	var value string
+
+	if index, found := terminfoStrings[name]; found {
+		if index < len(ti.strings) {
+			value = ti.strings[index]
+		}
+	} else {
+		value = ti.extStrings[name]
+	}
+
+	return rxTerminfoPadding.ReplaceAllString(value, "")
+}
+
+// Param returns the value of a parameterized string capability, with its parameters
+// instantiated, like the tparm ncurses function does (eg. the column of cursor moves).
+func (ti *Terminfo) Param(name string, params ...int) string {
+	capability := ti.String(name)
+	if capability == "" {
+		return ""
+	}
+
+	return tparm(capability, params...)
+}
+
+// terminfoDirs returns the directories in which terminfo entries are searched.
+func terminfoDirs() (dirs []string) {
+	defaults := []string{"/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo"}
+
+	if dir := os.Getenv("TERMINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+
+	if list, found := os.LookupEnv("TERMINFO_DIRS"); found {
+		for _, dir := range strings.Split(list, ":") {
+			if dir == "" {
+				dirs = append(dirs, defaults...)
+				continue
+			}
+
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return append(dirs, defaults...)
+}
+
+// terminfoReader reads the sections of a compiled terminfo entry.
+type terminfoReader struct {
+	data   []byte
+	pos    int
+	number int // Size of numbers, in bytes.
+	err    error
+}
+
+func (r *terminfoReader) bytes(size int) []byte {
+	if r.err != nil || size < 0 || r.pos+size > len(r.data) {
+		r.err = errTerminfoFormat
+		return nil
+	}
+
+	buf := r.data[r.pos : r.pos+size]
+	r.pos += size
+
+	return buf
+}
+
+// shorts reads 16-bit little-endian signed integers.
+func (r *terminfoReader) shorts(count int) []int {
+	buf := r.bytes(count * 2)
+	values := make([]int, len(buf)/2)
+
+	for i := range values {
+		values[i] = int(int16(binary.LittleEndian.Uint16(buf[i*2:])))
+	}
+
+	return values
+}
+
+// numbers reads the numeric capabilities, which are 32-bit in the extended format.
+func (r *terminfoReader) numbers(count int) []int {
+	if r.number == 2 {
+		return r.shorts(count)
+	}
+
+	buf := r.bytes(count * 4)
+	values := make([]int, len(buf)/4)
+
+	for i := range values {
+		values[i] = int(int32(binary.LittleEndian.Uint32(buf[i*4:])))
+	}
+
+	return values
+}
+
+func (r *terminfoReader) bools(count int) []bool {
+	buf := r.bytes(count)
+	values := make([]bool, len(buf))
+
+	for i, value := range buf {
+		values[i] = value == 1
+	}
+
+	return values
+}
+
+// align skips the padding byte inserted so that sections start on even offsets.
+func (r *terminfoReader) align() {
+	if r.pos%2 == 1 && r.pos < len(r.data) {
+		r.pos++
+	}
+}
+
+// parseTerminfo parses a compiled terminfo entry, as described in term(5).
+func parseTerminfo(data []byte) (*Terminfo, error) {
+	reader := &terminfoReader{data: data, number: 2}
+
+	header := reader.shorts(6)
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	switch header[0] {
+	case terminfoMagic:
+	case terminfoMagic32:
+		reader.number = 4
+	default:
+		return nil, errTerminfoFormat
+	}
+
+	info := &Terminfo{
+		Names: strings.Split(strings.TrimRight(string(reader.bytes(header[1])), "\x00"), "|"),
+		bools: reader.bools(header[2]),
+	}
+
+	reader.align()
+	info.numbers = reader.numbers(header[3])
+	offsets := reader.shorts(header[4])
+	table := reader.bytes(header[5])
+	info.strings = stringTable(table, offsets)
+
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	// Extended capabilities are optional.
+	reader.align()
+
+	if reader.pos < len(data) {
+		parseTerminfoExtended(reader, info)
+	}
+
+	return info, nil
+}
+
+// parseTerminfoExtended parses the extended capabilities section of a compiled entry:
+// its string table holds the values of string capabilities, followed by all names.
+func parseTerminfoExtended(reader *terminfoReader, info *Terminfo) {
+	header := reader.shorts(5)
+	if reader.err != nil {
+		return
+	}
+
+	bools := reader.bools(header[0])
+	reader.align()
+	numbers := reader.numbers(header[1])
+	offsets := reader.shorts(header[2])
+	nameOffsets := reader.shorts(header[0] + header[1] + header[2])
+	table := reader.bytes(header[4])
+
+	if reader.err != nil {
+		return
+	}
+
+	values := stringTable(table, offsets)
+
+	// Names start after the last value.
+	start := 0
+
+	for _, offset := range offsets {
+		if offset >= 0 && offset < len(table) {
+			start = max(start, offset+strings.IndexByte(string(table[offset:]), 0)+1)
+		}
+	}
+
+	names := stringTable(table[min(start, len(table)):], nameOffsets)
+
+	info.extBools = make(map[string]bool)
+	info.extNumbers = make(map[string]int)
+	info.extStrings = make(map[string]string)
+
+	for i, name := range names {
+		switch {
+		case i < len(bools):
+			info.extBools[name] = bools[i]
+		case i < len(bools)+len(numbers):
+			info.extNumbers[name] = numbers[i-len(bools)]
+		case i-len(bools)-len(numbers) < len(values):
+			info.extStrings[name] = values[i-len(bools)-len(numbers)]
+		}
+	}
+}
+
+// stringTable returns the NUL-terminated strings found at the
+// given offsets of a string table, or empty strings if absent.
+func stringTable(table []byte, offsets []int) []string {
+	values := make([]string, len(offsets))
+
+	for i, offset := range offsets {
+		if offset < 0 || offset >= len(table) {
+			continue
+		}
+
+		value := string(table[offset:])
+		if end := strings.IndexByte(value, 0); end != -1 {
+			value = value[:end]
+		}
+
+		values[i] = value
+	}
+
+	return values
+}
+
+// tparm instantiates the parameters of a capability string. It implements the
+// parameterized strings language described in terminfo(5), except for padding.
+func tparm(format string, params ...int) string {
+	var (
+		out     strings.Builder
+		stack   []int
+		args    [9]int
+		vars    [26]int
+		skip    int // Nesting level of skipped conditional parts.
+		skipAll bool
+	)
+
+	copy(args[:], params)
+
+	push := func(value int) { stack = append(stack, value) }
+	pop := func() int {
+		if len(stack) == 0 {
+			return 0
+		}
+
+		value := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		return value
+	}
+
+	for pos := 0; pos < len(format); pos++ {
+		char := format[pos]
+
+		if char != '%' || pos+1 == len(format) {
+			if skip == 0 {
+				out.WriteByte(char)
+			}
+
+			continue
+		}
+
+		pos++
+		char = format[pos]
+
+		// Conditionals are handled even when skipping.
+		if skip > 0 {
+			switch {
+			case char == '?':
+				skip++
+			case char == ';':
+				skip--
+			case char == 'e' && skip == 1 && !skipAll:
+				skip = 0
+			}
+
+			continue
+		}
+
+		switch char {
+		case '%':
+			out.WriteByte('%')
+		case 'c':
+			out.WriteByte(byte(pop()))
+		case 's':
+			out.WriteString(strconv.Itoa(pop()))
+		case 'p':
+			if pos+1 < len(format) && format[pos+1] >= '1' && format[pos+1] <= '9' {
+				pos++
+				push(args[format[pos]-'1'])
+			}
+		case 'P', 'g':
+			if pos+1 < len(format) && format[pos+1] >= 'a' && format[pos+1] <= 'z' {
+				pos++
+				if char == 'P' {
+					vars[format[pos]-'a'] = pop()
+				} else {
+					push(vars[format[pos]-'a'])
+				}
+			}
+		case '\'':
+			if pos+2 < len(format) {
+				push(int(format[pos+1]))
+				pos += 2
+			}
+		case '{':
+			end := strings.IndexByte(format[pos:], '}')
+			if end == -1 {
+				return out.String()
+			}
+
+			value, _ := strconv.Atoi(format[pos+1 : pos+end])
+			push(value)
+			pos += end
+		case 'l':
+			push(len(strconv.Itoa(pop())))
+		case 'i':
+			args[0]++
+			args[1]++
+		case '+', '-', '*', '/', 'm', '&', '|', '^', '=', '<', '>', 'A', 'O':
+			second, first := pop(), pop()
+			push(tparmOperation(char, first, second))
+		case '!':
+			push(boolInt(pop() == 0))
+		case '~':
+			push(^pop())
+		case '?', ';':
+		case 't':
+			if pop() == 0 {
+				skip, skipAll = 1, false
+			}
+		case 'e':
+			// The "then" part was used: skip until the end.
+			skip, skipAll = 1, true
+		default:
+			// Formatted output: %[[:]flags][width[.precision]][doxXs]
+			end := pos
+			for end < len(format) && strings.IndexByte(":-+# .0123456789", format[end]) != -1 {
+				end++
+			}
+
+			if end == len(format) || strings.IndexByte("doxXs", format[end]) == -1 {
+				continue
+			}
+
+			spec := "%" + strings.TrimPrefix(format[pos:end], ":") + string(format[end])
+			if format[end] == 's' {
+				fmt.Fprintf(&out, spec, strconv.Itoa(pop()))
+			} else {
+				fmt.Fprintf(&out, spec, pop())
+			}
+
+			pos = end
+		}
+	}
+
+	return out.String()
+}
+
+func tparmOperation(operator byte, first, second int) int {
+	switch operator {
+	case '+':
+		return first + second
+	case '-':
+		return first - second
+	case '*':
+		return first * second
+	case '/':
+		if second == 0 {
+			return 0
+		}
+
+		return first / second
+	case 'm':
+		if second == 0 {
+			return 0
+		}
+
+		return first % second
+	case '&':
+		return first & second
+	case '|':
+		return first | second
+	case '^':
+		return first ^ second
+	case '=':
+		return boolInt(first == second)
+	case '<':
+		return boolInt(first < second)
+	case '>':
+		return boolInt(first > second)
+	case 'A':
+		return boolInt(first != 0 && second != 0)
+	case 'O':
+		return boolInt(first != 0 || second != 0)
+	}
+
+	return 0
+}
+
+func boolInt(value bool) int {
+	if value {
+		return 1
+	}
+
+	return 0
+}
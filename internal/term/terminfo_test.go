@@ -0,0 +1,139 @@
+package term
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLoadTerminfo(t *testing.T) {
+	t.Setenv("TERMINFO", "testdata/terminfo")
+
+	tests := []struct {
+		name       string
+		wantColors int
+		wantEl     string
+		wantCuu    string
+		wantSs     string
+	}{
+		{name: "xterm-256color", wantColors: 256, wantEl: "\x1b[K", wantCuu: "\x1b[3A", wantSs: "\x1b[2 q"},
+		{name: "linux", wantColors: 8, wantEl: "\x1b[K", wantCuu: "\x1b[3A"},
+		{name: "vt100", wantColors: -1, wantEl: "\x1b[K", wantCuu: "\x1b[3A"},
+		{name: "dumb", wantColors: -1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := LoadTerminfo(test.name)
+			if err != nil {
+				t.Fatalf("LoadTerminfo() error = %v", err)
+			}
+
+			if got := info.Names[0]; got != test.name {
+				t.Errorf("Terminfo.Names[0] = %q, want %q", got, test.name)
+			}
+
+			if got := info.Number("colors"); got != test.wantColors {
+				t.Errorf("Terminfo.Number(colors) = %d, want %d", got, test.wantColors)
+			}
+
+			if got := info.String("el"); got != test.wantEl {
+				t.Errorf("Terminfo.String(el) = %q, want %q", got, test.wantEl)
+			}
+
+			if got := info.Param("cuu", 3); got != test.wantCuu {
+				t.Errorf("Terminfo.Param(cuu, 3) = %q, want %q", got, test.wantCuu)
+			}
+
+			if got := info.Param("Ss", 2); got != test.wantSs {
+				t.Errorf("Terminfo.Param(Ss, 2) = %q, want %q", got, test.wantSs)
+			}
+		})
+	}
+
+	if _, err := LoadTerminfo("unknown"); err == nil {
+		t.Errorf("LoadTerminfo(unknown) error = nil, want ErrNoTerminfo")
+	}
+}
+
+func TestTparm(t *testing.T) {
+	const setaf = "\x1b[%?%p1%{8}%<%t3%p1%d%e%p1%{16}%<%t9%p1%{8}%-%d%e38;5;%p1%d%;m"
+
+	tests := []struct {
+		format string
+		params []int
+		want   string
+	}{
+		{format: "\x1b[%p1%dA", params: []int{3}, want: "\x1b[3A"},
+		{format: "\x1b[%i%p1%d;%p2%dH", params: []int{4, 9}, want: "\x1b[5;10H"},
+		{format: setaf, params: []int{1}, want: "\x1b[31m"},
+		{format: setaf, params: []int{9}, want: "\x1b[91m"},
+		{format: setaf, params: []int{200}, want: "\x1b[38;5;200m"},
+		{format: "%p1%c%p1%02d%p1%x%%", params: []int{65}, want: "A6541%"},
+		{format: "%p1%Pa%ga%ga%*%d", params: []int{7}, want: "49"},
+	}
+
+	for _, test := range tests {
+		if got := tparm(test.format, test.params...); got != test.want {
+			t.Errorf("tparm(%q, %v) = %q, want %q", test.format, test.params, got, test.want)
+		}
+	}
+}
+
+func TestTerminal_Write(t *testing.T) {
+	t.Setenv("TERMINFO", "testdata/terminfo")
+
+	tests := []struct {
+		name  string
+		term  string
+		write string
+		want  string
+	}{
+		{
+			name:  "No terminfo entry",
+			term:  "unknown",
+			write: "\x1b[3A\x1b[0K\x1b[2 q",
+			want:  "\x1b[3A\x1b[0K\x1b[2 q",
+		},
+		{
+			name:  "Xterm",
+			term:  "xterm-256color",
+			write: "\x1b[3A\x1b[0K\x1b[2 q\x1b[1;38;5;242mgrey\x1b[0m",
+			want:  "\x1b[3A\x1b[K\x1b[2 q\x1b[1;38;5;242mgrey\x1b[0m",
+		},
+		{
+			name:  "Linux console",
+			term:  "linux",
+			write: "\x1b[2 q\x1b[?25l\x1b[31mred\x1b[0m\x1b[?2004h",
+			want:  "\x1b[?25l\x1b[?1c\x1b[31mred\x1b[0m",
+		},
+		{
+			name:  "VT100",
+			term:  "vt100",
+			write: "\x1b7\x1b[1;31mred\x1b[0m\x1b[0K\x1b8",
+			want:  "\x1b7\x1b[1mred\x1b[0m\x1b[K\x1b8",
+		},
+		{
+			name:  "Dumb terminal",
+			term:  "dumb",
+			write: "\x1b]0;title\a\x1b[1;31mred\x1b[0m\x1b[2A\x1b[6n!",
+			want:  "red!",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			terminal := NewTerminal(nil, &out, nil)
+			terminal.SetType(test.term)
+
+			if _, err := terminal.Write([]byte(test.write)); err != nil {
+				t.Fatalf("Terminal.Write() error = %v", err)
+			}
+
+			if got := out.String(); got != test.want {
+				t.Errorf("Terminal.Write() wrote %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package ui
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/reeflective/readline/internal/color"
@@ -18,6 +17,13 @@ type Hint struct {
 	cleanup    bool
 	temp       bool
 	set        bool
+	term       *term.Terminal
+}
+
+// NewHint is a required constructor for the hint section,
+// which is displayed on the provided terminal.
+func NewHint(t *term.Terminal) *Hint {
+	return &Hint{term: t}
 }
 
 // Set sets the hint message to the given text.
@@ -78,7 +84,7 @@ func DisplayHint(hint *Hint) {
 
 	if len(hint.text) == 0 && len(hint.persistent) == 0 {
 		if hint.cleanup {
-			fmt.Print(term.ClearLineAfter)
+			hint.term.Print(term.ClearLineAfter)
 		}
 
 		hint.cleanup = false
@@ -95,7 +101,7 @@ func DisplayHint(hint *Hint) {
 	text += term.ClearLineAfter + color.Reset
 
 	if len(text) > 0 {
-		fmt.Print(text)
+		hint.term.Print(text)
 	}
 }
 
@@ -134,7 +140,7 @@ func CoordinatesHint(hint *Hint) int {
 	lines := strings.Split(text, term.ClearLineAfter)
 
 	for i, line := range lines {
-		x, y := strutil.LineSpan([]rune(line), i, 0)
+		x, y := strutil.LineSpan([]rune(line), i, 0, hint.term.Width())
 		if x != 0 {
 			y++
 		}
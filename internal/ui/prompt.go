@@ -2,8 +2,12 @@ package ui
 
 import (
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/reeflective/readline/inputrc"
 	"github.com/reeflective/readline/internal/core"
@@ -24,11 +28,16 @@ type Prompt struct {
 	rightF     func() string
 	tooltipF   func() string
 
+	// Terminal window
+	titleF     func() string
+	directoryF func() string
+
 	// True if some logs have printed asynchronously
 	// since last loop. Check refresh prompt funcs.
 	refreshing bool
 
 	// Shell parameters
+	term    *term.Terminal
 	line    *core.Line
 	cursor  *core.Cursor
 	keymaps *keymap.Engine
@@ -36,8 +45,9 @@ type Prompt struct {
 }
 
 // NewPrompt is a required constructor to initialize the prompt system.
-func NewPrompt(line *core.Line, cursor *core.Cursor, keymaps *keymap.Engine, opts *inputrc.Config) *Prompt {
+func NewPrompt(t *term.Terminal, line *core.Line, cursor *core.Cursor, keymaps *keymap.Engine, opts *inputrc.Config) *Prompt {
 	return &Prompt{
+		term:    t,
 		line:    line,
 		cursor:  cursor,
 		keymaps: keymaps,
@@ -85,11 +95,52 @@ func (p *Prompt) Tooltip(prompt func(word string) string) {
 	}
 }
 
+// Title uses a function returning the title of the terminal window,
+// which is set each time the primary prompt is printed.
+func (p *Prompt) Title(title func() string) {
+	p.titleF = title
+}
+
+// WorkingDirectory uses a function returning the working directory of the application,
+// which is reported to the terminal each time the primary prompt is printed (OSC 7):
+// terminals use it to open new tabs and windows in the same directory.
+func (p *Prompt) WorkingDirectory(dir func() string) {
+	p.directoryF = dir
+}
+
+// SetSecret makes the prompt engine use a single primary prompt while the shell
+// reads a secret (like a password): other prompts are not displayed, since some of
+// them (like tooltips) are computed from the input line. The returned function must
+// be called to restore the prompts as they were before.
+func SetSecret(p *Prompt, prompt func() string) (restore func()) {
+	saved := *p
+
+	p.primaryF = prompt
+	p.secondaryF = nil
+	p.transientF = nil
+	p.rightF = nil
+	p.tooltipF = nil
+
+	return func() {
+		p.primaryF = saved.primaryF
+		p.secondaryF = saved.secondaryF
+		p.transientF = saved.transientF
+		p.rightF = saved.rightF
+		p.tooltipF = saved.tooltipF
+	}
+}
+
 // PrimaryPrint prints the primary prompt string, excluding
 // the last line if the primary prompt spans on several lines.
 func (p *Prompt) PrimaryPrint() {
 	p.refreshing = false
 
+	p.term.Print(p.terminalStatus())
+
+	if p.shellIntegration() {
+		p.term.Print(term.PromptStart)
+	}
+
 	if p.primaryF == nil {
 		return
 	}
@@ -103,10 +154,10 @@ func (p *Prompt) PrimaryPrint() {
 
 	// Print the various lines.
 	if prompt != "" {
-		fmt.Print(prompt)
+		p.term.Print(prompt)
 	}
 
-	fmt.Print(lastPrompt)
+	p.term.Print(lastPrompt)
 
 	// And compute coordinates
 	p.primaryRows = strings.Count(prompt, "\n")
@@ -128,6 +179,11 @@ func (p *Prompt) PrimaryUsed() int {
 // spans on several lines. If not, this function will actually print
 // the entire primary prompt, and PrimaryPrint() will not print anything.
 func (p *Prompt) LastPrint() {
+	// The input line starts after the prompt.
+	if p.shellIntegration() {
+		defer p.term.Print(term.InputStart)
+	}
+
 	if p.primaryF == nil {
 		return
 	}
@@ -144,7 +200,7 @@ func (p *Prompt) LastPrint() {
 
 	prompt := p.formatLastPrompt(lines[len(lines)-1])
 
-	fmt.Print(prompt)
+	p.term.Print(prompt)
 
 	p.primaryCols = strutil.RealLength(prompt)
 	if p.primaryCols > 0 {
@@ -199,9 +255,9 @@ func (p *Prompt) RightPrint(startColumn int, force bool) {
 	}
 
 	if prompt, canPrint := p.formatRightPrompt(rprompt, startColumn); canPrint {
-		fmt.Print(prompt)
+		p.term.Print(prompt)
 	} else {
-		fmt.Print(term.ClearLineAfter)
+		p.term.Print(term.ClearLineAfter)
 	}
 }
 
@@ -212,12 +268,12 @@ func (p *Prompt) TransientPrint() {
 	}
 
 	// Clean everything below where the prompt will be printed.
-	term.MoveCursorBackwards(term.GetWidth())
-	term.MoveCursorUp(p.primaryRows)
-	fmt.Print(term.ClearScreenBelow)
+	p.term.MoveCursorBackwards(p.term.Width())
+	p.term.MoveCursorUp(p.primaryRows)
+	p.term.Print(term.ClearScreenBelow)
 
 	// And print the prompt
-	fmt.Print(p.transientF())
+	p.term.Print(p.transientF())
 }
 
 // Refreshing returns true if the prompt is currently redisplaying
@@ -226,6 +282,50 @@ func (p *Prompt) Refreshing() bool {
 	return p.refreshing
 }
 
+// shellIntegration returns true if the prompt and the input line
+// are marked for the terminal with OSC 133 sequences.
+func (p *Prompt) shellIntegration() bool {
+	return p.opts.GetBool("enable-shell-integration")
+}
+
+// terminalStatus returns the sequences setting the window title
+// and reporting the working directory, if any, to the terminal.
+func (p *Prompt) terminalStatus() (status string) {
+	if p.titleF != nil {
+		title := strings.Map(func(char rune) rune {
+			if unicode.IsControl(char) {
+				return -1
+			}
+
+			return char
+		}, p.titleF())
+
+		status += fmt.Sprintf(term.WindowTitle, title)
+	}
+
+	if p.directoryF != nil {
+		if dir := p.directoryF(); dir != "" {
+			status += fmt.Sprintf(term.WorkingDirectory, directoryURL(dir))
+		}
+	}
+
+	return status
+}
+
+// directoryURL returns the file URL of a directory on the
+// local host, like "file://host/home/user" (see RFC 8089).
+func directoryURL(dir string) string {
+	host, _ := os.Hostname()
+	path := filepath.ToSlash(dir)
+
+	// Windows paths start with their volume name.
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	return (&url.URL{Scheme: "file", Host: host, Path: path}).String()
+}
+
 func (p *Prompt) formatLastPrompt(prompt string) string {
 	if !p.opts.GetBool("show-mode-in-prompt") {
 		return prompt
@@ -259,7 +359,7 @@ func (p *Prompt) formatLastPrompt(prompt string) string {
 
 func (p *Prompt) formatRightPrompt(rprompt string, startColumn int) (prompt string, canPrint bool) {
 	// Dimensions
-	termWidth := term.GetWidth()
+	termWidth := p.term.Width()
 	promptLen := strutil.RealLength(rprompt)
 	padLen := termWidth - startColumn - promptLen
 
@@ -0,0 +1,62 @@
+package readline
+
+import (
+	"context"
+
+	"github.com/reeflective/readline/internal/completion"
+	"github.com/reeflective/readline/internal/core"
+	"github.com/reeflective/readline/internal/display"
+	"github.com/reeflective/readline/internal/editor"
+	"github.com/reeflective/readline/internal/history"
+	"github.com/reeflective/readline/internal/ui"
+)
+
+// ReadPassword displays the given prompt and reads a secret, like a password.
+// The line is edited with the usual keymaps (Emacs or Vi), but is displayed with
+// each character replaced by the shell PasswordMask, or not displayed at all.
+//
+// While reading the secret, the shell does not use any of the features which
+// could leak it: it is not written to the history nor suggested from it, and no
+// completion, syntax highlighting, kill-ring/register writes, keyboard macros or
+// editing in $EDITOR are available. Other prompts (right, tooltip) are not shown,
+// and the caller AcceptMultiline function is not called. The input line buffer and
+// the keys used to type the secret are zeroed before returning it: this is only done
+// on a best-effort basis, since copies made along the way (like the buffers replaced
+// when the line grows, or the string returned) cannot be reached to be cleared.
+func (rl *Shell) ReadPassword(prompt string) (string, error) {
+	return rl.ReadPasswordContext(context.Background(), prompt)
+}
+
+// ReadPasswordContext is like ReadPassword, but also returns when the context
+// is done, or when the shell IdleTimeout elapses, like ReadlineContext does.
+func (rl *Shell) ReadPasswordContext(ctx context.Context, prompt string) (string, error) {
+	restore := ui.SetSecret(rl.Prompt, func() string { return prompt })
+	defer restore()
+
+	rl.setSecret(true)
+	defer rl.setSecret(false)
+
+	return rl.ReadlineContext(ctx)
+}
+
+// setSecret enables or disables the secret mode of all shell components.
+// When disabled, the input line buffer is zeroed, since it holds the secret.
+func (rl *Shell) setSecret(secret bool) {
+	rl.secret = secret
+
+	core.SetSecret(rl.Keys, secret)
+	history.SetSecret(rl.History, secret)
+	editor.SetSecret(rl.Buffers, secret)
+	completion.SetSecret(rl.completer, secret)
+	display.SetSecret(rl.Display, secret, rl.PasswordMask)
+
+	if secret {
+		return
+	}
+
+	clear(*rl.line)
+	rl.line.Set()
+	rl.cursor.Set(0)
+	rl.cursor.ResetMark()
+	rl.selection.Reset()
+}
@@ -19,9 +19,13 @@
 package readline
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/reeflective/readline/inputrc"
 	"github.com/reeflective/readline/internal/color"
@@ -38,6 +42,10 @@ import (
 // is pressed on the keyboard. The sequence is usually Ctrl-C.
 var ErrInterrupt = errors.New(os.Interrupt.String())
 
+// ErrIdleTimeout is returned when no key has been input during
+// the shell idle timeout, similarly to the TMOUT bash variable.
+var ErrIdleTimeout = core.ErrIdleTimeout
+
 // Readline displays the readline prompt and reads user input.
 // It can return from the call because of different things:
 //
@@ -48,31 +56,88 @@ var ErrInterrupt = errors.New(os.Interrupt.String())
 // In all cases, the current input line is returned along with any error,
 // and it is up to the caller to decide what to do with the line result.
 // When the error is not nil, the returned line is not written to history.
+//
+// If the shell input is not a terminal (like a pipe or a file), lines are
+// read as is, without any prompt nor line edition: see the readPlain method.
 func (rl *Shell) Readline() (string, error) {
-	descriptor := int(os.Stdin.Fd())
+	return rl.ReadlineContext(context.Background())
+}
+
+// ReadlineContext is like Readline, but also returns when the context is done,
+// along with the context error and the current input line. The terminal state
+// is restored, and the line is either redisplayed as if it had been accepted,
+// or cleared along with the prompt if the shell ClearOnCancel field is true.
+//
+// If the shell IdleTimeout is positive, the call also returns ErrIdleTimeout
+// when no key has been input for this duration: this can be used to log out
+// inactive users, like the TMOUT variable does in bash.
+func (rl *Shell) ReadlineContext(ctx context.Context) (string, error) {
+	if !rl.term.IsTerminal() {
+		return rl.readPlain(ctx)
+	}
+
+	// Streams that are not terminal files (like network
+	// ones) are assumed to be in raw mode already.
+	if descriptor, isFile := rl.term.Fd(); isFile {
+		state, err := term.MakeRaw(descriptor)
+		if err != nil {
+			return "", err
+		}
+
+		rl.cooked = state
 
-	state, err := term.MakeRaw(descriptor)
-	if err != nil {
-		return "", err
+		defer func() {
+			term.Restore(descriptor, state)
+			rl.cooked = nil
+		}()
 	}
-	defer term.Restore(descriptor, state)
+
+	// Until the line is returned, messages printed by other
+	// goroutines are synchronized with the shell display.
+	rl.Display.Start()
+	defer rl.Display.Stop()
+
+	// Optional terminal modes (bracketed paste, extended keys, mouse).
+	modesOn, modesOff := rl.terminalModes()
+	rl.term.Print(modesOn)
+	defer rl.term.Print(modesOff)
 
 	// Prompts and cursor styles
 	rl.Display.PrintPrimaryPrompt()
-	defer rl.Display.RefreshTransient()
-	defer fmt.Print(keymap.CursorStyle("default"))
+	defer rl.term.Print(keymap.CursorStyle("default"))
+
+	cleared := false
+
+	defer func() {
+		if !cleared {
+			rl.Display.RefreshTransient()
+		}
+
+		rl.Display.MarkOutput()
+	}()
 
 	rl.init()
 
+	// Blocking reads are aborted when the context is done.
+	core.SetContext(ctx, rl.Keys, rl.IdleTimeout)
+	defer core.SetContext(context.Background(), rl.Keys, 0)
+
 	// Terminal resize events
 	resize := display.WatchResize(rl.Display)
 	defer close(resize)
 
+	// The process might be suspended and resumed by job control.
+	resume := display.WatchResume(rl.Display, rl.restoreTerminal)
+	defer close(resume)
+
 	for {
 		// Whether or not the command is resolved, let the macro
 		// engine record the keys if currently recording a macro.
 		// This is done before flushing all used keys, on purpose.
-		macro.RecordKeys(rl.Macros)
+		// The keys of a secret line are never recorded.
+		if !rl.secret {
+			macro.RecordKeys(rl.Macros)
+		}
 
 		// Get the rid of the keys that were consumed during the
 		// previous command run. This may include keys that have
@@ -86,7 +151,15 @@ func (rl *Shell) Readline() (string, error) {
 		// Block and wait for available user input keys.
 		// These might be read on stdin, or already available because
 		// the macro engine has fed some keys in bulk when running one.
-		core.WaitAvailableKeys(rl.Keys, rl.Config)
+		// Other goroutines can print to the display while we wait.
+		rl.Display.Release()
+		err := core.WaitAvailableKeys(rl.Keys, rl.Config)
+		rl.Display.Acquire()
+
+		if err != nil {
+			cleared = rl.ClearOnCancel
+			return rl.cancel(err)
+		}
 
 		// 1 - Local keymap (Completion/Isearch/Vim operator pending).
 		bind, command, prefixed := keymap.MatchLocal(rl.Keymap)
@@ -125,6 +198,107 @@ func (rl *Shell) Readline() (string, error) {
 	}
 }
 
+// terminalModes returns the sequences enabling the optional terminal modes used while
+// reading a line, and the ones disabling them (in reverse order) once the line is read.
+func (rl *Shell) terminalModes() (on, off string) {
+	// Pasted text is read and inserted as a single block.
+	if rl.Config.GetBool("enable-bracketed-paste") && rl.term.Features().BracketedPaste {
+		on, off = on+term.BracketedPasteOn, term.BracketedPasteOff+off
+	}
+
+	// Keys with modifiers are reported unambiguously.
+	if rl.Config.GetBool("enable-extended-keys") {
+		on, off = on+term.ExtendedKeysOn, term.ExtendedKeysOff+off
+	}
+
+	// Mouse clicks and wheel scrolls are reported as keys.
+	if rl.Config.GetBool("enable-mouse") {
+		on, off = on+term.MouseOn, term.MouseOff+off
+	}
+
+	return on, off
+}
+
+// ReadlineWithInitial is like Readline, but the input line initially contains text,
+// with the cursor at the given position (clamped to the text bounds), so that users
+// can edit a default value, or a line previously rejected by the application. The
+// initial text is the first undo state of the line: revert-line goes back to it.
+//
+// If the shell input is not a terminal, the initial text is ignored.
+func (rl *Shell) ReadlineWithInitial(text string, cursor int) (string, error) {
+	return rl.ReadlineWithInitialContext(context.Background(), text, cursor)
+}
+
+// ReadlineWithInitialContext is like ReadlineWithInitial, but also
+// returns when the context is done, like ReadlineContext does.
+func (rl *Shell) ReadlineWithInitialContext(ctx context.Context, text string, cursor int) (string, error) {
+	rl.initial = []rune(text)
+	rl.initialPos = cursor
+
+	defer func() {
+		rl.initial = nil
+		rl.initialPos = 0
+	}()
+
+	return rl.ReadlineContext(ctx)
+}
+
+// readPlain reads a line from an input which is not a terminal, like a pipe or a file,
+// so that scripted sessions can run the same command loop as interactive ones.
+// No prompt nor escape sequence is printed, but lines are still continued as long
+// as AcceptMultiline rejects them, and accepted lines are written to the history.
+// The context and the idle timeout are honored like when reading from a terminal.
+func (rl *Shell) readPlain(ctx context.Context) (string, error) {
+	core.SetContext(ctx, rl.Keys, rl.IdleTimeout)
+	defer core.SetContext(context.Background(), rl.Keys, 0)
+
+	if rl.input == nil {
+		rl.input = bufio.NewReader(plainReader{rl.Keys})
+	}
+
+	var lines []string
+
+	for {
+		line, err := rl.input.ReadString('\n')
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if err != nil && line == "" && len(lines) == 0 {
+			return "", err
+		}
+
+		lines = append(lines, line)
+		buffer := strings.Join(lines, "\n")
+
+		// The last line of the input might not end with a newline,
+		// but it must still be complete to be accepted.
+		complete := rl.AcceptMultiline == nil || rl.secret || rl.AcceptMultiline([]rune(buffer))
+
+		switch {
+		case err != nil && !errors.Is(err, io.EOF):
+			return buffer, err
+		case !complete && err != nil:
+			return buffer, err
+		case !complete:
+			continue
+		}
+
+		rl.line.Set([]rune(buffer)...)
+		rl.History.Write(false)
+		rl.lineAccepted(buffer)
+
+		return buffer, nil
+	}
+}
+
+// plainReader reads the shell input when the latter is not a terminal.
+type plainReader struct {
+	keys *core.Keys
+}
+
+func (r plainReader) Read(p []byte) (n int, err error) {
+	return core.ReadInput(r.keys, p)
+}
+
 // init gathers all steps to perform at the beginning of readline loop.
 func (rl *Shell) init() {
 	// Reset core editor components.
@@ -142,6 +316,14 @@ func (rl *Shell) init() {
 	// line outright, or keep the accepted one.
 	history.Init(rl.History)
 
+	// The caller might want to start with an editable line:
+	// it is saved as the first undo state, for revert-line.
+	if rl.initial != nil {
+		rl.line.Set(rl.initial...)
+		rl.cursor.Set(rl.initialPos)
+		rl.History.Save()
+	}
+
 	// Reset/initialize user interface components.
 	rl.Hint.Reset()
 	rl.completer.ResetForce()
@@ -168,12 +350,21 @@ func (rl *Shell) run(main bool, bind inputrc.Bind, command func()) (bool, string
 	// input line and be using it with a virtual insertion,
 	// so it knows which line and cursor we should work on.
 	rl.line, rl.cursor, rl.selection = rl.completer.GetBuffer()
+	before, pos := rl.lineState()
 
 	// The command might be nil, because the provided key sequence
 	// did not match any. We regardless execute everything related
 	// to the command, like any pending ones, and cursor checks.
+	if command != nil && rl.PreCommand != nil {
+		rl.PreCommand(bind)
+	}
+
 	rl.execute(command)
 
+	if command != nil && rl.PostCommand != nil {
+		rl.PostCommand(bind)
+	}
+
 	// Either print/clear iterations/active registers hints.
 	rl.updatePosRunHints()
 
@@ -191,8 +382,29 @@ func (rl *Shell) run(main bool, bind inputrc.Bind, command func()) (bool, string
 	// which case this will automatically write the history
 	// sources and set up errors/returned line values.
 	rl.History.SaveWithCommand(bind)
+	rl.lineChanged(before, pos)
+
+	accepted, line, err := rl.History.LineAccepted()
+	if accepted && err == nil {
+		rl.lineAccepted(line)
+	}
 
-	return rl.History.LineAccepted()
+	return accepted, line, err
+}
+
+// cancel redisplays the interface when reading keys has been aborted
+// (context done or idle timeout), and returns the current input line.
+func (rl *Shell) cancel(err error) (string, error) {
+	rl.completer.ResetForce()
+	line := string(*rl.line)
+
+	if rl.ClearOnCancel {
+		rl.Display.ClearLine()
+	} else {
+		rl.Display.AcceptLine()
+	}
+
+	return line, err
 }
 
 // Run the dispatched command, any pending operator
@@ -241,6 +453,11 @@ func (rl *Shell) handleUndefined(bind inputrc.Bind, cmd func()) {
 		return
 	}
 
+	// The keys are still used by the shell, like for macros.
+	if rl.OnUndefinedKey != nil {
+		rl.OnUndefinedKey(append([]rune(nil), rl.Keys.Caller()...))
+	}
+
 	// Undefined keys incremental-search mode cancels it.
 	if rl.Keymap.Local() == keymap.Isearch {
 		rl.Hint.Reset()
@@ -0,0 +1,88 @@
+package readlinetest
+
+import (
+	"context"
+	"testing"
+)
+
+func TestShell_Clipboard(t *testing.T) {
+	tests := []struct {
+		name      string
+		vi        bool
+		killRing  bool
+		clipboard map[byte]string // Clipboard selections answered by the terminal, if any.
+		keys      []string
+		want      string
+		wantCopy  map[byte]string
+	}{
+		{
+			name:     "Yank to the clipboard register",
+			vi:       true,
+			keys:     []string{"\x1b", "0", "\"", "+", "yw"},
+			want:     "foo bar",
+			wantCopy: map[byte]string{'c': "foo "},
+		},
+		{
+			name:     "Yank to the primary selection register",
+			vi:       true,
+			keys:     []string{"\x1b", "0", "\"", "*", "yw"},
+			want:     "foo bar",
+			wantCopy: map[byte]string{'c': "", 'p': "foo "},
+		},
+		{
+			name:      "Put from the clipboard register",
+			vi:        true,
+			clipboard: map[byte]string{'c': "xyz"},
+			keys:      []string{"\x1b", "0", "\"", "+", "p"},
+			want:      "fxyzoo bar",
+		},
+		{
+			name:     "Kill ring copied to the clipboard",
+			killRing: true,
+			keys:     []string{"\x17"},
+			want:     "foo ",
+			wantCopy: map[byte]string{'c': "bar"},
+		},
+		{
+			name:     "Kill ring not copied by default",
+			keys:     []string{"\x17"},
+			want:     "foo ",
+			wantCopy: map[byte]string{'c': ""},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shell := newTestShell(t, 20, 5)
+			shell.Config.Set("kill-ring-clipboard", test.killRing)
+
+			if test.vi {
+				shell.Keymap.SetMain("vi-insert")
+			}
+
+			for selection, text := range test.clipboard {
+				shell.Terminal.SetClipboard(selection, text)
+			}
+
+			shell.StartWith(func(ctx context.Context) (string, error) {
+				return shell.ReadlineWithInitialContext(ctx, "foo bar", 7)
+			})
+
+			for _, key := range test.keys {
+				shell.Type(key)
+			}
+
+			shell.Type("\r")
+
+			if got, _ := shell.Result(); got != test.want {
+				t.Errorf("Shell.Result() = %q, want %q", got, test.want)
+			}
+
+			for selection, want := range test.wantCopy {
+				if got := shell.Terminal.Clipboard(selection); got != want {
+					t.Errorf("Terminal.Clipboard(%q) = %q, want %q", selection, got, want)
+				}
+			}
+		})
+	}
+}
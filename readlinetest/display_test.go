@@ -0,0 +1,258 @@
+package readlinetest
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/reeflective/readline/inputrc"
+)
+
+func TestShell_Viewport(t *testing.T) {
+	const buffer = "l1\nl2\nl3\nl4\nl5\nl6\nl7\nl8"
+
+	tests := []struct {
+		name       string
+		cursor     int
+		keys       []string
+		wantScreen []string
+		wantX      int
+		wantY      int
+	}{
+		{
+			name:       "Cursor on the first line",
+			cursor:     0,
+			wantScreen: []string{"> l1", "  l2", "  l3", "  5 more lines belo"},
+			wantX:      2,
+			wantY:      0,
+		},
+		{
+			name:       "Cursor on the last line",
+			cursor:     len(buffer),
+			wantScreen: []string{"> 5 more lines abov", "  l6", "  l7", "  l8"},
+			wantX:      4,
+			wantY:      3,
+		},
+		{
+			name:       "Scrolling down",
+			cursor:     0,
+			keys:       []string{"\x0e\x0e\x0e\x0e"},
+			wantScreen: []string{"> 2 more lines abov", "  l3", "  l4", "  l5", "  3 more lines belo"},
+			wantX:      2,
+			wantY:      3,
+		},
+		{
+			name:       "Scrolling back up keeps the viewport",
+			cursor:     0,
+			keys:       []string{"\x0e\x0e\x0e\x0e", "\x10\x10"},
+			wantScreen: []string{"> 2 more lines abov", "  l3", "  l4", "  l5", "  3 more lines belo"},
+			wantX:      2,
+			wantY:      1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shell := newTestShell(t, 20, 6)
+
+			binds := "\"\\C-n\": down-line-or-history\n\"\\C-p\": up-line-or-history\n"
+			if err := inputrc.ParseBytes([]byte(binds), shell.Config); err != nil {
+				t.Fatalf("inputrc.ParseBytes() error = %v", err)
+			}
+
+			shell.StartWith(func(ctx context.Context) (string, error) {
+				return shell.ReadlineWithInitialContext(ctx, buffer, test.cursor)
+			})
+
+			for _, key := range test.keys {
+				shell.Type(key)
+			}
+
+			if screen := shell.Screen()[:len(test.wantScreen)]; !reflect.DeepEqual(screen, test.wantScreen) {
+				t.Errorf("Shell.Screen() = %q, want %q", screen, test.wantScreen)
+			}
+
+			if x, y := shell.Cursor(); x != test.wantX || y != test.wantY {
+				t.Errorf("Shell.Cursor() = (%d, %d), want (%d, %d)", x, y, test.wantX, test.wantY)
+			}
+
+			// The whole buffer is displayed once accepted.
+			shell.Type("\r")
+
+			if got, _ := shell.Result(); got != buffer {
+				t.Errorf("Shell.Result() = %q, want %q", got, buffer)
+			}
+
+			if screen := shell.Screen()[:5]; screen[4] != "  l8" {
+				t.Errorf("Shell.Screen() = %q, want the last line displayed", screen)
+			}
+		})
+	}
+}
+
+func TestShell_HorizontalScrollMode(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       []string
+		wantScreen string
+		wantX      int
+	}{
+		{
+			name:       "Line fitting in the terminal",
+			keys:       []string{"0123456789abcde"},
+			wantScreen: "> 0123456789abcde",
+			wantX:      17,
+		},
+		{
+			name:       "Cursor at the end of a long line",
+			keys:       []string{"0123456789abcdefghijklmnop"},
+			wantScreen: "> <jklmnop",
+			wantX:      10,
+		},
+		{
+			name:       "Cursor at the beginning of a long line",
+			keys:       []string{"0123456789abcdefghijklmnop", "\x01"},
+			wantScreen: "> 0123456789abcdef>",
+			wantX:      2,
+		},
+		{
+			name:       "Cursor moved back into view",
+			keys:       []string{"0123456789abcdefghijklmnop", "\x01", "\x1bf\x1bf"},
+			wantScreen: "> <jklmnop",
+			wantX:      10,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shell := newTestShell(t, 20, 5)
+			shell.Config.Set("horizontal-scroll-mode", true)
+			shell.Start()
+
+			for _, key := range test.keys {
+				shell.Type(key)
+			}
+
+			if screen := shell.Screen(); screen[0] != test.wantScreen || screen[1] != "" {
+				t.Errorf("Shell.Screen() = %q, want %q on a single row", screen, test.wantScreen)
+			}
+
+			if x, y := shell.Cursor(); x != test.wantX || y != 0 {
+				t.Errorf("Shell.Cursor() = (%d, %d), want (%d, 0)", x, y, test.wantX)
+			}
+		})
+	}
+}
+
+func TestShell_Graphemes(t *testing.T) {
+	const (
+		accent = "e\u0301"
+		family = "\U0001F468\u200D\U0001F469\u200D\U0001F467"
+		flag   = "\U0001F1EB\U0001F1F7"
+	)
+
+	tests := []struct {
+		name  string
+		text  string
+		keys  []string
+		want  string
+		wantX int
+	}{
+		{
+			name:  "Backward char over a combining accent",
+			text:  "caf" + accent,
+			keys:  []string{"\x02"},
+			want:  "caf" + accent,
+			wantX: 5,
+		},
+		{
+			name:  "Backward char over wide characters",
+			text:  "a" + family + flag,
+			keys:  []string{"\x02", "\x02"},
+			want:  "a" + family + flag,
+			wantX: 3,
+		},
+		{
+			name:  "Delete char",
+			text:  "caf" + accent + "s",
+			keys:  []string{"\x02", "\x02", "\x04"},
+			want:  "cafs",
+			wantX: 5,
+		},
+		{
+			name:  "Backward delete char",
+			text:  "a" + family,
+			keys:  []string{"\x7f"},
+			want:  "a",
+			wantX: 3,
+		},
+		{
+			name:  "Transpose chars",
+			text:  "a" + flag + accent,
+			keys:  []string{"\x02", "\x14"},
+			want:  "a" + accent + flag,
+			wantX: 6,
+		},
+		{
+			name:  "Vi delete and change case",
+			text:  accent + flag + "x",
+			keys:  []string{"\x1b", "0", "~", "l", "x"},
+			want:  "E\u0301x",
+			wantX: 3,
+		},
+		{
+			name:  "Vi replace char",
+			text:  "a" + family + "b",
+			keys:  []string{"\x1b", "h", "r", "c"},
+			want:  "acb",
+			wantX: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shell := newTestShell(t, 20, 5)
+			if strings.HasPrefix(test.name, "Vi") {
+				shell.Keymap.SetMain("vi-insert")
+			}
+
+			shell.StartWith(func(ctx context.Context) (string, error) {
+				return shell.ReadlineWithInitialContext(ctx, test.text, len([]rune(test.text)))
+			})
+
+			for _, key := range test.keys {
+				shell.Type(key)
+			}
+
+			if x, _ := shell.Cursor(); x != test.wantX {
+				t.Errorf("Shell.Cursor() x = %d, want %d", x, test.wantX)
+			}
+
+			shell.Type("\r")
+
+			if got, _ := shell.Result(); got != test.want {
+				t.Errorf("Shell.Result() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestShell_DumbTerminal(t *testing.T) {
+	terminfo, _ := filepath.Abs("../internal/term/testdata/terminfo")
+	t.Setenv("TERMINFO", terminfo)
+
+	shell := newTestShell(t, 30, 5)
+	shell.SetTerminalType("dumb")
+
+	// The cursor position is not queried, since it would never be answered.
+	line, err := shell.Run("echo dumb", "\r")
+	if err != nil || line != "echo dumb" {
+		t.Fatalf("Shell.Run() = %q, %v, want %q", line, err, "echo dumb")
+	}
+
+	if screen := strings.Join(shell.Screen(), "\n"); !strings.Contains(screen, "echo dumb") {
+		t.Errorf("screen = %q, want the line displayed", screen)
+	}
+}
@@ -0,0 +1,82 @@
+package readlinetest
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/reeflective/readline/inputrc"
+)
+
+func TestShell_Hooks(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+
+	var events []string
+
+	shell.PreCommand = func(bind inputrc.Bind) {
+		events = append(events, "pre "+bind.Action)
+	}
+	shell.PostCommand = func(bind inputrc.Bind) {
+		events = append(events, "post "+bind.Action)
+	}
+	shell.OnLineChange = func(line []rune, cursor int) {
+		events = append(events, fmt.Sprintf("line %q %d", string(line), cursor))
+	}
+	shell.OnUndefinedKey = func(keys []rune) {
+		events = append(events, fmt.Sprintf("undefined %q", string(keys)))
+	}
+	shell.OnAccept = func(line string) {
+		events = append(events, "accept "+line)
+	}
+
+	if _, err := shell.Run("ab", "\x01", "\x1c", "\r"); err != nil {
+		t.Fatalf("Shell.Run() error = %v", err)
+	}
+
+	want := []string{
+		"pre self-insert", "post self-insert", `line "a" 1`,
+		"pre self-insert", "post self-insert", `line "ab" 2`,
+		"pre beginning-of-line", "post beginning-of-line", `line "ab" 0`,
+		`undefined "\x1c"`,
+		"pre accept-line", "post accept-line", "accept ab",
+	}
+
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("hooks called with %q, want %q", events, want)
+	}
+}
+
+func TestShell_OnUndefinedKeyCopy(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+
+	// Changing the keys does not change those recorded in a macro.
+	shell.OnUndefinedKey = func(keys []rune) {
+		keys[0] = 'b'
+	}
+
+	got, err := shell.Run("\x18(", "a", "\x1c", "\x18)", "\x18e", "\r")
+	if err != nil || got != "aa" {
+		t.Errorf("Shell.Run() = %q, %v, want %q", got, err, "aa")
+	}
+}
+
+func TestShell_OnKeymapChange(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+	shell.Keymap.SetMain("vi-insert")
+
+	var events []string
+
+	shell.OnKeymapChange = func(old, new string) {
+		events = append(events, old+" -> "+new)
+	}
+
+	shell.Start()
+	shell.Type("echo")
+	shell.Type("\x1b")
+	shell.Type("i")
+
+	want := []string{"vi-insert -> vi-command", "vi-command -> vi-insert"}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Shell.OnKeymapChange() called with %q, want %q", events, want)
+	}
+}
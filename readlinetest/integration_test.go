@@ -0,0 +1,58 @@
+package readlinetest
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestShell_ShellIntegration(t *testing.T) {
+	host, _ := os.Hostname()
+
+	tests := []struct {
+		name      string
+		enabled   bool
+		wantMarks []string
+	}{
+		{
+			name:      "Prompt, input and output marked",
+			enabled:   true,
+			wantMarks: []string{"A", "B", "C", "D;2", "A", "B", "C"},
+		},
+		{
+			name: "No marks by default",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shell := newTestShell(t, 20, 5)
+			shell.Config.Set("enable-shell-integration", test.enabled)
+			shell.Prompt.Title(func() string { return "shell\a" })
+			shell.Prompt.WorkingDirectory(func() string { return "/home/user/my dir" })
+
+			if _, err := shell.Run("false", "\r"); err != nil {
+				t.Fatalf("Shell.Run() error = %v", err)
+			}
+
+			shell.CommandDone(2)
+
+			if _, err := shell.Run("exit", "\r"); err != nil {
+				t.Fatalf("Shell.Run() error = %v", err)
+			}
+
+			if got := shell.Terminal.Marks(); !reflect.DeepEqual(got, test.wantMarks) {
+				t.Errorf("Terminal.Marks() = %q, want %q", got, test.wantMarks)
+			}
+
+			if got := shell.Terminal.Title(); got != "shell" {
+				t.Errorf("Terminal.Title() = %q, want %q", got, "shell")
+			}
+
+			wantDir := "file://" + host + "/home/user/my%20dir"
+			if got := shell.Terminal.WorkingDirectory(); got != wantDir {
+				t.Errorf("Terminal.WorkingDirectory() = %q, want %q", got, wantDir)
+			}
+		})
+	}
+}
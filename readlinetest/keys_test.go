@@ -0,0 +1,104 @@
+package readlinetest
+
+import (
+	"testing"
+
+	"github.com/reeflective/readline/inputrc"
+)
+
+func TestShell_KeyseqTimeout(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+	shell.Keymap.SetMain("vi-insert")
+	shell.Config.Set("keyseq-timeout", 200)
+
+	shell.Start()
+	shell.Type("abc")
+
+	// An arrow key split across two reads.
+	shell.Terminal.Input("\x1b")
+
+	if !shell.Terminal.waitReading(nil, Timeout) {
+		t.Fatalf("the shell has not read the escape key")
+	}
+
+	shell.Type("[D")
+
+	if got := string(shell.Keymap.Main()); got != "vi-insert" {
+		t.Errorf("Shell.Keymap.Main() = %q, want %q", got, "vi-insert")
+	}
+
+	if x, _ := shell.Cursor(); x != 4 {
+		t.Errorf("Shell.Cursor() x = %d, want 4", x)
+	}
+
+	// A lone escape key.
+	shell.Type("\x1b")
+
+	if got := string(shell.Keymap.Main()); got != "vi-command" {
+		t.Errorf("Shell.Keymap.Main() = %q, want %q", got, "vi-command")
+	}
+}
+
+func TestShell_ExtendedKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		want string
+	}{
+		{
+			name: "Bound Shift-Enter inserts a newline",
+			keys: []string{"a", "\x1b[13;2u", "b", "\r"},
+			want: "a\nb",
+		},
+		{
+			name: "Unbound Control-Enter accepts the line",
+			keys: []string{"ab", "\x1b[13;5u"},
+			want: "ab",
+		},
+		{
+			name: "Control key (kitty)",
+			keys: []string{"bc", "\x1b[97;5u", "a", "\r"},
+			want: "abc",
+		},
+		{
+			name: "Control key (modifyOtherKeys)",
+			keys: []string{"bc", "\x1b[27;5;97~", "a", "\r"},
+			want: "abc",
+		},
+		{
+			name: "Meta key",
+			keys: []string{"foo bar", "\x1b[98;3u", "x", "\r"},
+			want: "foo xbar",
+		},
+		{
+			name: "Unbound Super key is ignored",
+			keys: []string{"a", "\x1b[97;9u", "b", "\r"},
+			want: "ab",
+		},
+		{
+			name: "Sequence split across reads",
+			keys: []string{"bc", "\x1b[97;", "5u", "a", "\r"},
+			want: "abc",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shell := newTestShell(t, 20, 5)
+			shell.Config.Set("enable-extended-keys", true)
+
+			if err := inputrc.ParseBytes([]byte(`Shift-Return: newline-insert`), shell.Config); err != nil {
+				t.Fatalf("inputrc.ParseBytes() error = %v", err)
+			}
+
+			got, err := shell.Run(test.keys...)
+			if err != nil {
+				t.Errorf("Shell.Run() error = %v", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Shell.Run() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package readlinetest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/reeflective/readline"
+)
+
+func TestShell_Mouse(t *testing.T) {
+	const click = "\x1b[<0;%d;%dM"
+
+	tests := []struct {
+		name string
+		text string
+		keys []string
+		want string
+	}{
+		{
+			name: "Click on the line",
+			text: "hello world",
+			keys: []string{fmt.Sprintf(click, 5, 1), "X"},
+			want: "heXllo world",
+		},
+		{
+			name: "Click after the end of a line",
+			text: "foo\nbar baz\nqux",
+			keys: []string{fmt.Sprintf(click, 18, 2), "X"},
+			want: "foo\nbar bazX\nqux",
+		},
+		{
+			name: "Click in a multiline buffer",
+			text: "foo\nbar baz\nqux",
+			keys: []string{fmt.Sprintf(click, 4, 2), "X"},
+			want: "foo\nbXar baz\nqux",
+		},
+		{
+			name: "Click on the prompt",
+			text: "foo",
+			keys: []string{fmt.Sprintf(click, 1, 1), "X"},
+			want: "Xfoo",
+		},
+		{
+			name: "Click below the line",
+			text: "foo",
+			keys: []string{fmt.Sprintf(click, 3, 1), fmt.Sprintf(click, 2, 3), "X"},
+			want: "Xfoo",
+		},
+		{
+			name: "Click split across reads",
+			text: "hello world",
+			keys: []string{"\x1b[<0;5", ";1M", "X"},
+			want: "heXllo world",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shell := newTestShell(t, 20, 5)
+			shell.Config.Set("enable-mouse", true)
+
+			shell.StartWith(func(ctx context.Context) (string, error) {
+				return shell.ReadlineWithInitialContext(ctx, test.text, len(test.text))
+			})
+
+			for _, key := range test.keys {
+				shell.Type(key)
+			}
+
+			shell.Type("\r")
+
+			if got, _ := shell.Result(); got != test.want {
+				t.Errorf("Shell.Result() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestShell_MouseCompletion(t *testing.T) {
+	shell := newTestShell(t, 30, 6)
+	shell.Config.Set("enable-mouse", true)
+	shell.Completer = func(line []rune, cursor int) readline.Completions {
+		return readline.CompleteValues("status", "stash", "show")
+	}
+
+	shell.Start()
+	shell.Type("git s")
+	shell.Type("\x1b?")
+
+	// Click on the second candidate.
+	shell.Type("\x1b[<0;10;2M")
+
+	if got := shell.Screen()[0]; got != "> git stash" {
+		t.Errorf("Shell.Screen()[0] = %q, want %q", got, "> git stash")
+	}
+
+	// Scroll to the next one.
+	shell.Type("\x1b[<65;10;2M")
+
+	if got := shell.Screen()[0]; got != "> git status" {
+		t.Errorf("Shell.Screen()[0] = %q, want %q", got, "> git status")
+	}
+}
+
+func TestShell_MouseWheel(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+	shell.Config.Set("enable-mouse", true)
+
+	if _, err := shell.Run("first\r"); err != nil {
+		t.Fatalf("Shell.Run() error = %v", err)
+	}
+
+	got, err := shell.Run("\x1b[<64;1;1M", "\x1b[<65;1;1M", "\x1b[<64;1;1M", " second\r")
+	if err != nil {
+		t.Errorf("Shell.Run() error = %v", err)
+	}
+
+	if want := "first second"; got != want {
+		t.Errorf("Shell.Run() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package readlinetest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/reeflective/readline"
+)
+
+func TestShell_ReadPassword(t *testing.T) {
+	tests := []struct {
+		name       string
+		mask       rune
+		keys       []string
+		want       string
+		wantScreen []string
+		wantX      int
+	}{
+		{
+			name:       "Masked",
+			mask:       '*',
+			keys:       []string{"hunter2", "\r"},
+			want:       "hunter2",
+			wantScreen: []string{"Password: *******", ""},
+		},
+		{
+			name:       "Hidden",
+			keys:       []string{"hunter2", "\r"},
+			want:       "hunter2",
+			wantScreen: []string{"Password:", ""},
+		},
+		{
+			name:       "Editing",
+			mask:       '*',
+			keys:       []string{"unter3", "\x7f2", "\x01h", "\r"},
+			want:       "hunter2",
+			wantScreen: []string{"Password: *******", ""},
+		},
+		{
+			name:       "No completion",
+			mask:       '*',
+			keys:       []string{"hun", "\t", "\x1b?", "ter2", "\r"},
+			want:       "hunter2",
+			wantScreen: []string{"Password: *******", ""},
+		},
+		{
+			name:       "No kill ring",
+			mask:       '*',
+			keys:       []string{"hunter2 two", "\x17", "\x19", "\r"},
+			want:       "hunter2 ",
+			wantScreen: []string{"Password: ********", ""},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shell := newTestShell(t, 30, 5)
+			shell.PasswordMask = test.mask
+			shell.Completer = func(line []rune, cursor int) readline.Completions {
+				t.Errorf("Shell.Completer() called with %q", string(line))
+				return readline.CompleteValues("hunter2")
+			}
+			shell.SyntaxHighlighter = func(line []rune) string {
+				t.Errorf("Shell.SyntaxHighlighter() called with %q", string(line))
+				return string(line)
+			}
+
+			shell.StartPassword("Password: ")
+
+			for _, key := range test.keys {
+				shell.Type(key)
+			}
+
+			got, err := shell.Result()
+			if err != nil {
+				t.Errorf("Shell.ReadPassword() error = %v", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Shell.ReadPassword() = %q, want %q", got, test.want)
+			}
+
+			if screen := shell.Screen()[:len(test.wantScreen)]; !reflect.DeepEqual(screen, test.wantScreen) {
+				t.Errorf("Shell.Screen() = %q, want %q", screen, test.wantScreen)
+			}
+
+			if line := shell.Line(); len(*line) != 0 {
+				t.Errorf("Shell.Line() = %q, want an empty line", string(*line))
+			}
+
+			// Neither the history nor the kill ring may return the secret.
+			shell.Completer = nil
+			shell.SyntaxHighlighter = nil
+			shell.Start()
+			shell.Type("\x1b[A\x19")
+
+			if line := shell.Line(); len(*line) != 0 {
+				t.Errorf("Shell.Line() = %q after history and yank, want an empty line", string(*line))
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package readlinetest
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/reeflective/readline"
+)
+
+func TestShell_PlainInput(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     []string
+		wantLast string // Returned along with io.EOF.
+	}{
+		{
+			name:  "Continued lines",
+			input: "a\nb\\\nc\nlast",
+			want:  []string{"a", "b\\\nc", "last"},
+		},
+		{
+			name:     "Unterminated continued line",
+			input:    "a\nb\\",
+			want:     []string{"a"},
+			wantLast: "b\\",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("INPUTRC", os.DevNull)
+
+			input, writer, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("os.Pipe() error = %v", err)
+			}
+
+			defer input.Close()
+
+			writer.WriteString(test.input)
+			writer.Close()
+
+			var output strings.Builder
+
+			shell := readline.NewShellWithTerminal(input, &output, nil)
+			shell.Prompt.Primary(func() string { return "> " })
+			shell.AcceptMultiline = func(line []rune) bool {
+				return !strings.HasSuffix(string(line), "\\")
+			}
+
+			var lines []string
+
+			for {
+				line, err := shell.Readline()
+				if err != nil {
+					if line != test.wantLast || !errors.Is(err, io.EOF) {
+						t.Errorf("Shell.Readline() = %q, %v, want %q, %v", line, err, test.wantLast, io.EOF)
+					}
+
+					break
+				}
+
+				lines = append(lines, line)
+			}
+
+			if !reflect.DeepEqual(lines, test.want) {
+				t.Errorf("Shell.Readline() lines = %q, want %q", lines, test.want)
+			}
+
+			// Lines accepted are written to the history.
+			hist := shell.History.Current()
+
+			var history []string
+
+			for pos := 0; pos < hist.Len(); pos++ {
+				line, _ := hist.GetLine(pos)
+				history = append(history, line)
+			}
+
+			if !reflect.DeepEqual(history, test.want) {
+				t.Errorf("history lines = %q, want %q", history, test.want)
+			}
+
+			// Neither prompts nor escape sequences are printed.
+			if output.Len() > 0 {
+				t.Errorf("output = %q, want none", output.String())
+			}
+		})
+	}
+}
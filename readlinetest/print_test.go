@@ -0,0 +1,173 @@
+package readlinetest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/reeflective/readline"
+	"github.com/reeflective/readline/inputrc"
+)
+
+func TestShell_Printf(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+	shell.Start()
+	shell.Type("ls")
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		shell.PrintTransientf("job 1 done")
+	}()
+	<-done
+
+	want := []string{"job 1 done", "> ls", ""}
+	if got := shell.Screen()[:3]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Shell.Screen() = %q, want %q", got, want)
+	}
+
+	if x, y := shell.Cursor(); x != 4 || y != 1 {
+		t.Errorf("Shell.Cursor() = (%d, %d), want (4, 1)", x, y)
+	}
+}
+
+func TestShell_LogWriter(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+	shell.Start()
+	shell.Type("ls")
+
+	log := shell.LogWriter()
+
+	write := func(text string) {
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			fmt.Fprint(log, text)
+		}()
+		<-done
+	}
+
+	// Partial writes are held back until a line is complete.
+	write("job 1")
+
+	want := []string{"> ls", "", ""}
+	if got := shell.Screen()[:3]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Shell.Screen() = %q, want %q", got, want)
+	}
+
+	write(" done\njob 2")
+
+	want = []string{"job 1 done", "> ls", ""}
+	if got := shell.Screen()[:3]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Shell.Screen() = %q, want %q", got, want)
+	}
+
+	write(" done\n")
+
+	want = []string{"job 1 done", "job 2 done", "> ls"}
+	if got := shell.Screen()[:3]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Shell.Screen() = %q, want %q", got, want)
+	}
+
+	if x, y := shell.Cursor(); x != 4 || y != 2 {
+		t.Errorf("Shell.Cursor() = (%d, %d), want (4, 2)", x, y)
+	}
+}
+
+func TestShell_PrintfRow(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+	shell.Config.Set("enable-mouse", true)
+	shell.Start()
+	shell.Type("echo one two")
+
+	// Messages are printed while the shell waits for keys, without querying
+	// the cursor position: the shell would have to read the answer itself.
+	queries := shell.Terminal.CursorQueries()
+
+	for _, msg := range []string{"a message wrapping on two rows", "job done\nwith two lines"} {
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			shell.Printf(msg)
+		}()
+		<-done
+	}
+
+	if got := shell.Terminal.CursorQueries(); got != queries {
+		t.Errorf("cursor queries = %d while printing, want none", got-queries)
+	}
+
+	// The second message scrolls the screen, leaving the first prompt on top.
+	want := []string{"> echo one two", "job done", "with two lines", "> echo one two", ""}
+	if got := shell.Screen(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Shell.Screen() = %q, want %q", got, want)
+	}
+
+	// The row of the line is still known, to click on it.
+	shell.Type("\x1b[<0;6;4M")
+	shell.Type("X")
+	shell.Type("\r")
+
+	line, err := shell.Result()
+	if err != nil || line != "echXo one two" {
+		t.Errorf("Shell.Result() = %q, %v, want %q", line, err, "echXo one two")
+	}
+}
+
+func TestShell_PrintfWritten(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+
+	var queued int
+	var queuedErr error
+
+	shell.Keymap.Register(map[string]func(){
+		"log": func() { queued, queuedErr = shell.Printf("queued") },
+	})
+	shell.Config.Bind("emacs", inputrc.Unescape(`\C-t`), "log", false)
+
+	// Messages printed while running a command are queued.
+	shell.Start()
+	shell.Type("ls\x14")
+
+	if queued != 0 || queuedErr != nil {
+		t.Errorf("Shell.Printf() = %d, %v while queued, want 0, <nil>", queued, queuedErr)
+	}
+
+	shell.Type("\r")
+	shell.Result()
+
+	// Once the line is returned, the prompt and the last line
+	// are printed below messages, scrolling the screen up.
+	n, err := shell.Printf("job done")
+	if n != len("job done\n") || err != nil {
+		t.Errorf("Shell.Printf() = %d, %v, want %d, <nil>", n, err, len("job done\n"))
+	}
+
+	want := []string{"> ls", "", "job done", "> ls", ""}
+	if got := shell.Screen(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Shell.Screen() = %q, want %q", got, want)
+	}
+}
+
+// failingWriter is a terminal output to which nothing can be written.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, io.ErrClosedPipe }
+
+func TestShell_PrintfError(t *testing.T) {
+	shell := readline.NewShellWithTerminal(strings.NewReader(""), failingWriter{}, nil)
+
+	if n, err := shell.Printf("job done"); n != 0 || !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("Shell.Printf() = %d, %v, want 0, %v", n, err, io.ErrClosedPipe)
+	}
+
+	if _, err := fmt.Fprintln(shell.LogWriter(), "job done"); !errors.Is(err, io.ErrClosedPipe) {
+		t.Errorf("LogWriter().Write() error = %v, want %v", err, io.ErrClosedPipe)
+	}
+}
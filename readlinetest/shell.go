@@ -0,0 +1,226 @@
+// Package readlinetest provides tools to test readline shells end to end, without a real terminal.
+//
+// A Shell runs a readline shell against an in-memory VT100 terminal emulator: tests can type
+// keys, wait for the shell to process them, and then assert on the rendered screen, the cursor
+// position, the active keymaps and the line returned by the shell. This can be used to test the
+// shell itself, as well as the widgets, completers and other callbacks bound by applications.
+//
+// Example usage:
+//
+//	func TestCompletion(t *testing.T) {
+//		shell := readlinetest.NewShell(t, 80, 24)
+//		shell.Completer = myCompleter
+//
+//		shell.Start()
+//		shell.Type("git sta\t")
+//
+//		if got := shell.Screen()[0]; got != "git status" {
+//			t.Errorf("line = %q, want %q", got, "git status")
+//		}
+//
+//		line, err := shell.Result()
+//		...
+//	}
+//
+// Unlike other shells, the test shell does not load any inputrc file, so that tests do not
+// depend on the configuration of the user running them: options and binds can be set with
+// the Config field of the shell, and the inputrc options given to NewShell are applied.
+package readlinetest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/reeflective/readline"
+	"github.com/reeflective/readline/inputrc"
+	"github.com/reeflective/readline/internal/core"
+)
+
+// Timeout is the maximum duration to wait for the shell to process
+// input keys, or to return a line, after which the test fails.
+var Timeout = 5 * time.Second
+
+// Shell is a readline shell bound to an in-memory terminal, and driven by a test.
+// The readline shell is embedded, so that it can be configured as usual before
+// starting to read a line, and its state inspected while it waits for keys.
+type Shell struct {
+	*readline.Shell
+	Terminal *Terminal
+
+	tb     testing.TB
+	cancel context.CancelFunc
+	done   chan struct{}
+	line   string
+	err    error
+}
+
+// NewShell returns a new readline shell writing to and reading from a terminal emulator
+// of the given dimensions. The shell is stopped and the terminal closed when the test ends.
+func NewShell(tb testing.TB, width, height int, opts ...inputrc.Option) *Shell {
+	tb.Helper()
+
+	terminal := NewTerminal(width, height)
+
+	shell := &Shell{
+		Shell:    readline.NewShellWithTerminal(terminal, terminal, terminal.Size, opts...),
+		Terminal: terminal,
+		tb:       tb,
+	}
+
+	// The emulator understands xterm sequences,
+	// regardless of the terminal running the tests.
+	shell.SetTerminalType("")
+
+	// The configuration loaded by the shell might come from inputrc files:
+	// it is reset to the default one, and no file is read when reloaded.
+	*shell.Config = *inputrc.NewDefaultConfig(inputrc.WithConfigReadFileFunc(nil))
+
+	if err := shell.Keymap.ReloadConfig(opts...); err != nil {
+		tb.Fatalf("readlinetest: failed to load the shell configuration: %v", err)
+	}
+
+	tb.Cleanup(shell.stop)
+
+	return shell
+}
+
+// Start starts reading a line in the background, and waits
+// for the shell to display its prompt and to wait for keys.
+func (s *Shell) Start() {
+	s.tb.Helper()
+	s.StartWith(s.Shell.ReadlineContext)
+}
+
+// StartPassword is like Start, but reads a secret with the given prompt, like
+// the ReadPassword method of the shell does. The result is returned by Result.
+func (s *Shell) StartPassword(prompt string) {
+	s.tb.Helper()
+	s.StartWith(func(ctx context.Context) (string, error) {
+		return s.Shell.ReadPasswordContext(ctx, prompt)
+	})
+}
+
+// StartWith is like Start, but reads the line with the given function, which must
+// call one of the context-aware shell methods (like ReadlineWithInitialContext),
+// and return its results. The context is canceled when the test ends.
+func (s *Shell) StartWith(read func(ctx context.Context) (string, error)) {
+	s.tb.Helper()
+
+	if s.Running() {
+		s.tb.Fatal("readlinetest: the shell is already reading a line")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	s.cancel = cancel
+	s.done = done
+
+	go func() {
+		defer close(done)
+		s.line, s.err = read(ctx)
+	}()
+
+	s.wait()
+}
+
+// Type sends keys to the shell as if they were typed by the user, and waits until
+// the shell has processed all of them, either waiting for more keys or returning.
+// Keys can be any string, including control characters and escape sequences.
+func (s *Shell) Type(keys string) {
+	s.tb.Helper()
+
+	s.Terminal.Input(keys)
+	s.wait()
+}
+
+// Run starts reading a line, types all keys and returns the line read by the shell.
+// The test fails if the shell has not returned after all keys have been typed.
+func (s *Shell) Run(keys ...string) (line string, err error) {
+	s.tb.Helper()
+
+	s.Start()
+
+	for _, key := range keys {
+		s.Type(key)
+	}
+
+	return s.Result()
+}
+
+// Result waits for the shell to return from reading the current
+// line, and returns this line along with the error returned, if any.
+func (s *Shell) Result() (line string, err error) {
+	s.tb.Helper()
+
+	select {
+	case <-s.done:
+	case <-time.After(Timeout):
+		s.tb.Fatalf("readlinetest: the shell has not returned a line within %s:\n%s", Timeout, s.Terminal)
+	}
+
+	return s.line, s.err
+}
+
+// Running returns true if the shell is currently reading a line.
+func (s *Shell) Running() bool {
+	if s.done == nil {
+		return false
+	}
+
+	select {
+	case <-s.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Screen returns the rows of the terminal screen, with trailing spaces removed.
+func (s *Shell) Screen() []string {
+	return s.Terminal.Screen()
+}
+
+// Cursor returns the position of the cursor on the terminal screen (0-based).
+func (s *Shell) Cursor() (x, y int) {
+	return s.Terminal.Cursor()
+}
+
+// wait waits until the shell has processed all input keys. The shell might be
+// reading the terminal without being done with keys, like when it waits for the
+// end of an ambiguous key sequence: in this case, we wait until it is done. The
+// shell might also be stuck reading keys elsewhere (like a cursor position answer
+// never sent): the test fails once the deadline has passed.
+func (s *Shell) wait() {
+	s.tb.Helper()
+
+	deadline := time.Now().Add(Timeout)
+
+	for {
+		if !s.Terminal.waitReading(s.done, time.Until(deadline)) || time.Now().After(deadline) {
+			s.tb.Fatalf("readlinetest: the shell has not processed keys within %s:\n%s", Timeout, s.Terminal)
+		}
+
+		if !s.Running() || core.WaitingInput(s.Keys) {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// stop cancels the current line, if any, and closes the terminal.
+func (s *Shell) stop() {
+	if s.Running() {
+		s.cancel()
+
+		select {
+		case <-s.done:
+		case <-time.After(Timeout):
+			s.tb.Errorf("readlinetest: the shell has not stopped within %s", Timeout)
+		}
+	}
+
+	s.Terminal.Close()
+}
@@ -0,0 +1,327 @@
+package readlinetest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/reeflective/readline"
+)
+
+func newTestShell(t *testing.T, width, height int) *Shell {
+	t.Helper()
+
+	shell := NewShell(t, width, height)
+	shell.Prompt.Primary(func() string { return "> " })
+
+	return shell
+}
+
+func TestNewShell_Config(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "inputrc")
+
+	// The inputrc file of the user is not read.
+	if err := os.WriteFile(file, []byte("set editing-mode vi\n\"a\": end-of-line\n"), 0o600); err != nil {
+		t.Fatalf("os.WriteFile() error = %v", err)
+	}
+
+	t.Setenv("INPUTRC", file)
+
+	shell := newTestShell(t, 20, 5)
+
+	if got := string(shell.Keymap.Main()); got != "emacs" {
+		t.Errorf("Shell.Keymap.Main() = %q, want %q", got, "emacs")
+	}
+
+	if got, err := shell.Run("abc\r"); got != "abc" || err != nil {
+		t.Errorf("Shell.Run() = %q, %v, want %q", got, err, "abc")
+	}
+}
+
+func TestShell_Run(t *testing.T) {
+	tests := []struct {
+		name       string
+		keys       []string
+		want       string
+		wantErr    error
+		wantScreen []string
+	}{
+		{
+			name:       "Accept line",
+			keys:       []string{"git status", "\r"},
+			want:       "git status",
+			wantScreen: []string{"> git status", ""},
+		},
+		{
+			name:       "Emacs editing",
+			keys:       []string{"world", "\x01", "hello ", "\x05!", "\r"},
+			want:       "hello world!",
+			wantScreen: []string{"> hello world!", ""},
+		},
+		{
+			name:       "Kill and yank",
+			keys:       []string{"one two", "\x17", "\x01", "\x19 ", "\r"},
+			want:       "two one ",
+			wantScreen: []string{"> two one", ""},
+		},
+		{
+			name:       "Wrapped line",
+			keys:       []string{"0123456789abcdefghijk", "\r"},
+			want:       "0123456789abcdefghijk",
+			wantScreen: []string{"> 0123456789abcdefgh", "ijk"},
+		},
+		{
+			name:       "Interrupt",
+			keys:       []string{"rm -rf", "\x03"},
+			want:       "rm -rf",
+			wantErr:    readline.ErrInterrupt,
+			wantScreen: []string{"> rm -rf^C", ""},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shell := newTestShell(t, 20, 5)
+
+			got, err := shell.Run(test.keys...)
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("Shell.Run() error = %v, want %v", err, test.wantErr)
+			}
+
+			if got != test.want {
+				t.Errorf("Shell.Run() = %q, want %q", got, test.want)
+			}
+
+			if screen := shell.Screen()[:len(test.wantScreen)]; !reflect.DeepEqual(screen, test.wantScreen) {
+				t.Errorf("Shell.Screen() = %q, want %q", screen, test.wantScreen)
+			}
+		})
+	}
+}
+
+func TestShell_ReadlineWithInitial(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		cursor int
+		keys   []string
+		want   string
+	}{
+		{
+			name:   "Accept initial line",
+			text:   "old-name",
+			cursor: 8,
+			keys:   []string{"\r"},
+			want:   "old-name",
+		},
+		{
+			name:   "Insert at cursor",
+			text:   "git comit",
+			cursor: 7,
+			keys:   []string{"m", "\r"},
+			want:   "git commit",
+		},
+		{
+			name:   "Cursor beyond text",
+			text:   "ab",
+			cursor: 10,
+			keys:   []string{"c", "\r"},
+			want:   "abc",
+		},
+		{
+			name:   "Revert line",
+			text:   "old",
+			cursor: 3,
+			keys:   []string{"\x7f\x7f", "new", "\x1br", "\r"},
+			want:   "old",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shell := newTestShell(t, 20, 5)
+			shell.StartWith(func(ctx context.Context) (string, error) {
+				return shell.ReadlineWithInitialContext(ctx, test.text, test.cursor)
+			})
+
+			for _, key := range test.keys {
+				shell.Type(key)
+			}
+
+			got, err := shell.Result()
+			if err != nil {
+				t.Errorf("Shell.ReadlineWithInitial() error = %v", err)
+			}
+
+			if got != test.want {
+				t.Errorf("Shell.ReadlineWithInitial() = %q, want %q", got, test.want)
+			}
+
+			if want := "> " + test.want; shell.Screen()[0] != want {
+				t.Errorf("Shell.Screen()[0] = %q, want %q", shell.Screen()[0], want)
+			}
+		})
+	}
+}
+
+func TestShell_ReadlineContext(t *testing.T) {
+	tests := []struct {
+		name          string
+		clearOnCancel bool
+		idleTimeout   time.Duration
+		wantErr       error
+		wantScreen    []string
+		wantY         int
+	}{
+		{
+			name:       "Canceled mid-line",
+			wantErr:    context.Canceled,
+			wantScreen: []string{"> echo hi", "", ""},
+			wantY:      1,
+		},
+		{
+			name:          "Canceled and cleared",
+			clearOnCancel: true,
+			wantErr:       context.Canceled,
+			wantScreen:    []string{"", "", ""},
+		},
+		{
+			name:        "Idle timeout",
+			idleTimeout: 50 * time.Millisecond,
+			wantErr:     readline.ErrIdleTimeout,
+			wantScreen:  []string{"> echo hi", "", ""},
+			wantY:       1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shell := newTestShell(t, 20, 3)
+			shell.ClearOnCancel = test.clearOnCancel
+			shell.IdleTimeout = test.idleTimeout
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			shell.StartWith(func(context.Context) (string, error) {
+				return shell.ReadlineContext(ctx)
+			})
+			shell.Type("echo hi")
+
+			if test.idleTimeout == 0 {
+				cancel()
+			}
+
+			// The line being edited is returned along with the error.
+			got, err := shell.Result()
+			if got != "echo hi" || !errors.Is(err, test.wantErr) {
+				t.Errorf("Shell.ReadlineContext() = %q, %v, want %q, %v", got, err, "echo hi", test.wantErr)
+			}
+
+			if got := shell.Screen(); !reflect.DeepEqual(got, test.wantScreen) {
+				t.Errorf("Shell.Screen() = %q, want %q", got, test.wantScreen)
+			}
+
+			if x, y := shell.Cursor(); x != 0 || y != test.wantY {
+				t.Errorf("Shell.Cursor() = (%d, %d), want (0, %d)", x, y, test.wantY)
+			}
+		})
+	}
+}
+
+func TestShell_Cursor(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+	shell.Start()
+
+	shell.Type("hello world")
+
+	if x, y := shell.Cursor(); x != 13 || y != 0 {
+		t.Errorf("Shell.Cursor() = (%d, %d), want (13, 0)", x, y)
+	}
+
+	// Backward word
+	shell.Type("\x1bb")
+
+	if x, y := shell.Cursor(); x != 8 || y != 0 {
+		t.Errorf("Shell.Cursor() = (%d, %d), want (8, 0)", x, y)
+	}
+}
+
+func TestShell_CursorQueries(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+	shell.Start()
+
+	// The row of the line is known from the last frame
+	// displayed, as long as it is still on the screen.
+	queries := shell.Terminal.CursorQueries()
+
+	shell.Type("hello")
+	shell.Type(" world\x1bb")
+	shell.Type("\t")
+
+	if got := shell.Terminal.CursorQueries(); got != queries {
+		t.Errorf("cursor queries = %d while editing, want none", got-queries)
+	}
+
+	// The screen is cleared, so the cursor position is queried again.
+	shell.Type("\x0c")
+
+	if got := shell.Terminal.CursorQueries(); got == queries {
+		t.Errorf("cursor queries = 0 after clearing the screen, want some")
+	}
+
+	if x, y := shell.Cursor(); x != 8 || y != 0 {
+		t.Errorf("Shell.Cursor() = (%d, %d), want (8, 0)", x, y)
+	}
+}
+
+func TestShell_Keymap(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+	shell.Keymap.SetMain("vi-insert")
+	shell.Start()
+
+	shell.Type("echo")
+	shell.Type("\x1b")
+
+	if got := string(shell.Keymap.Main()); got != "vi-command" {
+		t.Errorf("Shell.Keymap.Main() = %q, want %q", got, "vi-command")
+	}
+
+	shell.Type("0cwprint\r")
+
+	if got, _ := shell.Result(); got != "print" {
+		t.Errorf("Shell.Result() = %q, want %q", got, "print")
+	}
+}
+
+func TestShell_Completion(t *testing.T) {
+	shell := newTestShell(t, 30, 6)
+	shell.Completer = func(line []rune, cursor int) readline.Completions {
+		return readline.CompleteValues("status", "stash", "show")
+	}
+
+	shell.Start()
+	shell.Type("git s")
+
+	// possible-completions
+	shell.Type("\x1b?")
+
+	if got := string(shell.Keymap.Local()); got != "menu-select" {
+		t.Errorf("Shell.Keymap.Local() = %q, want %q", got, "menu-select")
+	}
+
+	want := []string{"> git s", "show  stash  status", ""}
+	if got := shell.Screen()[:3]; !reflect.DeepEqual(got, want) {
+		t.Errorf("Shell.Screen() = %q, want %q", got, want)
+	}
+
+	shell.Type("\t\t")
+
+	if got := shell.Screen()[0]; got != "> git stash" {
+		t.Errorf("Shell.Screen()[0] = %q, want %q", got, "> git stash")
+	}
+}
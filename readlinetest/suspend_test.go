@@ -0,0 +1,53 @@
+package readlinetest
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/reeflective/readline/inputrc"
+)
+
+func TestShell_Suspend(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+
+	var suspendedPaste bool
+
+	shell.Keymap.Register(map[string]func(){
+		"pick": func() {
+			shell.Suspend()
+			suspendedPaste = shell.Terminal.Mode("2004")
+
+			// A picker drawing below the line, and clearing itself.
+			shell.Terminal.Write([]byte("> bar\r\n  baz\x1b[1A\r\x1b[J"))
+			shell.Resume()
+
+			shell.Line().Insert(shell.Shell.Cursor().Pos(), []rune("bar")...)
+			shell.Shell.Cursor().Move(3)
+		},
+	})
+	shell.Config.Bind("emacs", inputrc.Unescape(`\C-t`), "pick", false)
+	shell.Config.Set("enable-bracketed-paste", true)
+
+	shell.Start()
+	shell.Type("foo ")
+	shell.Type("\x14")
+
+	if suspendedPaste {
+		t.Errorf("Terminal.Mode(%q) = true while suspended, want false", "2004")
+	}
+
+	want := []string{"> foo bar", "", "", "", ""}
+	if got := shell.Screen(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Shell.Screen() = %q, want %q", got, want)
+	}
+
+	if !shell.Terminal.Mode("2004") {
+		t.Errorf("Terminal.Mode(%q) = false once resumed, want true", "2004")
+	}
+
+	shell.Type("\r")
+
+	if got, _ := shell.Result(); got != "foo bar" {
+		t.Errorf("Shell.Result() = %q, want %q", got, "foo bar")
+	}
+}
@@ -0,0 +1,111 @@
+//go:build unix
+
+package readlinetest
+
+import (
+	"os/exec"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/reeflective/readline/inputrc"
+)
+
+func TestShell_Resume(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+	shell.Config.Set("enable-bracketed-paste", true)
+	shell.Start()
+
+	// The terminal is not a file, so the process is not suspended.
+	shell.Type("foo\x1a")
+
+	// Output of the parent shell, when the process is resumed with fg:
+	// it has disabled the terminal modes used by the shell.
+	shell.Terminal.Write([]byte("\x1b[?2004l\r\n$ fg\r\n"))
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGCONT); err != nil {
+		t.Fatalf("syscall.Kill() error = %v", err)
+	}
+
+	want := []string{"> foo", "$ fg", "> foo", "", ""}
+	deadline := time.Now().Add(Timeout)
+
+	for !reflect.DeepEqual(shell.Screen(), want) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if got := shell.Screen(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Shell.Screen() = %q, want %q", got, want)
+	}
+
+	if !shell.Terminal.Mode("2004") {
+		t.Errorf("Terminal.Mode(%q) = false once resumed, want true", "2004")
+	}
+
+	shell.Type("\r")
+
+	if got, _ := shell.Result(); got != "foo" {
+		t.Errorf("Shell.Result() = %q, want %q", got, "foo")
+	}
+}
+
+func TestShell_ResumeRunningCommand(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+
+	shell.Keymap.Register(map[string]func(){
+		"stopped": func() {
+			shell.Terminal.Write([]byte("\x1b[?2004l\r\n$ fg\r\n"))
+
+			if err := syscall.Kill(syscall.Getpid(), syscall.SIGCONT); err != nil {
+				t.Errorf("syscall.Kill() error = %v", err)
+			}
+
+			// The signal is handled while the command is still running.
+			time.Sleep(10 * time.Millisecond)
+		},
+	})
+	shell.Config.Bind("emacs", inputrc.Unescape(`\C-t`), "stopped", false)
+	shell.Config.Set("enable-bracketed-paste", true)
+
+	shell.Start()
+	shell.Type("foo\x14")
+
+	want := []string{"> foo", "$ fg", "> foo", "", ""}
+	if got := shell.Screen(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Shell.Screen() = %q, want %q", got, want)
+	}
+
+	if !shell.Terminal.Mode("2004") {
+		t.Errorf("Terminal.Mode(%q) = false once resumed, want true", "2004")
+	}
+}
+
+func TestShell_RunExternal(t *testing.T) {
+	shell := newTestShell(t, 20, 5)
+
+	shell.Keymap.Register(map[string]func(){
+		"run": func() {
+			// Programs put the cursor back where they started.
+			cmd := exec.Command("printf", `\0337\r\n  picker\0338`)
+
+			if err := shell.RunExternal(cmd); err != nil {
+				t.Errorf("Shell.RunExternal() error = %v", err)
+			}
+		},
+	})
+	shell.Config.Bind("emacs", inputrc.Unescape(`\C-t`), "run", false)
+
+	shell.Start()
+	shell.Type("foo")
+	shell.Type("\x14")
+
+	want := []string{"> foo", "", "", "", ""}
+	if got := shell.Screen(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Shell.Screen() = %q, want %q", got, want)
+	}
+
+	if x, y := shell.Cursor(); x != 5 || y != 0 {
+		t.Errorf("Shell.Cursor() = (%d, %d), want (5, 0)", x, y)
+	}
+}
@@ -0,0 +1,641 @@
+package readlinetest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rivo/uniseg"
+)
+
+// Terminal is an in-memory VT100 terminal emulator, to which a shell writes its output
+// and from which it reads its input keys. It maintains a grid of screen cells along with
+// the cursor position, and answers cursor position queries like a real terminal would.
+//
+// Only the sequences used by line editors are interpreted (cursor movements and erasures,
+// modes, cursor reports, clipboard and shell integration ones): all other ones, like colors, are parsed and
+// discarded.
+type Terminal struct {
+	width  int
+	height int
+
+	// Screen
+	cells       [][]string // Cells hold a printable grapheme, or nothing if part of a wide one.
+	x, y        int
+	savedX      int
+	savedY      int
+	wrapPending bool // The last column has been written, but the cursor is not wrapped yet.
+	hidden      bool // The cursor is hidden.
+	modes       map[string]bool
+	parser      []byte // Incomplete escape sequence or UTF-8 character.
+
+	// Clipboard selections, written with OSC 52 sequences, and
+	// whether their contents are sent back when queried.
+	clipboard     map[byte]string
+	clipboardRead bool
+
+	// Shell integration: window title, working directory (OSC 2 and 7),
+	// and semantic marks (OSC 133) in the order they have been written.
+	title     string
+	directory string
+	marks     []string
+
+	// Input
+	keys    []byte
+	reading bool // A Read call is blocked until keys are available.
+	closed  bool
+	queries int // Number of cursor position queries answered.
+
+	mutex sync.Mutex
+	cond  *sync.Cond
+}
+
+// NewTerminal returns a new terminal emulator with the given dimensions, and a blank screen.
+func NewTerminal(width, height int) *Terminal {
+	t := &Terminal{
+		width:     width,
+		height:    height,
+		modes:     make(map[string]bool),
+		clipboard: make(map[byte]string),
+	}
+
+	t.cond = sync.NewCond(&t.mutex)
+	t.cells = make([][]string, height)
+
+	for row := range t.cells {
+		t.cells[row] = t.blankRow()
+	}
+
+	return t
+}
+
+// Size returns the dimensions of the terminal.
+func (t *Terminal) Size() (width, height int) {
+	return t.width, t.height
+}
+
+// Read blocks until some input keys are available, and reads them.
+// It returns io.EOF once the terminal is closed and all keys read.
+func (t *Terminal) Read(p []byte) (n int, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for len(t.keys) == 0 && !t.closed {
+		t.reading = true
+		t.cond.Broadcast()
+		t.cond.Wait()
+	}
+
+	t.reading = false
+
+	if len(t.keys) == 0 {
+		return 0, io.EOF
+	}
+
+	n = copy(p, t.keys)
+	t.keys = t.keys[n:]
+
+	return n, nil
+}
+
+// Input queues keys to be read by the shell, as if they were typed by the user.
+func (t *Terminal) Input(keys string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.keys = append(t.keys, keys...)
+	t.cond.Broadcast()
+}
+
+// Close makes all pending and future reads return io.EOF, once all keys have been read.
+func (t *Terminal) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.closed = true
+	t.cond.Broadcast()
+
+	return nil
+}
+
+// Write interprets the output of the shell, and updates the screen accordingly.
+// Escape sequences and UTF-8 characters can be split across several writes.
+func (t *Terminal) Write(p []byte) (n int, err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.parser = append(t.parser, p...)
+
+	for len(t.parser) > 0 {
+		used := t.parse(t.parser)
+		if used == 0 {
+			break
+		}
+
+		t.parser = t.parser[used:]
+	}
+
+	return len(p), nil
+}
+
+// Screen returns the rows of the screen, with trailing spaces removed.
+func (t *Terminal) Screen() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	rows := make([]string, len(t.cells))
+
+	for i, row := range t.cells {
+		rows[i] = strings.TrimRight(strings.Join(row, ""), " ")
+	}
+
+	return rows
+}
+
+// String returns the screen rows joined with newlines, without trailing empty rows.
+func (t *Terminal) String() string {
+	return strings.TrimRight(strings.Join(t.Screen(), "\n"), "\n")
+}
+
+// Cursor returns the current cursor position (0-based).
+func (t *Terminal) Cursor() (x, y int) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.x, t.y
+}
+
+// CursorVisible returns true if the cursor is currently shown.
+func (t *Terminal) CursorVisible() bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return !t.hidden
+}
+
+// Mode returns true if the given private mode is currently set, like "2004"
+// for bracketed paste: the mode is set with "\x1b[?2004h", reset with "\x1b[?2004l".
+func (t *Terminal) Mode(mode string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.modes[mode]
+}
+
+// Clipboard returns the text last copied by the shell to a clipboard selection,
+// with an OSC 52 sequence: 'c' for the clipboard, and 'p' for the primary one.
+func (t *Terminal) Clipboard(selection byte) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.clipboard[selection]
+}
+
+// SetClipboard sets the text of a clipboard selection, and makes the terminal answer
+// the OSC 52 queries of the shell: by default, like most terminals, it ignores them.
+func (t *Terminal) SetClipboard(selection byte, text string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.clipboard[selection] = text
+	t.clipboardRead = true
+}
+
+// Title returns the window title last set by the shell.
+func (t *Terminal) Title() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.title
+}
+
+// WorkingDirectory returns the working directory last reported by the shell,
+// as a file URL like "file://host/home/user".
+func (t *Terminal) WorkingDirectory() string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.directory
+}
+
+// CursorQueries returns the number of cursor position queries answered so far.
+func (t *Terminal) CursorQueries() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.queries
+}
+
+// Marks returns the OSC 133 marks written by the shell, in order and without
+// their prefix, like "A" for the start of a prompt or "D;0" for a command end.
+func (t *Terminal) Marks() []string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return append([]string(nil), t.marks...)
+}
+
+// waitReading blocks until a Read call is blocked because no input keys are left,
+// or until the timeout expires, in which case false is returned. The done channel
+// is also watched, so as to return when the shell does not read input anymore.
+func (t *Terminal) waitReading(done <-chan struct{}, timeout time.Duration) bool {
+	expired := false
+	timer := time.AfterFunc(timeout, func() {
+		t.mutex.Lock()
+		expired = true
+		t.cond.Broadcast()
+		t.mutex.Unlock()
+	})
+
+	defer timer.Stop()
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		select {
+		case <-done:
+			t.mutex.Lock()
+			t.cond.Broadcast()
+			t.mutex.Unlock()
+		case <-stop:
+		}
+	}()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for !(t.reading && len(t.keys) == 0) {
+		select {
+		case <-done:
+			return true
+		default:
+		}
+
+		if expired {
+			return false
+		}
+
+		t.cond.Wait()
+	}
+
+	return true
+}
+
+// parse interprets the first character or sequence of buf, and returns
+// the number of bytes used, or 0 if the sequence is not complete yet.
+func (t *Terminal) parse(buf []byte) int {
+	switch buf[0] {
+	case '\x1b':
+		return t.parseEscape(buf)
+	case '\r':
+		t.x = 0
+		t.wrapPending = false
+	case '\n', '\v', '\f':
+		t.lineFeed()
+	case '\b':
+		t.moveTo(t.x-1, t.y)
+	case '\t':
+		t.moveTo((t.x/8+1)*8, t.y)
+	case '\a':
+	default:
+		if buf[0] < ' ' || buf[0] == 0x7f {
+			return 1
+		}
+
+		return t.parseGrapheme(buf)
+	}
+
+	return 1
+}
+
+// parseGrapheme prints the first grapheme cluster of buf, if complete.
+func (t *Terminal) parseGrapheme(buf []byte) int {
+	// Stop the cluster before any control character or sequence.
+	end := len(buf)
+
+	for i, char := range buf {
+		if char < ' ' || char == 0x7f {
+			end = i
+			break
+		}
+	}
+
+	cluster, rest, width, _ := uniseg.FirstGraphemeCluster(buf[:end], -1)
+
+	// The cluster might continue in a subsequent write.
+	if len(rest) == 0 && end == len(buf) && !isCompleteUTF8(cluster) {
+		return 0
+	}
+
+	t.print(string(cluster), width)
+
+	return len(cluster)
+}
+
+// parseEscape interprets an escape sequence.
+func (t *Terminal) parseEscape(buf []byte) int {
+	if len(buf) < 2 {
+		return 0
+	}
+
+	switch buf[1] {
+	case '[':
+		return t.parseCSI(buf)
+	case ']':
+		end := parseString(buf)
+		if end > 0 {
+			t.parseOSC(string(buf[2:end]))
+		}
+
+		return end
+	case 'P', '_', '^':
+		return parseString(buf)
+	case '7':
+		t.savedX, t.savedY = t.x, t.y
+	case '8':
+		t.moveTo(t.savedX, t.savedY)
+	case 'M':
+		t.moveTo(t.x, t.y-1)
+	case 'D':
+		t.lineFeed()
+	case 'E':
+		t.x = 0
+		t.lineFeed()
+	case '(', ')', '#', ' ':
+		if len(buf) < 3 {
+			return 0
+		}
+
+		return 3
+	}
+
+	return 2
+}
+
+// parseCSI interprets a Control Sequence Introducer sequence.
+func (t *Terminal) parseCSI(buf []byte) int {
+	end := 2
+	for end < len(buf) && (buf[end] < 0x40 || buf[end] > 0x7e) {
+		end++
+	}
+
+	if end == len(buf) {
+		return 0
+	}
+
+	final := buf[end]
+	body := string(buf[2:end])
+
+	// Private modes and intermediate bytes.
+	private := strings.HasPrefix(body, "?")
+	body = strings.TrimPrefix(body, "?")
+	intermediate := strings.TrimLeft(body, "0123456789;:")
+	body = strings.TrimSuffix(body, intermediate)
+
+	params := parseParams(body)
+	param := func(i, def int) int {
+		if len(params) <= i || params[i] == 0 {
+			return def
+		}
+
+		return params[i]
+	}
+
+	if intermediate != "" {
+		return end + 1
+	}
+
+	switch {
+	case private && (final == 'h' || final == 'l'):
+		for _, mode := range strings.Split(body, ";") {
+			t.setMode(mode, final == 'h')
+		}
+	case private:
+	case final == 'A':
+		t.moveTo(t.x, t.y-param(0, 1))
+	case final == 'B':
+		t.moveTo(t.x, t.y+param(0, 1))
+	case final == 'C':
+		t.moveTo(t.x+param(0, 1), t.y)
+	case final == 'D':
+		t.moveTo(t.x-param(0, 1), t.y)
+	case final == 'E':
+		t.moveTo(0, t.y+param(0, 1))
+	case final == 'F':
+		t.moveTo(0, t.y-param(0, 1))
+	case final == 'G':
+		t.moveTo(param(0, 1)-1, t.y)
+	case final == 'd':
+		t.moveTo(t.x, param(0, 1)-1)
+	case final == 'H', final == 'f':
+		t.moveTo(param(1, 1)-1, param(0, 1)-1)
+	case final == 'J':
+		t.eraseDisplay(param(0, 0))
+	case final == 'K':
+		t.eraseLine(param(0, 0))
+	case final == 's':
+		t.savedX, t.savedY = t.x, t.y
+	case final == 'u':
+		t.moveTo(t.savedX, t.savedY)
+	case final == 'n' && param(0, 0) == 6:
+		t.keys = append(t.keys, fmt.Sprintf("\x1b[%d;%dR", t.y+1, t.x+1)...)
+		t.queries++
+		t.cond.Broadcast()
+	}
+
+	return end + 1
+}
+
+// parseOSC interprets an Operating System Command, with its terminator.
+func (t *Terminal) parseOSC(body string) {
+	body = strings.TrimSuffix(strings.TrimSuffix(body, "\a"), "\x1b\\")
+
+	command, args, _ := strings.Cut(body, ";")
+
+	switch command {
+	case "0", "2":
+		t.title = args
+	case "7":
+		t.directory = args
+	case "133":
+		t.marks = append(t.marks, args)
+	case "52":
+		t.parseClipboard(args)
+	}
+}
+
+// parseClipboard copies text to clipboard selections, or answers queries.
+func (t *Terminal) parseClipboard(args string) {
+	selections, data, _ := strings.Cut(args, ";")
+	if selections == "" {
+		selections = "c"
+	}
+
+	for _, selection := range []byte(selections) {
+		switch {
+		case data == "?" && t.clipboardRead:
+			encoded := base64.StdEncoding.EncodeToString([]byte(t.clipboard[selection]))
+			t.keys = append(t.keys, fmt.Sprintf("\x1b]52;%c;%s\a", selection, encoded)...)
+			t.cond.Broadcast()
+		case data != "?":
+			text, _ := base64.StdEncoding.DecodeString(data)
+			t.clipboard[selection] = string(text)
+		}
+	}
+}
+
+// parseString skips an OSC, DCS, APC or PM sequence, terminated by BEL or ST.
+func parseString(buf []byte) int {
+	for i := 2; i < len(buf); i++ {
+		switch {
+		case buf[i] == '\a':
+			return i + 1
+		case buf[i] == '\x1b' && i+1 < len(buf) && buf[i+1] == '\\':
+			return i + 2
+		}
+	}
+
+	return 0
+}
+
+func parseParams(body string) (params []int) {
+	if body == "" {
+		return nil
+	}
+
+	for _, param := range strings.Split(body, ";") {
+		value, _ := strconv.Atoi(strings.Split(param, ":")[0])
+		params = append(params, value)
+	}
+
+	return params
+}
+
+func (t *Terminal) setMode(mode string, set bool) {
+	if mode == "25" {
+		t.hidden = !set
+	}
+
+	t.modes[mode] = set
+}
+
+// print writes a grapheme cluster at the cursor position.
+func (t *Terminal) print(cluster string, width int) {
+	// Zero-width characters combine with the previous cell.
+	if width == 0 {
+		previous := t.x - 1
+		if t.wrapPending {
+			previous = t.x
+		}
+
+		if previous >= 0 {
+			t.cells[t.y][previous] += cluster
+		}
+
+		return
+	}
+
+	if t.wrapPending || t.x+width > t.width {
+		t.x = 0
+		t.lineFeed()
+	}
+
+	t.cells[t.y][t.x] = cluster
+	for i := 1; i < width && t.x+i < t.width; i++ {
+		t.cells[t.y][t.x+i] = ""
+	}
+
+	t.x += width
+
+	// Like most terminals, defer wrapping until the next character.
+	if t.x >= t.width {
+		t.x = t.width - 1
+		t.wrapPending = true
+	}
+}
+
+func (t *Terminal) lineFeed() {
+	t.wrapPending = false
+
+	if t.y < t.height-1 {
+		t.y++
+		return
+	}
+
+	// Scroll the screen up.
+	t.cells = append(t.cells[1:], t.blankRow())
+}
+
+func (t *Terminal) moveTo(x, y int) {
+	t.x = clamp(x, 0, t.width-1)
+	t.y = clamp(y, 0, t.height-1)
+	t.wrapPending = false
+}
+
+func (t *Terminal) eraseLine(mode int) {
+	row := t.cells[t.y]
+
+	switch mode {
+	case 0:
+		t.erase(row[t.x:])
+	case 1:
+		t.erase(row[:t.x+1])
+	case 2:
+		t.erase(row)
+	}
+}
+
+func (t *Terminal) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		t.eraseLine(0)
+
+		for _, row := range t.cells[t.y+1:] {
+			t.erase(row)
+		}
+	case 1:
+		t.eraseLine(1)
+
+		for _, row := range t.cells[:t.y] {
+			t.erase(row)
+		}
+	case 2, 3:
+		for _, row := range t.cells {
+			t.erase(row)
+		}
+	}
+}
+
+func (t *Terminal) erase(cells []string) {
+	for i := range cells {
+		cells[i] = " "
+	}
+}
+
+func (t *Terminal) blankRow() []string {
+	row := make([]string, t.width)
+	t.erase(row)
+
+	return row
+}
+
+func isCompleteUTF8(buf []byte) bool {
+	return strings.ToValidUTF8(string(buf), "") == string(buf)
+}
+
+func clamp(value, min, max int) int {
+	if value < min {
+		return min
+	}
+
+	if value > max {
+		return max
+	}
+
+	return value
+}
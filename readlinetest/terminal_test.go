@@ -0,0 +1,193 @@
+package readlinetest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTerminal_Write(t *testing.T) {
+	tests := []struct {
+		name       string
+		writes     []string
+		wantScreen []string
+		wantX      int
+		wantY      int
+	}{
+		{
+			name:       "Plain text",
+			writes:     []string{"hello"},
+			wantScreen: []string{"hello", "", ""},
+			wantX:      5,
+		},
+		{
+			name:       "Newlines and carriage returns",
+			writes:     []string{"one\r\ntwo\r\nthree"},
+			wantScreen: []string{"one", "two", "three"},
+			wantX:      5,
+			wantY:      2,
+		},
+		{
+			name:       "Deferred wrapping at last column",
+			writes:     []string{"0123456789"},
+			wantScreen: []string{"0123456789", "", ""},
+			wantX:      9,
+		},
+		{
+			name:       "Wrapping on next character",
+			writes:     []string{"0123456789ab"},
+			wantScreen: []string{"0123456789", "ab", ""},
+			wantX:      2,
+			wantY:      1,
+		},
+		{
+			name:       "Scrolling",
+			writes:     []string{"a\r\nb\r\nc\r\nd"},
+			wantScreen: []string{"b", "c", "d"},
+			wantX:      1,
+			wantY:      2,
+		},
+		{
+			name:       "Cursor movements are clamped",
+			writes:     []string{"abc\x1b[5A\x1b[20D\x1b[2B\x1b[4C"},
+			wantScreen: []string{"abc", "", ""},
+			wantX:      4,
+			wantY:      2,
+		},
+		{
+			name:       "Clear line after cursor",
+			writes:     []string{"abcdef\x1b[3D\x1b[0K"},
+			wantScreen: []string{"abc", "", ""},
+			wantX:      3,
+		},
+		{
+			name:       "Clear screen below cursor",
+			writes:     []string{"abc\r\ndef\r\nghi\x1b[1A\x1b[2D\x1b[0J"},
+			wantScreen: []string{"abc", "d", ""},
+			wantX:      1,
+			wantY:      1,
+		},
+		{
+			name:       "Absolute positioning",
+			writes:     []string{"\x1b[2;3Hx\x1b[H"},
+			wantScreen: []string{"", "  x", ""},
+		},
+		{
+			name:       "Colors are ignored",
+			writes:     []string{"\x1b[1;31mred\x1b[0m \x1b[38;5;242mgrey\x1b[0m"},
+			wantScreen: []string{"red grey", "", ""},
+			wantX:      8,
+		},
+		{
+			name:       "Sequences split across writes",
+			writes:     []string{"ab\x1b[", "1", "Dc\xc3", "\xa9"},
+			wantScreen: []string{"acé", "", ""},
+			wantX:      3,
+		},
+		{
+			name:       "Wide characters",
+			writes:     []string{"日本"},
+			wantScreen: []string{"日本", "", ""},
+			wantX:      4,
+		},
+		{
+			name:       "Combining characters",
+			writes:     []string{"e\u0301", "\u0302a"},
+			wantScreen: []string{"e\u0301\u0302a", "", ""},
+			wantX:      2,
+		},
+		{
+			name:       "Title and other OSC sequences",
+			writes:     []string{"\x1b]0;title\a\x1b]7;file:///tmp\x1b\\ok"},
+			wantScreen: []string{"ok", "", ""},
+			wantX:      2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			term := NewTerminal(10, 3)
+
+			for _, write := range test.writes {
+				term.Write([]byte(write))
+			}
+
+			if got := term.Screen(); !reflect.DeepEqual(got, test.wantScreen) {
+				t.Errorf("Terminal.Screen() = %q, want %q", got, test.wantScreen)
+			}
+
+			if x, y := term.Cursor(); x != test.wantX || y != test.wantY {
+				t.Errorf("Terminal.Cursor() = (%d, %d), want (%d, %d)", x, y, test.wantX, test.wantY)
+			}
+		})
+	}
+}
+
+func TestTerminal_Read(t *testing.T) {
+	term := NewTerminal(10, 3)
+	term.Write([]byte("ab\x1b[6n"))
+	term.Input("x")
+
+	buf := make([]byte, 32)
+
+	read, err := term.Read(buf)
+	if err != nil {
+		t.Fatalf("Terminal.Read() error = %v", err)
+	}
+
+	if got, want := string(buf[:read]), "\x1b[1;3Rx"; got != want {
+		t.Errorf("Terminal.Read() = %q, want %q", got, want)
+	}
+
+	term.Close()
+
+	if _, err := term.Read(buf); err == nil {
+		t.Errorf("Terminal.Read() on closed terminal should return an error")
+	}
+}
+
+func TestTerminal_Mode(t *testing.T) {
+	term := NewTerminal(10, 3)
+	term.Write([]byte("\x1b[?2004h\x1b[?25l"))
+
+	if !term.Mode("2004") {
+		t.Errorf("Terminal.Mode(2004) = false, want true")
+	}
+
+	if term.CursorVisible() {
+		t.Errorf("Terminal.CursorVisible() = true, want false")
+	}
+
+	term.Write([]byte("\x1b[?2004l\x1b[?25h"))
+
+	if term.Mode("2004") {
+		t.Errorf("Terminal.Mode(2004) = true, want false")
+	}
+
+	if !term.CursorVisible() {
+		t.Errorf("Terminal.CursorVisible() = false, want true")
+	}
+}
+
+func TestTerminal_Clipboard(t *testing.T) {
+	term := NewTerminal(10, 3)
+	term.Write([]byte("\x1b]52;c;Zm9v\a\x1b]52;p;?\a"))
+
+	if got, want := term.Clipboard('c'), "foo"; got != want {
+		t.Errorf("Terminal.Clipboard() = %q, want %q", got, want)
+	}
+
+	term.SetClipboard('p', "bar")
+	term.Write([]byte("\x1b]52;p;?\x1b\\"))
+	term.Input("x")
+
+	buf := make([]byte, 32)
+
+	read, err := term.Read(buf)
+	if err != nil {
+		t.Fatalf("Terminal.Read() error = %v", err)
+	}
+
+	if got, want := string(buf[:read]), "\x1b]52;p;YmFy\ax"; got != want {
+		t.Errorf("Terminal.Read() = %q, want %q", got, want)
+	}
+}
@@ -1,7 +1,13 @@
 package readline
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/reeflective/readline/inputrc"
 	"github.com/reeflective/readline/internal/completion"
@@ -19,12 +25,14 @@ import (
 // and methods to run the line editor, manage the inputrc configuration, keymaps
 // and commands.
 // Although each instance contains everything needed to run a line editor, it is
-// recommended to use a single instance per application, and to share it between
-// all the goroutines that need to read user input.
+// recommended to use a single instance per terminal, and to share it between all
+// the goroutines that need to read user input on this terminal. Several shells
+// can run at once in the same process when bound to different terminals.
 // Please refer to the README and documentation for more details about the shell
 // and its components, and how to use them.
 type Shell struct {
 	// Core editor
+	term       *term.Terminal   // The input/output streams of the shell.
 	line       *core.Line       // The input line buffer and its management methods.
 	cursor     *core.Cursor     // The cursor and its methods.
 	selection  *core.Selection  // The selection manages various visual/pending selections.
@@ -34,6 +42,11 @@ type Shell struct {
 	Keymap     *keymap.Engine   // Manages main/local keymaps, binds, stores command functions, etc.
 	History    *history.Sources // History manages all history types/sources (past commands and undo)
 	Macros     *macro.Engine    // Record, use and display macros.
+	input      *bufio.Reader    // Buffered input, when the shell does not read from a terminal.
+	secret     bool             // The line being read is a secret, like a password.
+	initial    []rune           // An editable line to start reading with, if any.
+	initialPos int              // The cursor position in the initial line.
+	cooked     *term.State      // The terminal state before entering raw mode, if it is a file.
 
 	// User interface
 	Config    *inputrc.Config    // Contains all keymaps, binds and per-application settings.
@@ -61,22 +74,90 @@ type Shell struct {
 	// It takes the readline line ([]rune) and cursor pos as parameters,
 	// and returns completions with their associated metadata/settings.
 	Completer func(line []rune, cursor int) Completions
+
+	// Hooks
+
+	// OnLineChange is called after a command has modified the input line,
+	// or moved the cursor. The line might contain a completion candidate
+	// currently inserted. It is not called when reading a password.
+	OnLineChange func(line []rune, cursor int)
+
+	// OnKeymapChange is called when the keymap in use changes, either the
+	// main one (like when entering Vim command mode) or a local one (like
+	// when starting completion). Keymaps are those returned by Keymap.Main()
+	// and Keymap.Local(), the local one taking precedence when it is active.
+	OnKeymapChange func(old, new string)
+
+	// PreCommand and PostCommand are called before and after a command bound
+	// to some key sequence is executed, with the bind of the executed command.
+	PreCommand  func(bind inputrc.Bind)
+	PostCommand func(bind inputrc.Bind)
+
+	// OnAccept is called when the user has accepted the input line, just before
+	// it is returned to the caller. It is not called when the line is returned
+	// along with an error (like ErrInterrupt), nor when reading a password.
+	OnAccept func(line string)
+
+	// OnUndefinedKey is called when a key sequence is not bound to any command
+	// in the current keymaps. It is called with the keys of the sequence.
+	OnUndefinedKey func(keys []rune)
+
+	// Cancellation
+
+	// IdleTimeout is the maximum duration to wait for a key to be input,
+	// after which ReadlineContext returns ErrIdleTimeout. Zero means no timeout.
+	IdleTimeout time.Duration
+
+	// ClearOnCancel makes ReadlineContext erase the prompt and the input line
+	// when it returns because of a done context or an idle timeout, instead of
+	// redisplaying the line as if it had been accepted.
+	ClearOnCancel bool
+
+	// Secrets
+
+	// PasswordMask is the character displayed in place of each character of a line
+	// read with ReadPassword. If zero (the default), nothing is displayed at all.
+	PasswordMask rune
 }
 
+// Clipboard is a system clipboard, to which the "+ and "* Vim registers are written, and from
+// which they are read (the kill ring is also copied to it with the kill-ring-clipboard option).
+// By default, the clipboard of the terminal is used with OSC 52 sequences, which works over SSH:
+// applications can provide their own (like one using a display server) with Buffers.SetClipboard.
+type Clipboard = editor.Clipboard
+
 // NewShell returns a readline shell instance initialized with a default
 // inputrc configuration and binds, and with an in-memory command history.
 // The constructor accepts an optional list of inputrc configuration options,
 // which are used when parsing/loading and applying any inputrc configuration.
+// The shell reads its input on os.Stdin, and writes its output to os.Stdout.
 func NewShell(opts ...inputrc.Option) *Shell {
+	return NewShellWithTerminal(os.Stdin, os.Stdout, nil, opts...)
+}
+
+// NewShellWithTerminal returns a readline shell instance reading its input keys on in,
+// and writing all of its output (prompts, input line, hints, completions...) to out.
+// Each of those shells is independent from others, so that an application can serve
+// several terminals at once (for instance, one per SSH session).
+//
+// The size function should return the current dimensions of the terminal: it is called
+// each time the shell is redisplayed. If nil, the size is queried on out if the latter
+// is a terminal file, and defaults to 80 columns and 80 rows otherwise.
+//
+// If in is a terminal file, it is put in raw mode when reading a line. Otherwise, the
+// caller is responsible for setting the remote terminal in the appropriate mode.
+func NewShellWithTerminal(in io.Reader, out io.Writer, size func() (width, height int), opts ...inputrc.Option) *Shell {
 	shell := new(Shell)
 
 	// Core editor
-	keys := new(core.Keys)
+	terminal := term.NewTerminal(in, out, size)
+	keys := core.NewKeys(terminal)
 	line := new(core.Line)
 	cursor := core.NewCursor(line)
 	selection := core.NewSelection(line, cursor)
 	iterations := new(core.Iterations)
 
+	shell.term = terminal
 	shell.Keys = keys
 	shell.line = line
 	shell.cursor = cursor
@@ -85,7 +166,8 @@ func NewShell(opts ...inputrc.Option) *Shell {
 	shell.Iterations = iterations
 
 	// Keymaps and commands
-	keymaps, config := keymap.NewEngine(keys, iterations, opts...)
+	keymaps, config := keymap.NewEngine(terminal, keys, iterations, opts...)
+	keymap.Init(keymaps, shell.keymapChanged)
 	keymaps.Register(shell.standardCommands())
 	keymaps.Register(shell.viCommands())
 	keymaps.Register(shell.historyCommands())
@@ -95,15 +177,17 @@ func NewShell(opts ...inputrc.Option) *Shell {
 	shell.Config = config
 	shell.Opts = opts
 
+	editor.Init(shell.Buffers, config, editor.NewTerminalClipboard(terminal, keys))
+
 	// User interface
-	hint := new(ui.Hint)
-	prompt := ui.NewPrompt(line, cursor, keymaps, config)
-	macros := macro.NewEngine(keys, hint)
+	hint := ui.NewHint(terminal)
+	prompt := ui.NewPrompt(terminal, line, cursor, keymaps, config)
+	macros := macro.NewEngine(terminal, keys, hint)
 	history := history.NewSources(line, cursor, hint, config)
-	completer := completion.NewEngine(hint, keymaps, config)
+	completer := completion.NewEngine(terminal, hint, keymaps, config)
 	completion.Init(completer, keys, line, cursor, selection, shell.commandCompletion)
 
-	display := display.NewEngine(keys, selection, history, prompt, hint, completer, config)
+	display := display.NewEngine(terminal, keys, selection, history, prompt, hint, completer, config)
 
 	shell.Config = config
 	shell.Hint = hint
@@ -116,6 +200,32 @@ func NewShell(opts ...inputrc.Option) *Shell {
 	return shell
 }
 
+// SetTerminalType sets the type of the shell terminal, like the TERM environment variable
+// (used by default) does. The terminfo entry of this type is used to translate the control
+// sequences written by the shell, and to find the features supported by the terminal (like
+// colors, cursor shapes or bracketed paste), so that the shell degrades gracefully. This
+// is useful when serving remote terminals, whose type is not the one of the application.
+//
+// If the type is empty or has no terminfo entry, like on Windows, the terminal is assumed
+// to be compatible with xterm, and to support all features.
+func (rl *Shell) SetTerminalType(name string) {
+	rl.term.SetType(name)
+}
+
+// CommandDone reports the exit status of the command run for the last line returned,
+// once its output is complete, when the enable-shell-integration option is on. Along
+// with the marks printed before the prompt, the input line and the command output
+// (OSC 133 sequences), this lets terminals jump between commands, select their
+// output, and show their status. The title of the terminal window and the working
+// directory of the application can also be reported with the Prompt methods.
+//
+// Nothing is printed if the shell input is not a terminal.
+func (rl *Shell) CommandDone(status int) {
+	if rl.term.IsTerminal() {
+		rl.Display.MarkCommandEnd(status)
+	}
+}
+
 // Line is the shell input line buffer.
 // Contains methods to search and modify its contents,
 // split itself with tokenizers, and displaying itself.
@@ -140,39 +250,55 @@ func (rl *Shell) Selection() *core.Selection { return rl.selection }
 // Printf prints a formatted string below the current line and redisplays the prompt
 // and input line (and possibly completions/hints if active) below the logged string.
 // A newline is added to the message so that the prompt is correctly refreshed below.
+// This is also the case when the shell is not reading a line: the prompt and the last
+// line read are then redisplayed below the message.
+//
+// This function is safe to call from any goroutine, including while the shell is
+// blocked reading keys: the message is printed as soon as the shell is not using
+// the display, and the interface is redrawn below it. It returns the number of bytes
+// of the message written, along with any error writing it to the shell terminal: if
+// the message is queued until the shell is done using the display (like when running
+// a command, or while suspended), it returns 0 and no error.
 func (rl *Shell) Printf(msg string, args ...any) (n int, err error) {
-	// First go back to the last line of the input line,
-	// and clear everything below (hints and completions).
-	rl.Display.CursorBelowLine()
-	term.MoveCursorBackwards(term.GetWidth())
-	fmt.Print(term.ClearScreenBelow)
-
-	// Skip a line, and print the formatted message.
-	n, err = fmt.Printf(msg+"\n", args...)
-
-	// Redisplay the prompt, input line and active helpers.
-	rl.Prompt.PrimaryPrint()
-	rl.Display.Refresh()
-
-	return
+	return rl.Display.PrintAbove(fmt.Sprintf(msg+"\n", args...), false)
 }
 
 // PrintTransientf prints a formatted string in place of the current prompt and input
 // line, and then refreshes, or "pushes" the prompt/line below this printed message.
+// Like Printf, this function is safe to call from any goroutine, and returns the same.
 func (rl *Shell) PrintTransientf(msg string, args ...any) (n int, err error) {
-	// First go back to the beginning of the line/prompt, and
-	// clear everything below (prompt/line/hints/completions).
-	rl.Display.CursorToLineStart()
-	term.MoveCursorBackwards(term.GetWidth())
-	term.MoveCursorUp(rl.Prompt.PrimaryUsed())
-	fmt.Print(term.ClearScreenBelow)
+	return rl.Display.PrintAbove(fmt.Sprintf(msg+"\n", args...), true)
+}
+
+// LogWriter returns a writer printing everything written to it above the prompt, like
+// PrintTransientf does. Output is printed one or more complete lines at a time, so that
+// partial writes do not push the prompt down. The writer is safe for concurrent use, and
+// can be used as the output of a logger, or of any background task of the application.
+// Write errors are those of the shell terminal, once the lines written are printed.
+func (rl *Shell) LogWriter() io.Writer {
+	return &logWriter{display: rl.Display}
+}
+
+// logWriter buffers writes until they contain complete lines.
+type logWriter struct {
+	display *display.Engine
+	buf     []byte
+	mutex   sync.Mutex
+}
+
+func (w *logWriter) Write(p []byte) (n int, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.buf = append(w.buf, p...)
 
-	// Print the logged message.
-	n, err = fmt.Printf(msg+"\n", args...)
+	end := bytes.LastIndexByte(w.buf, '\n')
+	if end == -1 {
+		return len(p), nil
+	}
 
-	// Redisplay the prompt, input line and active helpers.
-	rl.Prompt.PrimaryPrint()
-	rl.Display.Refresh()
+	_, err = w.display.PrintAbove(string(w.buf[:end+1]), true)
+	w.buf = append([]byte(nil), w.buf[end+1:]...)
 
-	return
+	return len(p), err
 }
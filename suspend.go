@@ -0,0 +1,96 @@
+package readline
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/reeflective/readline/internal/color"
+	"github.com/reeflective/readline/internal/keymap"
+	"github.com/reeflective/readline/internal/term"
+)
+
+// Suspend releases the terminal while the shell is reading a line, so that a command
+// (like one registered with Keymap.Register) can run interactive programs on it, like
+// pagers or fuzzy finders: the terminal is restored as it was before reading the line
+// (in cooked mode, and without the terminal modes enabled by the shell), the helpers
+// below the line are cleared, and the cursor is put on the row below the line.
+// Until Resume is called, the interface is not redisplayed, and messages printed
+// by other goroutines are queued.
+//
+// Nothing is done if the shell is not reading a line, or if it is already suspended.
+func (rl *Shell) Suspend() {
+	if !rl.Display.Suspend() {
+		return
+	}
+
+	_, modesOff := rl.terminalModes()
+	rl.term.Print(modesOff)
+	rl.term.Print(keymap.CursorStyle("default"))
+
+	if descriptor, isFile := rl.term.Fd(); isFile && rl.cooked != nil {
+		term.Restore(descriptor, rl.cooked)
+	}
+}
+
+// Resume takes the terminal back after a call to Suspend: the terminal is put in raw
+// mode again, and the whole interface is redisplayed where it was, assuming that the
+// programs run in between have put the cursor back on the row below the line (like
+// full-screen programs and pickers do). Any key typed while suspended and not read
+// by those programs is read by the shell.
+func (rl *Shell) Resume() {
+	rl.resume(true)
+}
+
+// RunExternal runs an interactive program while the shell is suspended (see Suspend),
+// and returns its error, if any, once it has exited and the shell has been resumed.
+// The program uses the shell terminal for its standard streams, unless they are set:
+// its input is only set if the one of the shell is a file, since otherwise, it would
+// be copied by a goroutine still reading it after the program has exited.
+func (rl *Shell) RunExternal(cmd *exec.Cmd) error {
+	if file, isFile := rl.term.Input().(*os.File); isFile && cmd.Stdin == nil {
+		cmd.Stdin = file
+	}
+
+	if cmd.Stdout == nil {
+		cmd.Stdout = rl.term.Output()
+	}
+
+	if cmd.Stderr == nil {
+		cmd.Stderr = rl.term.Output()
+	}
+
+	rl.Suspend()
+	defer rl.Resume()
+
+	return cmd.Run()
+}
+
+// resume puts the terminal back in the state needed to read a line, and redisplays
+// the interface, either where it was (see Resume) or on the row of the cursor.
+func (rl *Shell) resume(inPlace bool) {
+	if !rl.Display.Suspended() {
+		return
+	}
+
+	if err := rl.restoreTerminal(); err != nil {
+		rl.Hint.SetTemporary(color.FgRed + "terminal error: " + err.Error())
+	}
+
+	rl.Display.Resume(inPlace)
+}
+
+// restoreTerminal puts the terminal back in the state needed to read a line, after other
+// programs have used it: in raw mode, with the terminal modes and cursor style of the shell.
+func (rl *Shell) restoreTerminal() error {
+	if descriptor, isFile := rl.term.Fd(); isFile && rl.cooked != nil {
+		if _, err := term.MakeRaw(descriptor); err != nil {
+			return err
+		}
+	}
+
+	modesOn, _ := rl.terminalModes()
+	rl.term.Print(modesOn)
+	rl.Keymap.UpdateCursor()
+
+	return nil
+}
@@ -0,0 +1,25 @@
+//go:build unix
+
+package readline
+
+import "golang.org/x/sys/unix"
+
+// Suspend the shell process, like the terminal does when the suspend character (usually
+// Ctrl-Z) is input in cooked mode: the terminal is restored as it was before reading the
+// line, and the processes of the job are stopped. Once resumed (like with the fg command
+// of the parent shell), the prompt and the line are displayed again below the line.
+func (rl *Shell) suspendProcess() {
+	rl.History.SkipSave()
+
+	if _, isFile := rl.term.Fd(); !isFile {
+		return
+	}
+
+	rl.Suspend()
+
+	// Returns once the process has been resumed.
+	unix.Kill(0, unix.SIGTSTP)
+
+	// The parent shell has used the terminal.
+	rl.resume(false)
+}
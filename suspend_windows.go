@@ -0,0 +1,8 @@
+//go:build windows
+
+package readline
+
+// Suspending the shell process does nothing on Windows, which has no job control.
+func (rl *Shell) suspendProcess() {
+	rl.History.SkipSave()
+}
@@ -1,6 +1,7 @@
 package readline
 
 import (
+	"strings"
 	"unicode"
 
 	"github.com/reeflective/readline/inputrc"
@@ -251,7 +252,8 @@ func (rl *Shell) viForwardChar() {
 		vii := rl.Iterations.Get()
 
 		for i := 1; i <= vii; i++ {
-			if (*rl.line)[rl.cursor.Pos()+1] == '\n' {
+			_, next := rl.line.Grapheme(rl.cursor.Pos())
+			if next >= rl.line.Len() || (*rl.line)[next] == '\n' {
 				break
 			}
 
@@ -271,7 +273,7 @@ func (rl *Shell) viBackwardChar() {
 	}
 
 	for i := 1; i <= vii; i++ {
-		if (*rl.line)[rl.cursor.Pos()-1] == '\n' {
+		if rl.cursor.Pos() == 0 || (*rl.line)[rl.cursor.Pos()-1] == '\n' {
 			break
 		}
 
@@ -641,8 +643,8 @@ func (rl *Shell) viDeleteChar() {
 	vii := rl.Iterations.Get()
 
 	for i := 1; i <= vii; i++ {
-		cutBuf = append(cutBuf, rl.cursor.Char())
-		rl.line.CutRune(rl.cursor.Pos())
+		cutBuf = append(cutBuf, rl.cursor.Grapheme()...)
+		rl.line.CutGrapheme(rl.cursor.Pos())
 	}
 
 	rl.Buffers.Write(cutBuf...)
@@ -709,14 +711,14 @@ func (rl *Shell) viChangeCase() {
 			return
 		}
 
-		char := rl.cursor.Char()
-		if unicode.IsLower(char) {
-			char = unicode.ToUpper(char)
+		char := rl.cursor.Grapheme()
+		if unicode.IsLower(char[0]) {
+			char = []rune(strings.ToUpper(string(char)))
 		} else {
-			char = unicode.ToLower(char)
+			char = []rune(strings.ToLower(string(char)))
 		}
 
-		rl.cursor.ReplaceWith(char)
+		rl.cursor.ReplaceWith(char...)
 	}
 }
 
@@ -743,7 +745,7 @@ func (rl *Shell) viSubstitute() {
 			// Delete next characters and enter insert mode.
 			vii := rl.Iterations.Get()
 			for i := 1; i <= vii; i++ {
-				rl.line.CutRune(rl.cursor.Pos())
+				rl.line.CutGrapheme(rl.cursor.Pos())
 			}
 		case 'S':
 			if rl.cursor.OnEmptyLine() {
@@ -923,8 +925,8 @@ func (rl *Shell) viRubout() {
 		}
 
 		rl.cursor.Dec()
-		cut = append(cut, rl.cursor.Char())
-		rl.line.CutRune(rl.cursor.Pos())
+		cut = append(cut, rl.cursor.Grapheme()...)
+		rl.line.CutGrapheme(rl.cursor.Pos())
 	}
 
 	rl.Buffers.Write(cut...)